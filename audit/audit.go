@@ -0,0 +1,121 @@
+// Package audit records the requests to the bedrock-api in pluggable sinks:
+// memory, JSON lines file, Kubernetes Events in the client namespace and webhook
+package audit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/xumak-grid/bedrock"
+)
+
+const (
+	// Success is the outcome of the requests with a status lower than 400
+	Success = "success"
+	// Failure is the outcome of the requests with a status of 400 or more
+	Failure = "failure"
+
+	// DefaultLimit is the number of records returned by a query without limit
+	DefaultLimit = 100
+)
+
+// Sink stores the audit records
+type Sink interface {
+	Write(record bedrock.AuditRecord) error
+}
+
+// Querier is implemented by the sinks that can return the stored records
+type Querier interface {
+	// Query returns the last q.Limit records that match q in chronological order
+	Query(q Query) ([]bedrock.AuditRecord, error)
+}
+
+// Query filters the audit records, the empty fields match all the records
+type Query struct {
+	ClientID      string
+	EnvironmentID string
+	Since         time.Time
+	Limit         int
+}
+
+// Matches returns true when the record satisfies the filters of the query
+func (q Query) Matches(record bedrock.AuditRecord) bool {
+	if q.ClientID != "" && record.ClientID != q.ClientID {
+		return false
+	}
+	if q.EnvironmentID != "" && record.EnvironmentID != q.EnvironmentID {
+		return false
+	}
+	return q.Since.IsZero() || !record.Time.Before(q.Since)
+}
+
+// last returns the last q.Limit records, DefaultLimit when it is not set
+func (q Query) last(records []bedrock.AuditRecord) []bedrock.AuditRecord {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if len(records) > limit {
+		return records[len(records)-limit:]
+	}
+	return records
+}
+
+// Log writes the records to all the sinks
+type Log struct {
+	log     logrus.FieldLogger
+	sinks   []Sink
+	querier Querier
+}
+
+// New returns a log that writes to the sinks, the queries use the first sink that is a Querier,
+// a memory sink is added when none of them is
+func New(log logrus.FieldLogger, sinks ...Sink) *Log {
+	l := &Log{log: log, sinks: sinks}
+	for _, s := range sinks {
+		if q, ok := s.(Querier); ok {
+			l.querier = q
+			break
+		}
+	}
+	if l.querier == nil {
+		memory := NewMemorySink(DefaultMemoryRecords)
+		l.sinks = append(l.sinks, memory)
+		l.querier = memory
+	}
+	return l
+}
+
+// Record completes the id and the outcome of the record and writes it to the sinks,
+// the errors of the sinks are logged
+func (l *Log) Record(record bedrock.AuditRecord) {
+	if record.ID == "" {
+		record.ID = newID()
+	}
+	if record.Outcome == "" {
+		record.Outcome = Success
+		if record.Status >= 400 {
+			record.Outcome = Failure
+		}
+	}
+	for _, s := range l.sinks {
+		err := s.Write(record)
+		if err != nil {
+			l.log.WithError(err).WithField("audit", record.ID).Error("Error writing the audit record")
+		}
+	}
+}
+
+// Query returns the records that match q
+func (l *Log) Query(q Query) ([]bedrock.AuditRecord, error) {
+	return l.querier.Query(q)
+}
+
+// newID returns a random hex id
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
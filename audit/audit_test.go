@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/xumak-grid/bedrock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRedactBody(t *testing.T) {
+	scm := bedrock.SCM{Configuration: &bedrock.SCMConfig{
+		InitData: &bedrock.SCMInitData{AdminName: "admin", AdminPass: "s3cret", AdminConfirmPass: "s3cret"},
+	}}
+	artifactory := bedrock.Artifactory{Configuration: &bedrock.ArtifactoryConfig{
+		Proxies: []bedrock.ArtifactoryProxy{{Name: "central", Authentication: &bedrock.ArtifactoryAuth{Username: "ci", Password: "s3cret"}}},
+	}}
+	for _, v := range []interface{}{scm, artifactory} {
+		body, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		redacted, err := json.Marshal(RedactBody(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(redacted), "s3cret") || !strings.Contains(string(redacted), Redacted) {
+			t.Errorf("expected the passwords redacted, got %s", redacted)
+		}
+	}
+	if RedactBody(nil) != nil || RedactBody([]byte("not json")) != "not json" {
+		t.Error("expected nil for an empty body and the text of an invalid json")
+	}
+}
+
+// testRecords are three records of two clients in chronological order
+func testRecords() []bedrock.AuditRecord {
+	now := time.Now().UTC().Truncate(time.Second)
+	return []bedrock.AuditRecord{
+		{ID: "1", Time: now.Add(-2 * time.Minute), ClientID: "acme", Method: "POST", Status: 201},
+		{ID: "2", Time: now.Add(-time.Minute), ClientID: "other", Method: "DELETE", Status: 200},
+		{ID: "3", Time: now, ClientID: "acme", EnvironmentID: "dev", Method: "PATCH", Status: 400},
+	}
+}
+
+func TestSinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file, err := NewFileSink(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	sinks := map[string]interface {
+		Sink
+		Querier
+	}{
+		"memory": NewMemorySink(10),
+		"file":   file,
+	}
+	records := testRecords()
+	for name, sink := range sinks {
+		l := New(logrus.New(), sink)
+		for _, r := range records {
+			l.Record(r)
+		}
+
+		found, err := l.Query(Query{ClientID: "acme"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != 2 || found[0].ID != "1" || found[1].ID != "3" || found[1].Outcome != Failure {
+			t.Errorf("%v: expected the records of acme, got %+v", name, found)
+		}
+		found, _ = l.Query(Query{ClientID: "acme", Since: records[1].Time})
+		if len(found) != 1 || found[0].ID != "3" {
+			t.Errorf("%v: expected the records since the second, got %+v", name, found)
+		}
+		found, _ = l.Query(Query{ClientID: "acme", Limit: 1})
+		if len(found) != 1 || found[0].ID != "3" {
+			t.Errorf("%v: expected the last record, got %+v", name, found)
+		}
+	}
+}
+
+func TestEventSink(t *testing.T) {
+	kubecli := fake.NewSimpleClientset()
+	events := NewEventSink(func() kubernetes.Interface { return kubecli })
+	if _, ok := interface{}(events).(Querier); ok {
+		t.Error("expected the event sink without queries, the events expire")
+	}
+	l := New(logrus.New(), events)
+	for _, r := range testRecords() {
+		l.Record(r)
+	}
+
+	list, err := kubecli.CoreV1().Events("acme").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 2 || list.Items[0].InvolvedObject.Name != "acme" {
+		t.Errorf("expected an event for each record of acme, got %+v", list.Items)
+	}
+	found, err := l.Query(Query{ClientID: "acme"})
+	if err != nil || len(found) != 2 {
+		t.Errorf("expected the records of acme from the memory sink, got %+v: %v", found, err)
+	}
+}
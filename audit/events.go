@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"encoding/json"
+
+	"github.com/xumak-grid/bedrock"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// EventLabel identifies the Events created by the audit log
+	EventLabel = "bedrock-audit"
+	// eventReason is the reason of the audit Events
+	eventReason = "BedrockAudit"
+	// eventComponent is the source of the audit Events
+	eventComponent = "bedrock-api"
+)
+
+// EventSink stores the records as Kubernetes Events of the client namespace, the records
+// without client are ignored. The Events expire with the ttl of the cluster, one hour by default,
+// so the sink is not a Querier and the queries use the memory or the file sink.
+type EventSink struct {
+	kubeClient func() kubernetes.Interface
+}
+
+// NewEventSink returns a sink that uses the client returned by kubeClient,
+// the client is obtained on every call to use the current one after a kubeconfig reload.
+func NewEventSink(kubeClient func() kubernetes.Interface) *EventSink {
+	return &EventSink{kubeClient: kubeClient}
+}
+
+// Write implements Sink, the message of the Event is the json of the record
+func (e *EventSink) Write(record bedrock.AuditRecord) error {
+	if record.ClientID == "" {
+		return nil
+	}
+	message, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	eventType := v1.EventTypeNormal
+	if record.Outcome == Failure {
+		eventType = v1.EventTypeWarning
+	}
+	at := metav1.NewTime(record.Time)
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bedrock-audit-" + record.ID,
+			Namespace: record.ClientID,
+			Labels:    map[string]string{"app": EventLabel},
+		},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Namespace",
+			Name:       record.ClientID,
+		},
+		Reason:         eventReason,
+		Message:        string(message),
+		Type:           eventType,
+		Source:         v1.EventSource{Component: eventComponent},
+		FirstTimestamp: at,
+		LastTimestamp:  at,
+		Count:          1,
+	}
+	_, err = e.kubeClient().CoreV1().Events(record.ClientID).Create(event)
+	return err
+}
@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"sync"
+
+	"github.com/xumak-grid/bedrock"
+)
+
+// DefaultMemoryRecords is the number of records kept by the default memory sink
+const DefaultMemoryRecords = 1000
+
+// MemorySink keeps the last records in memory, they are lost on restart
+type MemorySink struct {
+	mu      sync.RWMutex
+	size    int
+	records []bedrock.AuditRecord
+}
+
+// NewMemorySink returns a sink that keeps the last size records
+func NewMemorySink(size int) *MemorySink {
+	return &MemorySink{size: size}
+}
+
+// Write implements Sink
+func (m *MemorySink) Write(record bedrock.AuditRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, record)
+	if len(m.records) > m.size {
+		m.records = append([]bedrock.AuditRecord{}, m.records[len(m.records)-m.size:]...)
+	}
+	return nil
+}
+
+// Query implements Querier
+func (m *MemorySink) Query(q Query) ([]bedrock.AuditRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	records := []bedrock.AuditRecord{}
+	for _, r := range m.records {
+		if q.Matches(r) {
+			records = append(records, r)
+		}
+	}
+	return q.last(records), nil
+}
@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Redacted replaces the values of the secret fields
+const Redacted = "[REDACTED]"
+
+// secretFields are the parts of the field names that hold secrets, for example
+// admin_passwd of bedrock.SCMInitData or password of bedrock.ArtifactoryAuth
+var secretFields = []string{"passw", "secret", "token", "credential"}
+
+// RedactBody decodes a json request body and replaces the values of the secret fields,
+// it returns nil when the body is empty and the body as a string when it is not json
+func RedactBody(body []byte) interface{} {
+	if len(body) == 0 {
+		return nil
+	}
+	var v interface{}
+	err := json.Unmarshal(body, &v)
+	if err != nil {
+		return string(body)
+	}
+	return redact(v)
+}
+
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			if isSecret(k) {
+				v[k] = Redacted
+				continue
+			}
+			v[k] = redact(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redact(value)
+		}
+	}
+	return v
+}
+
+func isSecret(field string) bool {
+	field = strings.ToLower(field)
+	for _, s := range secretFields {
+		if strings.Contains(field, s) {
+			return true
+		}
+	}
+	return false
+}
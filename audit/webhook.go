@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/xumak-grid/bedrock"
+)
+
+const (
+	// webhookQueueSize is the number of records waiting to be sent
+	webhookQueueSize = 1000
+	// webhookTimeout is the timeout of every POST to the webhook
+	webhookTimeout = 10 * time.Second
+)
+
+// errWebhookQueueFull is returned when the webhook is slower than the requests to the api
+var errWebhookQueueFull = errors.New("the queue of the audit webhook is full, the record is dropped")
+
+// WebhookSink sends every record as a json POST to a url, the records are sent in background
+// to not delay the responses of the api
+type WebhookSink struct {
+	url    string
+	client *http.Client
+	log    logrus.FieldLogger
+	queue  chan bedrock.AuditRecord
+}
+
+// NewWebhookSink returns a sink that posts the records to url
+func NewWebhookSink(log logrus.FieldLogger, url string) *WebhookSink {
+	w := &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+		log:    log,
+		queue:  make(chan bedrock.AuditRecord, webhookQueueSize),
+	}
+	go w.send()
+	return w
+}
+
+// Write implements Sink
+func (w *WebhookSink) Write(record bedrock.AuditRecord) error {
+	select {
+	case w.queue <- record:
+		return nil
+	default:
+		return errWebhookQueueFull
+	}
+}
+
+// send posts the records of the queue
+func (w *WebhookSink) send() {
+	for record := range w.queue {
+		err := w.post(record)
+		if err != nil {
+			w.log.WithError(err).WithField("audit", record.ID).Error("Error sending the audit record to the webhook")
+		}
+	}
+}
+
+func (w *WebhookSink) post(record bedrock.AuditRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("the webhook responded %v", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// Package auth provides authentication and authorization for the bedrock-api
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Role represents the verbs that a principal is allowed to use
+type Role string
+
+const (
+	// ReadOnly allows only GET requests
+	ReadOnly Role = "read-only"
+	// Admin allows all the requests
+	Admin Role = "admin"
+	// SecretsAdmin allows all the requests and also to reveal the passwords of the instances
+	SecretsAdmin Role = "secrets-admin"
+
+	// AllClients is the clientId that grants access to every client
+	AllClients = "*"
+)
+
+var (
+	// ErrUnauthenticated is returned when the request does not include valid credentials
+	ErrUnauthenticated = errors.New("authentication required")
+	// ErrForbidden is returned when the principal is not allowed to do the request
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Grant allows a role on a list of clients
+type Grant struct {
+	// ClientIDs are the clients covered by the grant, "*" covers all the clients
+	ClientIDs []string `json:"clientIds"`
+	Role      Role     `json:"role"`
+}
+
+// covers returns true if the grant includes the client, an empty clientID is covered by any grant
+func (g Grant) covers(clientID string) bool {
+	if clientID == "" {
+		return true
+	}
+	for _, id := range g.ClientIDs {
+		if id == AllClients || id == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// allows returns true if the role of the grant allows the http method
+func (g Grant) allows(method string) bool {
+	return g.Role == Admin || g.Role == SecretsAdmin || (g.Role == ReadOnly && isReadMethod(method))
+}
+
+// Principal represents an authenticated caller and the clients that it can access
+type Principal struct {
+	// Subject identifies the caller, for example the sub claim of a JWT
+	Subject string  `json:"subject"`
+	Grants  []Grant `json:"grants"`
+}
+
+// CanAccess returns true if at least one grant of the principal covers the client
+func (p *Principal) CanAccess(clientID string) bool {
+	if p == nil {
+		return false
+	}
+	for _, g := range p.Grants {
+		if g.covers(clientID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize returns ErrForbidden if no grant of the principal allows the method on the client
+// an empty clientID represents a resource that does not belong to a client
+func (p *Principal) Authorize(clientID, method string) error {
+	if p == nil {
+		return ErrForbidden
+	}
+	for _, g := range p.Grants {
+		if g.covers(clientID) && g.allows(method) {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
+
+// AuthorizeSecrets returns ErrForbidden if no grant of the principal allows to reveal
+// the secrets of the client
+func (p *Principal) AuthorizeSecrets(clientID string) error {
+	if p == nil {
+		return ErrForbidden
+	}
+	for _, g := range p.Grants {
+		if g.covers(clientID) && g.Role == SecretsAdmin {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
+
+// Authenticator obtains the principal from the credentials in the request
+type Authenticator interface {
+	// Authenticate returns ErrUnauthenticated when the credentials are not present or not valid
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Chain tries every authenticator in order and returns the first principal found
+type Chain []Authenticator
+
+// Authenticate implements Authenticator
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(r)
+		if err == nil {
+			return p, nil
+		}
+		if err != ErrUnauthenticated {
+			return nil, err
+		}
+	}
+	return nil, ErrUnauthenticated
+}
+
+// anonymous grants secrets-admin access to every request
+type anonymous struct{}
+
+// Anonymous returns an Authenticator that grants secrets-admin access to all the requests,
+// this must be used only for development
+func Anonymous() Authenticator {
+	return anonymous{}
+}
+
+func (anonymous) Authenticate(r *http.Request) (*Principal, error) {
+	return &Principal{
+		Subject: "anonymous",
+		Grants:  []Grant{{ClientIDs: []string{AllClients}, Role: SecretsAdmin}},
+	}, nil
+}
+
+// bearerToken returns the token from the Authorization header
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(h[7:])
+}
+
+func isReadMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func request(method, token string) *http.Request {
+	r, _ := http.NewRequest(method, "/api/v1/clients", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestAuthorize(t *testing.T) {
+	p := &Principal{
+		Subject: "portal",
+		Grants: []Grant{
+			{ClientIDs: []string{"demo"}, Role: Admin},
+			{ClientIDs: []string{"acme"}, Role: ReadOnly},
+		},
+	}
+	tests := []struct {
+		clientID, method string
+		allowed          bool
+	}{
+		{"demo", http.MethodDelete, true},
+		{"acme", http.MethodGet, true},
+		{"acme", http.MethodDelete, false},
+		{"other", http.MethodGet, false},
+		{"", http.MethodGet, true},
+	}
+	for _, tt := range tests {
+		err := p.Authorize(tt.clientID, tt.method)
+		if (err == nil) != tt.allowed {
+			t.Errorf("Authorize(%q, %v) = %v, allowed %v", tt.clientID, tt.method, err, tt.allowed)
+		}
+	}
+
+	p.Grants = append(p.Grants, Grant{ClientIDs: []string{"vault"}, Role: SecretsAdmin})
+	for clientID, allowed := range map[string]bool{"vault": true, "demo": false, "acme": false} {
+		err := p.AuthorizeSecrets(clientID)
+		if (err == nil) != allowed {
+			t.Errorf("AuthorizeSecrets(%q) = %v, allowed %v", clientID, err, allowed)
+		}
+	}
+}
+
+func TestStaticTokens(t *testing.T) {
+	a := NewStaticTokens([]StaticToken{
+		{Token: "secret", Subject: "portal", Grant: Grant{ClientIDs: []string{AllClients}, Role: Admin}},
+	})
+	p, err := a.Authenticate(request(http.MethodGet, "secret"))
+	if err != nil || p.Subject != "portal" {
+		t.Fatalf("valid token: principal %v, err %v", p, err)
+	}
+	_, err = a.Authenticate(request(http.MethodGet, "wrong"))
+	if err != ErrUnauthenticated {
+		t.Fatalf("invalid token: expected ErrUnauthenticated, got %v", err)
+	}
+	_, err = a.Authenticate(request(http.MethodGet, ""))
+	if err != ErrUnauthenticated {
+		t.Fatalf("anonymous request: expected ErrUnauthenticated, got %v", err)
+	}
+}
+
+func TestJWTWithJWKSFile(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	jwksFile := filepath.Join(dir, "jwks.json")
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+	data, _ := json.Marshal(jwks)
+	err = ioutil.WriteFile(jwksFile, data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(&Config{
+		OIDC: &JWTConfig{Issuer: "https://issuer.test", Audience: "bedrock", JWKSFile: jwksFile},
+		Rules: []Rule{
+			{Group: "ops", Grant: Grant{ClientIDs: []string{"demo"}, Role: ReadOnly}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := map[string]interface{}{
+		"iss":    "https://issuer.test",
+		"aud":    "bedrock",
+		"sub":    "jane",
+		"groups": []string{"ops"},
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+	p, err := a.Authenticate(request(http.MethodGet, signRS256(t, key, claims)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "jane" || p.Authorize("demo", http.MethodGet) != nil || p.Authorize("demo", http.MethodPost) == nil {
+		t.Errorf("unexpected principal %+v", p)
+	}
+
+	claims["exp"] = time.Now().Add(-time.Hour).Unix()
+	_, err = a.Authenticate(request(http.MethodGet, signRS256(t, key, claims)))
+	if err == nil {
+		t.Error("expired token should not be valid")
+	}
+
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	delete(claims, "iss")
+	_, err = a.Authenticate(request(http.MethodGet, signRS256(t, key, claims)))
+	if err == nil {
+		t.Error("token without issuer should not be valid")
+	}
+
+	_, err = New(&Config{OIDC: &JWTConfig{JWKSFile: jwksFile}})
+	if err == nil {
+		t.Error("oidc config without issuer should not be valid")
+	}
+	keys, err := NewFileKeySet(jwksFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutIssuer := NewJWT(JWTConfig{}, keys, nil)
+	_, err = withoutIssuer.Authenticate(request(http.MethodGet, signRS256(t, key, claims)))
+	if err == nil {
+		t.Error("token without issuer should not match an empty issuer")
+	}
+
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	claims["iss"] = "https://issuer.test"
+	_, err = a.Authenticate(request(http.MethodGet, signRS256(t, other, claims)))
+	if err == nil {
+		t.Error("token signed with another key should not be valid")
+	}
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// Config is the authentication configuration of the api, usually loaded from a JSON file
+//
+//	{
+//	  "tokens": [{"token": "secret", "subject": "portal", "clientIds": ["*"], "role": "admin"}],
+//	  "oidc": {"issuer": "https://accounts.example.com", "audience": "bedrock"},
+//	  "rules": [{"group": "ops", "clientIds": ["demo"], "role": "read-only"}]
+//	}
+type Config struct {
+	// Tokens are static bearer tokens
+	Tokens []StaticToken `json:"tokens,omitempty"`
+	// OIDC enables the validation of JWT bearer tokens
+	OIDC *JWTConfig `json:"oidc,omitempty"`
+	// Rules give grants to the subjects or groups of the JWT bearer tokens
+	Rules []Rule `json:"rules,omitempty"`
+}
+
+// LoadConfig reads the configuration from a JSON file
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	err = json.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth config %v: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// New returns an Authenticator from the configuration
+// static tokens are checked before JWT tokens
+func New(cfg *Config) (Authenticator, error) {
+	chain := Chain{}
+	for _, t := range cfg.Tokens {
+		if t.Token == "" {
+			return nil, errors.New("static token can not be empty")
+		}
+		if err := validRole(t.Role); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.Tokens) > 0 {
+		chain = append(chain, NewStaticTokens(cfg.Tokens))
+	}
+	if cfg.OIDC != nil {
+		for _, r := range cfg.Rules {
+			if err := validRole(r.Role); err != nil {
+				return nil, err
+			}
+		}
+		if cfg.OIDC.Issuer == "" {
+			return nil, errors.New("oidc issuer is required")
+		}
+		keys, err := keySet(cfg.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, NewJWT(*cfg.OIDC, keys, cfg.Rules))
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("auth config requires tokens or oidc")
+	}
+	return chain, nil
+}
+
+// keySet returns the local JWKS file when is configured, otherwise uses the OIDC discovery
+func keySet(cfg *JWTConfig) (KeySet, error) {
+	if cfg.JWKSFile != "" {
+		return NewFileKeySet(cfg.JWKSFile)
+	}
+	return DiscoverKeySet(cfg.Issuer)
+}
+
+func validRole(role Role) error {
+	if role != Admin && role != ReadOnly && role != SecretsAdmin {
+		return fmt.Errorf("unknown role %q, the options are: %v, %v, %v", role, Admin, ReadOnly, SecretsAdmin)
+	}
+	return nil
+}
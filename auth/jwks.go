@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeySet provides the public keys used to verify the signature of a JWT
+type KeySet interface {
+	// Key returns the key with the given id, an empty kid is valid when the set has only one key
+	Key(kid string) (crypto.PublicKey, error)
+}
+
+// jwk represents a JSON Web Key, only RSA and EC P-256 keys are supported
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS returns the public keys of a JWKS document by kid
+// keys with an unsupported type are ignored
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	doc := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwks: %v", err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range doc.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, err := decodeBigInt(k.N)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jwks key %v: %v", k.Kid, err)
+			}
+			e, err := decodeBigInt(k.E)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jwks key %v: %v", k.Kid, err)
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+			x, err := decodeBigInt(k.X)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jwks key %v: %v", k.Kid, err)
+			}
+			y, err := decodeBigInt(k.Y)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jwks key %v: %v", k.Kid, err)
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks does not contain supported keys")
+	}
+	return keys, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// findKey returns the key by kid, or the only key when kid is empty
+func findKey(keys map[string]crypto.PublicKey, kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, k := range keys {
+			return k, true
+		}
+	}
+	k, ok := keys[kid]
+	return k, ok
+}
+
+// staticKeySet is a KeySet that never changes, for example a local JWKS file
+type staticKeySet struct {
+	keys map[string]crypto.PublicKey
+}
+
+// NewFileKeySet returns a KeySet from a local JWKS file
+func NewFileKeySet(path string) (KeySet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	return &staticKeySet{keys: keys}, nil
+}
+
+func (s *staticKeySet) Key(kid string) (crypto.PublicKey, error) {
+	k, ok := findKey(s.keys, kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return k, nil
+}
+
+// remoteRefreshInterval is the minimum time between two downloads of a remote JWKS
+const remoteRefreshInterval = time.Minute
+
+// remoteKeySet downloads the JWKS from a URL and downloads it again when a key id is unknown
+type remoteKeySet struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewRemoteKeySet returns a KeySet that downloads the JWKS from url
+func NewRemoteKeySet(url string) KeySet {
+	return &remoteKeySet{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// DiscoverKeySet returns a remote KeySet using the jwks_uri from the OIDC discovery document of the issuer
+func DiscoverKeySet(issuer string) (KeySet, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Get(strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery for %v returned %v", issuer, res.Status)
+	}
+	doc := struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}{}
+	err = json.NewDecoder(res.Body).Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid oidc discovery document: %v", err)
+	}
+	if doc.JWKSURI == "" {
+		return nil, errors.New("oidc discovery document does not include jwks_uri")
+	}
+	return NewRemoteKeySet(doc.JWKSURI), nil
+}
+
+func (s *remoteKeySet) Key(kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if k, ok := findKey(s.keys, kid); ok {
+		return k, nil
+	}
+	if time.Since(s.fetched) < remoteRefreshInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	err := s.fetch()
+	if err != nil {
+		return nil, err
+	}
+	k, ok := findKey(s.keys, kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return k, nil
+}
+
+// fetch downloads the JWKS, the caller must hold the lock
+func (s *remoteKeySet) fetch() error {
+	s.fetched = time.Now()
+	res, err := s.client.Get(s.url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("jwks %v returned %v", s.url, res.Status)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	return nil
+}
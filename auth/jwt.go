@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// clockSkew is the tolerance used when the exp and nbf claims are validated
+const clockSkew = time.Minute
+
+// JWTConfig configures the validation of OIDC/JWT bearer tokens
+type JWTConfig struct {
+	// Issuer is required and must match the iss claim, it is also used for the OIDC discovery
+	Issuer string `json:"issuer"`
+	// Audience must be present in the aud claim when is not empty
+	Audience string `json:"audience,omitempty"`
+	// JWKSFile is a local JWKS file used instead of the OIDC discovery, useful for testing
+	JWKSFile string `json:"jwksFile,omitempty"`
+	// GroupsClaim is the claim with the groups of the subject, by default "groups"
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+}
+
+// Rule gives a grant to a subject or to a group of a JWT
+type Rule struct {
+	Subject string `json:"subject,omitempty"`
+	Group   string `json:"group,omitempty"`
+	Grant
+}
+
+// jwtAuthenticator authenticates requests with a signed JWT bearer token
+type jwtAuthenticator struct {
+	cfg   JWTConfig
+	keys  KeySet
+	rules []Rule
+	now   func() time.Time
+}
+
+// NewJWT returns an Authenticator that validates JWT bearer tokens signed with keys
+// the principal is built with the rules that match the subject or the groups of the token
+func NewJWT(cfg JWTConfig, keys KeySet, rules []Rule) Authenticator {
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	return &jwtAuthenticator{cfg: cfg, keys: keys, rules: rules, now: time.Now}
+}
+
+func (j *jwtAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := bearerToken(r)
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		// not a JWT, other authenticators can accept it
+		return nil, ErrUnauthenticated
+	}
+	claims, err := j.verify(parts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+	subject, _ := claims["sub"].(string)
+	return j.principal(subject, stringList(claims[j.cfg.GroupsClaim])), nil
+}
+
+// verify checks the signature and the registered claims of the token and returns its claims
+func (j *jwtAuthenticator) verify(parts []string) (map[string]interface{}, error) {
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, err
+	}
+	key, err := j.keys.Key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	err = verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := map[string]interface{}{}
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, err
+	}
+	iss, ok := claims["iss"].(string)
+	if !ok || iss == "" {
+		return nil, errors.New("iss claim is required")
+	}
+	if iss != j.cfg.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if j.cfg.Audience != "" && !contains(stringList(claims["aud"]), j.cfg.Audience) {
+		return nil, errors.New("unexpected audience")
+	}
+	now := j.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("exp claim is required")
+	}
+	if now.Add(-clockSkew).After(time.Unix(int64(exp), 0)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token not valid yet")
+	}
+	return claims, nil
+}
+
+// principal collects the grants of the rules that match the subject or one of the groups
+func (j *jwtAuthenticator) principal(subject string, groups []string) *Principal {
+	p := &Principal{Subject: subject, Grants: []Grant{}}
+	for _, rule := range j.rules {
+		if (rule.Subject != "" && rule.Subject == subject) || (rule.Group != "" && contains(groups, rule.Group)) {
+			p.Grants = append(p.Grants, rule.Grant)
+		}
+	}
+	return p
+}
+
+// verifySignature supports RS256 and ES256 signatures
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	hash := sha256.Sum256(signed)
+	switch alg {
+	case "RS256":
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key is not an RSA key")
+		}
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], signature)
+	case "ES256":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key is not an EC key")
+		}
+		if len(signature) != 64 {
+			return errors.New("invalid signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(k, hash[:], r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %q", alg)
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// stringList converts a claim that can be a string or a list of strings
+func stringList(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		l := []string{}
+		for _, i := range v {
+			if s, ok := i.(string); ok {
+				l = append(l, s)
+			}
+		}
+		return l
+	}
+	return nil
+}
+
+func contains(l []string, s string) bool {
+	for _, i := range l {
+		if i == s {
+			return true
+		}
+	}
+	return false
+}
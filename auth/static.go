@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// StaticToken is a bearer token with a fixed subject and grant
+type StaticToken struct {
+	Token   string `json:"token"`
+	Subject string `json:"subject"`
+	Grant
+}
+
+// staticTokens authenticates requests with a list of static bearer tokens
+type staticTokens struct {
+	tokens []StaticToken
+}
+
+// NewStaticTokens returns an Authenticator that accepts the given bearer tokens
+func NewStaticTokens(tokens []StaticToken) Authenticator {
+	return &staticTokens{tokens: tokens}
+}
+
+func (s *staticTokens) Authenticate(r *http.Request) (*Principal, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return &Principal{Subject: t.Subject, Grants: []Grant{t.Grant}}, nil
+		}
+	}
+	return nil, ErrUnauthenticated
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/xumak-grid/bedrock/metrics"
 )
 
 const (
@@ -43,19 +45,64 @@ func (s3o *S3Object) PreSignedURL(sess *session.Session, hours int) (string, err
 	})
 
 	urlStr, err := req.Presign(time.Duration(hours) * time.Hour)
+	metrics.CountS3("presign", err)
 	if err != nil {
 		return "", fmt.Errorf("url file %s", err.Error())
 	}
 	return urlStr, nil
 }
 
-// Session returns a new AWS session using envVar
-func Session() (*session.Session, error) {
+// PreSignedUploadURL returns a pre-signed aws s3 url that uploads the object with a PUT and expires in h hours
+func (s3o *S3Object) PreSignedUploadURL(sess *session.Session, hours int) (string, error) {
+
+	svc := s3.New(sess)
+	req, _ := svc.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(s3o.BucketName),
+		Key:    aws.String(s3o.Key),
+	})
+
+	urlStr, err := req.Presign(time.Duration(hours) * time.Hour)
+	metrics.CountS3("presign", err)
+	if err != nil {
+		return "", fmt.Errorf("upload url file %s", err.Error())
+	}
+	return urlStr, nil
+}
+
+// Presigner creates pre-signed urls to download and upload S3 objects
+type Presigner interface {
+	PreSignedURL(s3o *S3Object, hours int) (string, error)
+	PreSignedUploadURL(s3o *S3Object, hours int) (string, error)
+}
+
+// EnvPresigner is the Presigner that uses a session created from the env vars on every call,
+// the credentials can be rotated without restarting the api
+type EnvPresigner struct{}
+
+// PreSignedURL returns a pre-signed aws s3 url of s3o that expires in h hours
+func (EnvPresigner) PreSignedURL(s3o *S3Object, hours int) (string, error) {
+	sess, err := Session()
+	if err != nil {
+		return "", err
+	}
+	return s3o.PreSignedURL(sess, hours)
+}
 
+// PreSignedUploadURL returns a pre-signed aws s3 url that uploads s3o and expires in h hours
+func (EnvPresigner) PreSignedUploadURL(s3o *S3Object, hours int) (string, error) {
+	sess, err := Session()
+	if err != nil {
+		return "", err
+	}
+	return s3o.PreSignedUploadURL(sess, hours)
+}
+
+// EnvCredentials returns the credentials and the region in the envVars, us-east-1 when the region is not set
+func EnvCredentials() (credentials.Value, string, error) {
 	accessKeyID := os.Getenv(AccessKeyEnvVar)
 	secretAccessKey := os.Getenv(SecretKeyEnvVar)
 	if accessKeyID == "" || secretAccessKey == "" {
-		return nil, fmt.Errorf("empty %v or %v env vars", AccessKeyEnvVar, SecretKeyEnvVar)
+		return credentials.Value{}, "", fmt.Errorf("empty %v or %v env vars", AccessKeyEnvVar, SecretKeyEnvVar)
 	}
 
 	value := credentials.Value{
@@ -67,6 +114,16 @@ func Session() (*session.Session, error) {
 	if region == "" {
 		region = "us-east-1"
 	}
+	return value, region, nil
+}
+
+// Session returns a new AWS session using envVar
+func Session() (*session.Session, error) {
+
+	value, region, err := EnvCredentials()
+	if err != nil {
+		return nil, err
+	}
 
 	creds := credentials.NewStaticCredentialsFromCreds(value)
 	sess, err := session.NewSession(&aws.Config{
@@ -78,3 +135,9 @@ func Session() (*session.Session, error) {
 	}
 	return sess, nil
 }
+
+// CheckCredentials returns an error if the credentials of the session are not valid
+func CheckCredentials(sess *session.Session) error {
+	_, err := sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	return err
+}
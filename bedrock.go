@@ -1,6 +1,10 @@
 package bedrock
 
-import "os"
+import (
+	"net/http"
+	"os"
+	"time"
+)
 
 const (
 	// DevDockerRepository default aws repository.
@@ -10,33 +14,129 @@ const (
 	ProdDockerRepository = "your.image"
 
 	gridExternalDomainEnvVar = "GRID_EXTERNAL_DOMAIN"
+
+	// OperationPending the operation or step has not started yet
+	OperationPending = "pending"
+	// OperationRunning the operation or step is in progress
+	OperationRunning = "running"
+	// OperationSucceeded the operation or step finished without errors
+	OperationSucceeded = "succeeded"
+	// OperationFailed the operation or step finished with an error
+	OperationFailed = "failed"
+
+	// ContentSetupDantaDemo the repository is initialized with the danta aem demo
+	ContentSetupDantaDemo = "danta-aem-demo"
+	// ContentSetupEPCommerce the repository is initialized with an ep-commerce project
+	ContentSetupEPCommerce = "ep-commerce"
+	// ContentSetupBloomreach the repository is initialized with a bloomreach archetype
+	ContentSetupBloomreach = "bloomreach-archetype"
+
+	// HealthOK the api or the dependency is working
+	HealthOK = "ok"
+	// HealthError the api or the dependency is not working
+	HealthError = "error"
+)
+
+// Reasons of the api errors, they are returned in the reason field of an error response
+const (
+	// ReasonValidation the request is not valid, details includes the invalid fields
+	ReasonValidation = "Validation"
+	// ReasonNotFound the resource does not exist
+	ReasonNotFound = "NotFound"
+	// ReasonConflict the resource already exists or was modified by another request
+	ReasonConflict = "Conflict"
+	// ReasonUpstreamFailure a dependency like kubernetes, vault or aws failed or is not reachable
+	ReasonUpstreamFailure = "UpstreamFailure"
+	// ReasonUnauthorized the request does not include valid credentials
+	ReasonUnauthorized = "Unauthorized"
+	// ReasonForbidden the credentials are not allowed to do the request
+	ReasonForbidden = "Forbidden"
+	// ReasonInternal an unexpected error
+	ReasonInternal = "Internal"
+)
+
+// StatusReason returns the reason of an error response that only has a status code
+func StatusReason(code int) string {
+	switch code {
+	case http.StatusBadRequest:
+		return ReasonValidation
+	case http.StatusUnauthorized:
+		return ReasonUnauthorized
+	case http.StatusForbidden:
+		return ReasonForbidden
+	case http.StatusNotFound:
+		return ReasonNotFound
+	case http.StatusConflict:
+		return ReasonConflict
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ReasonUpstreamFailure
+	}
+	return ReasonInternal
+}
+
+// FieldError represents a validation error of a field of the request
+type FieldError struct {
+	// Field is the json path of the field, for example configuration.adminEmail
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+const (
+	// ClientActive is the status of a client that is running
+	ClientActive = "active"
+	// ClientSuspended is the status of a client scaled to zero
+	ClientSuspended = "suspended"
+	// ClientDeleting is the status of a client scheduled for deletion
+	ClientDeleting = "deleting"
+
+	// ReservedMetaDataPrefix is the prefix of the annotations used by bedrock to store the state of the clients
+	ReservedMetaDataPrefix = "grid.xumak.io/"
 )
 
 // Client represents an abstraction of a client
 type Client struct {
 	// ClientID represents a namespace where the client resources will live
-	ClientID string `json:"clientId"`
-	// MetaData represents additional information of the client, this will be stored in annotations
-	MetaData     map[string]string `json:"meta,omitempty"`
-	CustomConfig bool              `json:"customConfig"`
+	ClientID string `json:"clientId" validate:"required,dns1123label"`
+	// MetaData represents additional information of the client, this will be stored in annotations,
+	// the keys with the ReservedMetaDataPrefix are used by bedrock and are not allowed
+	MetaData map[string]string `json:"meta,omitempty"`
+	// Status is one of ClientActive, ClientSuspended or ClientDeleting, it is set by the api
+	Status string `json:"status,omitempty"`
+	// Protected is true while the client can not be deleted, it is set by the api
+	Protected bool `json:"protected,omitempty"`
+	// CreatedAt is the creation time of the namespace, it is set by the api
+	CreatedAt    *time.Time `json:"createdAt,omitempty"`
+	CustomConfig bool       `json:"customConfig"`
 	// Configuration is required when CustomConfig is set to true
 	Configuration *ClientCustomConfig `json:"configuration,omitempty"`
 	// DryRun allows to return the FullDeploy without create any resource
 	DryRun bool `json:"dryRun,omitempty"`
+	// KeepOnFailure keeps the resources already created when the FullDeploy fails,
+	// by default they are removed, this is useful to debug a failed FullDeploy
+	KeepOnFailure bool `json:"keepOnFailure,omitempty"`
+}
+
+// ClientPatch changes the metadata of a client, a null value removes the key
+type ClientPatch struct {
+	MetaData map[string]*string `json:"meta,omitempty"`
 }
 
 // ClientCustomConfig represents basic information to create the fullDeploy for the client
 type ClientCustomConfig struct {
-	FullCompanyName            string   `json:"fullCompanyName"`
-	AdminEmail                 string   `json:"adminEmail"`
-	Environments               []string `json:"environments"`
-	AEMInstancesVersion        string   `json:"aemInstancesVersion"`
-	AEMInstancesType           string   `json:"aemInstancesType"`
-	DispatcherInstancesVersion string   `json:"dispatcherInstancesVersion"`
-	DispatcherInstancesType    string   `json:"dispatcherInstancesType"`
+	FullCompanyName            string   `json:"fullCompanyName" validate:"required"`
+	AdminEmail                 string   `json:"adminEmail" validate:"required,email"`
+	Environments               []string `json:"environments" validate:"required,min=1"`
+	AEMInstancesVersion        string   `json:"aemInstancesVersion" validate:"required"`
+	AEMInstancesType           string   `json:"aemInstancesType" validate:"required,oneof=small medium large"`
+	DispatcherInstancesVersion string   `json:"dispatcherInstancesVersion" validate:"required"`
+	DispatcherInstancesType    string   `json:"dispatcherInstancesType" validate:"required,oneof=small medium large"`
 	// InitialRepositoryType defines the initial project in the repository
 	// see options available in SCMRepository.ContentSetupType field
-	InitialRepositoryType string `json:"initialRepositoryType"`
+	InitialRepositoryType string `json:"initialRepositoryType" validate:"oneof=danta-aem-demo ep-commerce bloomreach-archetype"`
+	// EPObjectType is required when the InitialRepositoryType is set to "ep-commerce"
+	EPObjectType *EPObjectType `json:"epCommerce,omitempty"`
+	// BRObjectType is required when the InitialRepositoryType is set to "bloomreach-archetype"
+	BRObjectType *BRObjectType `json:"bloomreachArchetype,omitempty"`
 }
 
 // FullDeploy represent a full deployment of a client, describes the resources that will be created
@@ -49,11 +149,80 @@ type FullDeploy struct {
 	Toolbelt       Toolbelt
 }
 
+// Operation represents an asynchronous task, for example the creation of a full deploy
+type Operation struct {
+	OperationID string `json:"operationId"`
+	ClientID    string `json:"clientId"`
+	// Type is the kind of task executed by the operation, for example "fullDeploy"
+	Type string `json:"type"`
+	// Status is one of: pending, running, succeeded, failed
+	Status string          `json:"status"`
+	Error  string          `json:"error,omitempty"`
+	Steps  []OperationStep `json:"steps"`
+	// RolledBack is true when the resources created by a failed operation were removed
+	RolledBack bool `json:"rolledBack"`
+	// Result is the FullDeploy populated with the created resources, available when the operation succeeded,
+	// the configurations of the artifactory and the scm are removed because they include passwords
+	Result     *FullDeploy `json:"result,omitempty"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+}
+
+// OperationStep represents a single step of an operation, for example the creation of the SCM
+type OperationStep struct {
+	Name string `json:"name"`
+	// Status is one of: pending, running, succeeded, failed
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// AuditRecord is a request to the api recorded in the audit log, the secrets of the body are redacted
+type AuditRecord struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Actor is the subject of the principal that did the request
+	Actor         string `json:"actor"`
+	ClientID      string `json:"clientId,omitempty"`
+	EnvironmentID string `json:"environmentId,omitempty"`
+	Method        string `json:"method"`
+	// Route is the pattern of the route, for example /api/v1/clients/{clientId}
+	Route string `json:"route"`
+	Path  string `json:"path"`
+	// Body is the json of the request body
+	Body   interface{} `json:"body,omitempty"`
+	Status int         `json:"status"`
+	// Outcome is success when the status is lower than 400, otherwise failure
+	Outcome    string `json:"outcome"`
+	DurationMs int64  `json:"durationMs"`
+	RequestID  string `json:"requestId,omitempty"`
+}
+
+// Health represents the state of the api and of each dependency that it uses
+type Health struct {
+	// Status is ok when all the dependencies are ok, otherwise error
+	Status       string             `json:"status"`
+	Dependencies []DependencyHealth `json:"dependencies,omitempty"`
+}
+
+// DependencyHealth represents the result of the check of a dependency
+type DependencyHealth struct {
+	Name string `json:"name"`
+	// Status is one of: ok, error
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Duration is the time taken by the check, for example 12ms
+	Duration string `json:"duration"`
+}
+
 // Toolbelt represent a toolbelt box to donwload
 type Toolbelt struct {
 	ClientID string `json:"clientId"`
 	URL      string `json:"url"`
 	Message  string `json:"message"`
+	// ExpiresAt is the expiration of the presigned URL, empty when it is unknown
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 }
 
 // Deployment represents a bedrock stack deploy.
@@ -68,7 +237,7 @@ type AEMDeployment struct {
 	ClientID string `json:"clientId"`
 	// EnvironmentID represents the environment where the deployment deployed
 	// also is the name of the aem deployment and is taken from the URL
-	EnvironmentID string `json:"environmentId"`
+	EnvironmentID string `json:"environmentId" validate:"dns1123label"`
 	// Spec This is the configuration for the deployment, these are the value from the
 	// body request
 	Spec AEMDeploymentSpec `json:"spec,omitempty"`
@@ -82,17 +251,17 @@ type AEMDeploymentSpec struct {
 	Publishers  Config `json:"publishers,omitempty"`
 	Dispatchers Config `json:"dispatchers,omitempty"`
 	// Version represents the version of the deployment for example "6.3"
-	Version string `json:"version"`
+	Version string `json:"version" validate:"required"`
 	// DispatcherVersion represents the version of the dispatcher
-	DispatcherVersion string `json:"dispatcher_version"`
+	DispatcherVersion string `json:"dispatcher_version" validate:"required"`
 }
 
 // Config the instance config for AEM deployment app
 type Config struct {
 	// Type represents the type of the instances deployed for example "small"
-	Type string `json:"type"`
-	// Replicas is the number of replicas in the deployment
-	Replicas int `json:"replicas"`
+	Type string `json:"type" validate:"oneof=small medium large"`
+	// Replicas is the number of replicas in the deployment, from 0 to 10
+	Replicas int `json:"replicas" validate:"min=0,max=10"`
 }
 
 type SCMDeployment struct {
@@ -117,7 +286,139 @@ type Instance struct {
 	Runmode     string `json:"runmode"`
 	Running     bool   `json:"running"`
 	Ready       bool   `json:"ready"`
-	Password    string `json:"password"`
+}
+
+// InstanceCredentials are the admin credentials of an AEM instance, when the password is
+// wrapped it is empty and WrappingToken returns it once with vault unwrap
+type InstanceCredentials struct {
+	ClientID          string     `json:"clientId"`
+	EnvironmentID     string     `json:"environmentId"`
+	Instance          string     `json:"instance"`
+	Password          string     `json:"password,omitempty"`
+	WrappingToken     string     `json:"wrappingToken,omitempty"`
+	WrappingExpiresAt *time.Time `json:"wrappingExpiresAt,omitempty"`
+}
+
+// PasswordRotation is the result of rotating the admin password of AEM instances,
+// the new passwords are stored in the secret service and applied by the aem-operator
+type PasswordRotation struct {
+	ClientID      string `json:"clientId"`
+	EnvironmentID string `json:"environmentId"`
+	// Instances are the names of the pods with a new password
+	Instances []string  `json:"instances"`
+	RotatedAt time.Time `json:"rotatedAt"`
+	// RotatedBy is the subject of the caller
+	RotatedBy string `json:"rotatedBy"`
+}
+
+// ClientSuspension is the state of a client whose AEM deployments and stack servers are scaled to zero,
+// the replicas before the suspension are restored by the resume
+type ClientSuspension struct {
+	ClientID    string     `json:"clientId"`
+	Suspended   bool       `json:"suspended"`
+	SuspendedAt *time.Time `json:"suspendedAt,omitempty"`
+	// SuspendedBy is the subject of the caller that suspended the client
+	SuspendedBy string `json:"suspendedBy,omitempty"`
+	// AEMDeployments maps the environments to the replicas of their instances before the suspension
+	AEMDeployments map[string]InstanceReplicas `json:"aemDeployments"`
+	// StatefulSets maps the stack servers, for example nexus-server, to their replicas before the suspension
+	StatefulSets map[string]int32 `json:"statefulSets"`
+}
+
+// InstanceReplicas are the replicas of the instances of an AEM deployment
+type InstanceReplicas struct {
+	Authors     int `json:"authors"`
+	Publishers  int `json:"publishers"`
+	Dispatchers int `json:"dispatchers"`
+}
+
+// ClientSummary is the state of a client and all its provisioned stacks in one response,
+// the stacks that are not provisioned are omitted
+type ClientSummary struct {
+	Client       Client               `json:"client"`
+	Environments []EnvironmentSummary `json:"environments"`
+	Artifactory  *StackSummary        `json:"artifactory,omitempty"`
+	SCM          *StackSummary        `json:"scm,omitempty"`
+	CI           *StackSummary        `json:"ci,omitempty"`
+	Certificate  *CertificateSummary  `json:"certificate,omitempty"`
+	Toolbelt     *ToolbeltSummary     `json:"toolbelt,omitempty"`
+	// Errors are the resources that could not be read, the rest of the summary is complete
+	Errors []string `json:"errors,omitempty"`
+}
+
+// EnvironmentSummary is the state of the AEM deployment of an environment
+type EnvironmentSummary struct {
+	EnvironmentID     string `json:"environmentId"`
+	Status            string `json:"status"`
+	Version           string `json:"version"`
+	DispatcherVersion string `json:"dispatcherVersion"`
+	// Replicas are the desired instances and ReadyReplicas the instances whose pod is ready
+	Replicas      InstanceReplicas `json:"replicas"`
+	ReadyReplicas InstanceReplicas `json:"readyReplicas"`
+}
+
+// StackSummary is the state of the server of a stack, the readiness is derived from its StatefulSet
+type StackSummary struct {
+	Vendor        string `json:"vendor"`
+	Image         string `json:"image"`
+	Host          string `json:"host,omitempty"`
+	Replicas      int32  `json:"replicas"`
+	ReadyReplicas int32  `json:"readyReplicas"`
+	// Ready is true when all the desired replicas are ready, a suspended server is not ready
+	Ready bool `json:"ready"`
+	// InitJob is the Operation status of the job that applies the custom configuration,
+	// empty when the server has no custom configuration
+	InitJob string `json:"initJob,omitempty"`
+}
+
+// CertificateSummary is the state of the certManager Certificate of the client
+type CertificateSummary struct {
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+	// Message is the message of the Ready condition, for example the reason of a failed issue
+	Message string `json:"message,omitempty"`
+}
+
+// ToolbeltSummary is the toolbelt of the client and the expiration of its URL
+type ToolbeltSummary struct {
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Expired   bool       `json:"expired"`
+}
+
+const (
+	// DeletionPending is the status of a deletion during the grace period or while the exports run
+	DeletionPending = "pending"
+	// DeletionExportFailed is the status of a deletion with a failed export, the client is kept until
+	// the deletion is cancelled and requested again
+	DeletionExportFailed = "exportFailed"
+	// DeletionReady is the status of a deletion after the grace period with all the exports succeeded
+	DeletionReady = "ready"
+	// DeletionCancelled is the status of a cancelled deletion
+	DeletionCancelled = "cancelled"
+)
+
+// ClientDeletion is a deletion of a client scheduled after a grace period, when it ends and the
+// exports succeeded the secrets and the namespace of the client are deleted
+type ClientDeletion struct {
+	ClientID    string    `json:"clientId"`
+	RequestedAt time.Time `json:"requestedAt"`
+	// RequestedBy is the subject of the caller that requested the deletion
+	RequestedBy string `json:"requestedBy"`
+	// DeleteAt is the end of the grace period, the deletion can be cancelled until then
+	DeleteAt time.Time      `json:"deleteAt"`
+	Exports  []ClientExport `json:"exports"`
+	Status   string         `json:"status"`
+}
+
+// ClientExport is the export to S3 of the data of a stack before the deletion of the client
+type ClientExport struct {
+	// Stack is scm or artifactory
+	Stack string `json:"stack"`
+	// Location is the S3 url of the export, for example s3://bucket/acme/20180102T150405Z/scm.tar.gz
+	Location string `json:"location"`
+	// Status is running, succeeded or failed
+	Status string `json:"status"`
 }
 
 // Artifactory represents an Artifactory manager for example nexus
@@ -125,7 +426,7 @@ type Artifactory struct {
 	ArtifactoryID string `json:"artifactoryId"`
 	ServerName    string `json:"serverName"`
 	IngressName   string `json:"ingressName"`
-	Image         string `json:"image,omitempty"`
+	Image         string `json:"image,omitempty" validate:"required"`
 	ServiceName   string `json:"serviceName"`
 	Host          string `json:"host"`
 	CustomConfig  bool   `json:"customConfig"`
@@ -134,6 +435,17 @@ type Artifactory struct {
 	Configuration *ArtifactoryConfig `json:"configuration,omitempty"`
 }
 
+// ArtifactoryPatch represents the changes allowed in an existing artifactory,
+// nil fields are not changed
+type ArtifactoryPatch struct {
+	// Image the new image of the server, it must be one of the vendor images
+	Image *string `json:"image,omitempty"`
+	// CustomConfig set to false removes the init configuration,
+	// set to true applies Configuration and runs again the init job when the configuration changes
+	CustomConfig  *bool              `json:"customConfig,omitempty"`
+	Configuration *ArtifactoryConfig `json:"configuration,omitempty"`
+}
+
 // ArtifactoryConfig represents the global configuration to apply in nexus
 type ArtifactoryConfig struct {
 	Users   []ArtifactoryUser   `json:"users,omitempty"`
@@ -145,36 +457,36 @@ type ArtifactoryConfig struct {
 // ArtifactoryUser represents a user in the server
 type ArtifactoryUser struct {
 	// Action the action for this user: CHANGE, CREATE
-	Action      string `json:"action"`
-	Username    string `json:"username"`
+	Action      string `json:"action" validate:"required,oneof=CHANGE CREATE"`
+	Username    string `json:"username" validate:"required"`
 	Password    string `json:"password"`
 	NewPassword string `json:"newpassword"`
 }
 
 // ArtifactoryGroup represents a group repository
 type ArtifactoryGroup struct {
-	Name    string   `json:"name"`
-	Members []string `json:"members"`
+	Name    string   `json:"name" validate:"required"`
+	Members []string `json:"members" validate:"required"`
 }
 
 // ArtifactoryHosted represents a hosted repository
 type ArtifactoryHosted struct {
-	Name string `json:"name"`
+	Name string `json:"name" validate:"required"`
 	// VersionPolicy the options are: RELEASE SNAPSHOT MIXED
-	VersionPolicy string `json:"versionPolicy"`
+	VersionPolicy string `json:"versionPolicy" validate:"required,oneof=RELEASE SNAPSHOT MIXED"`
 	// LayoutPolicy the options are: STRICT PERMISSIVE
-	LayoutPolicy string `json:"layoutPolicy"`
+	LayoutPolicy string `json:"layoutPolicy" validate:"required,oneof=STRICT PERMISSIVE"`
 }
 
 // ArtifactoryProxy represents a proxy repository
 type ArtifactoryProxy struct {
-	Name string `json:"name"`
+	Name string `json:"name" validate:"required"`
 	// VersionPolicy the options are: RELEASE SNAPSHOT MIXED
-	VersionPolicy string `json:"versionPolicy"`
+	VersionPolicy string `json:"versionPolicy" validate:"required,oneof=RELEASE SNAPSHOT MIXED"`
 	// LayoutPolicy the options are: STRICT PERMISSIVE
-	LayoutPolicy string `json:"layoutPolicy"`
+	LayoutPolicy string `json:"layoutPolicy" validate:"required,oneof=STRICT PERMISSIVE"`
 	// RemoteURL is remote url to be proxied
-	RemoteURL string `json:"remoteUrl"`
+	RemoteURL string `json:"remoteUrl" validate:"required,url"`
 	// RequiredAuth set to true if the proxy requires authentication
 	RequiredAuth bool `json:"requiredAuth"`
 	// Authentication is required if RequiredAuth is set to true
@@ -183,8 +495,8 @@ type ArtifactoryProxy struct {
 
 // ArtifactoryAuth is the auth for artifactory proxy repository
 type ArtifactoryAuth struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
 // SCM represents Source Control Manager for example gogs
@@ -192,7 +504,7 @@ type SCM struct {
 	SCMID        string `json:"scmId"`
 	ServerName   string `json:"serverName"`
 	IngressName  string `json:"ingressName"`
-	Image        string `json:"image,omitempty"`
+	Image        string `json:"image,omitempty" validate:"required"`
 	ServiceName  string `json:"serviceName"`
 	Host         string `json:"host"`
 	CustomConfig bool   `json:"customConfig"`
@@ -201,9 +513,20 @@ type SCM struct {
 	Configuration *SCMConfig `json:"configuration,omitempty"`
 }
 
+// SCMPatch represents the changes allowed in an existing scm,
+// nil fields are not changed
+type SCMPatch struct {
+	// Image the new image of the server, it must be one of the vendor images
+	Image *string `json:"image,omitempty"`
+	// CustomConfig set to false removes the init configuration,
+	// set to true applies Configuration and runs again the init job when the configuration changes
+	CustomConfig  *bool      `json:"customConfig,omitempty"`
+	Configuration *SCMConfig `json:"configuration,omitempty"`
+}
+
 // SCMConfig custom configuration for a scm
 type SCMConfig struct {
-	InitData      *SCMInitData      `json:"init_data"`
+	InitData      *SCMInitData      `json:"init_data" validate:"required"`
 	Organizations []SCMOrganization `json:"organizations"`
 	Repositories  []SCMRepository   `json:"repositories"`
 }
@@ -213,32 +536,32 @@ type SCMInitData struct {
 	Domain           string `json:"domain"`
 	HTTPPort         string `json:"http_port"`
 	APPURL           string `json:"app_url"`
-	AdminName        string `json:"admin_name"`
-	AdminPass        string `json:"admin_passwd"`
-	AdminConfirmPass string `json:"admin_confirm_passwd"`
-	AdminEmail       string `json:"admin_email"`
+	AdminName        string `json:"admin_name" validate:"required"`
+	AdminPass        string `json:"admin_passwd" validate:"required"`
+	AdminConfirmPass string `json:"admin_confirm_passwd" validate:"required"`
+	AdminEmail       string `json:"admin_email" validate:"required,email"`
 	RepoRootPath     string `json:"repo_root_path"`
 	LogRootPath      string `json:"log_root_path"`
 }
 
 // SCMOrganization represents an organization in a scm
 type SCMOrganization struct {
-	UserName    string `json:"username"`
+	UserName    string `json:"username" validate:"required"`
 	FullName    string `json:"full_name"`
 	Description string `json:"description"`
-	Website     string `json:"website"`
+	Website     string `json:"website" validate:"url"`
 	Location    string `json:"location"`
 }
 
 // SCMRepository represents a repository in a scm
 type SCMRepository struct {
-	Name        string `json:"name"`
+	Name        string `json:"name" validate:"required"`
 	Description string `json:"description"`
 	Private     bool   `json:"private"`
 	Owner       string `json:"owner"`
 	// ContentSetupType is the initial files for this repository
 	// the options available are: "danta-aem-demo", "ep-commerce", "bloomreach-archetype" and ""
-	ContentSetupType string `json:"content_setup_type"`
+	ContentSetupType string `json:"content_setup_type" validate:"oneof=danta-aem-demo ep-commerce bloomreach-archetype"`
 	// EPObjectType is required when the ContentSetupType is set to "ep-commerce"
 	EPObjectType *EPObjectType `json:"ep_commerce,omitempty"`
 	// BRObjectType is required when the ContentSetupType is set to "bloomreach-archetype"
@@ -248,7 +571,7 @@ type SCMRepository struct {
 // EPObjectType is required when the ContentSetupType is set to "ep-commerce"
 type EPObjectType struct {
 	// ep version. Required (in format 7.1)
-	Version string `json:"version"`
+	Version string `json:"version" validate:"required"`
 	// zip package url from S3. This is generated with a pre-signed url
 	SourceCodeURL string `json:"source_code_url"`
 	// Nexus ep-repository-group URL. Default ""
@@ -263,17 +586,17 @@ type EPObjectType struct {
 // ContentSetupType is set to "bloomreach-archetype"
 type BRObjectType struct {
 	// Bloomreach archetype version. Required (e.g. 12.2.0)
-	ArchetypeVersion string `json:"archetype_version"`
+	ArchetypeVersion string `json:"archetype_version" validate:"required"`
 	// Required (e.g. org.example)
-	GroupID string `json:"group_id"`
+	GroupID string `json:"group_id" validate:"required"`
 	// Required (e.g. myCompany)
-	ArtifactID string `json:"artifact_id"`
+	ArtifactID string `json:"artifact_id" validate:"required"`
 	// Version of the project. Required (e.g. 0.1.0-SNAPSHOT)
-	Version string `json:"version"`
+	Version string `json:"version" validate:"required"`
 	// Project package. Required (e.g. com.example)
-	Package string `json:"package"`
+	Package string `json:"package" validate:"required"`
 	// Required. (e.g. myProject)
-	ProjectName string `json:"project_name"`
+	ProjectName string `json:"project_name" validate:"required"`
 }
 
 // CI represents Continuous Integration Manager, for example drone
@@ -281,11 +604,18 @@ type CI struct {
 	CIID        string `json:"ciId"`
 	ServerName  string `json:"serverName"`
 	IngressName string `json:"ingressName"`
-	Image       string `json:"image,omitempty"`
-	SecondImage string `json:"secondImage,omitempty"`
+	Image       string `json:"image,omitempty" validate:"required"`
+	SecondImage string `json:"secondImage,omitempty" validate:"required"`
 	ServiceName string `json:"serviceName"`
 	Host        string `json:"host"`
-	ScmURL      string `json:"scmURL"`
+	ScmURL      string `json:"scmURL" validate:"required,url"`
+}
+
+// CIPatch represents the changes allowed in an existing ci,
+// nil fields are not changed, the images must be one of the vendor images
+type CIPatch struct {
+	Image       *string `json:"image,omitempty"`
+	SecondImage *string `json:"secondImage,omitempty"`
 }
 
 // Vendor represents a Vendor for the services
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/xumak-grid/bedrock"
+)
+
+// ListArtifactoryVendors returns the artifact managers available and their images
+func (c *Client) ListArtifactoryVendors(ctx context.Context) ([]bedrock.Vendor, error) {
+	return c.listVendors(ctx, "artifactory")
+}
+
+// ListSCMVendors returns the source control managers available and their images
+func (c *Client) ListSCMVendors(ctx context.Context) ([]bedrock.Vendor, error) {
+	return c.listVendors(ctx, "scm")
+}
+
+// ListCIVendors returns the continuous integration managers available and their images
+func (c *Client) ListCIVendors(ctx context.Context) ([]bedrock.Vendor, error) {
+	return c.listVendors(ctx, "ci")
+}
+
+func (c *Client) listVendors(ctx context.Context, kind string) ([]bedrock.Vendor, error) {
+	vendors := []bedrock.Vendor{}
+	_, err := c.do(ctx, http.MethodGet, path("vendors", kind, "list"), nil, &vendors)
+	return vendors, err
+}
+
+// ListAEMImages returns the AEM images available for the deployments
+func (c *Client) ListAEMImages(ctx context.Context) ([]bedrock.Image, error) {
+	images := []bedrock.Image{}
+	_, err := c.do(ctx, http.MethodGet, path("images", "aem", "list"), nil, &images)
+	return images, err
+}
+
+// ListDispatcherImages returns the dispatcher images available for the deployments
+func (c *Client) ListDispatcherImages(ctx context.Context) ([]bedrock.Image, error) {
+	images := []bedrock.Image{}
+	_, err := c.do(ctx, http.MethodGet, path("images", "dispatcher", "list"), nil, &images)
+	return images, err
+}
+
+// ListInstanceTypes returns the types of the AEM and dispatcher instances
+func (c *Client) ListInstanceTypes(ctx context.Context) ([]bedrock.InstanceType, error) {
+	types := []bedrock.InstanceType{}
+	_, err := c.do(ctx, http.MethodGet, path("instances", "type", "list"), nil, &types)
+	return types, err
+}
@@ -0,0 +1,149 @@
+// Package client is the Go client of the bedrock API, it exposes a typed method
+// for every route of the api using the bedrock types.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is the default number of retries of an idempotent request that failed with a 5xx or a network error
+	DefaultMaxRetries = 3
+	// DefaultRetryBackoff is the default wait before the first retry, it is doubled on each retry
+	DefaultRetryBackoff = 200 * time.Millisecond
+)
+
+// Client calls the bedrock API, it is safe for concurrent use
+type Client struct {
+	baseURL *url.URL
+
+	// HTTPClient is the client used to send the requests
+	HTTPClient *http.Client
+	// Token is sent as a bearer token in every request when is not empty
+	Token string
+	// MaxRetries is the number of retries of the GET, HEAD, PUT and DELETE requests that fail with a 5xx
+	// or a network error, the other methods are never retried because they can create resources twice
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+// New returns a client of the api in baseURL, for example https://bedrock.example.com/api/v1
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base url %q", baseURL)
+	}
+	return &Client{
+		baseURL:      u,
+		HTTPClient:   http.DefaultClient,
+		MaxRetries:   DefaultMaxRetries,
+		RetryBackoff: DefaultRetryBackoff,
+	}, nil
+}
+
+// do sends the request with the json of in as body and decodes the response in out,
+// in and out are ignored when they are nil, it returns the status code of the response
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) (int, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+	}
+	backoff := c.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		status, err := c.send(ctx, method, path, body, out)
+		if attempt >= c.MaxRetries || !idempotent(method) || !retryable(err) {
+			return status, err
+		}
+		select {
+		case <-ctx.Done():
+			return status, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, body []byte, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.baseURL.String()+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp.StatusCode, responseError(resp)
+	}
+	if out == nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("invalid response of %v %v: %v", method, path, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// idempotent returns true for the methods that can be sent again without changing the result
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable returns true for the 5xx responses and the errors sending the request,
+// the cancellation of the context is never retried
+func retryable(err error) bool {
+	if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	if e, ok := err.(*Error); ok {
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	if e, ok := err.(*url.Error); ok {
+		return e.Err != context.Canceled && e.Err != context.DeadlineExceeded
+	}
+	return false
+}
+
+// path joins the segments escaping them, for example path("clients", "acme") is /clients/acme
+func path(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, s := range segments {
+		escaped[i] = url.PathEscape(s)
+	}
+	return "/" + strings.Join(escaped, "/")
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	certfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	aemfake "github.com/xumak-grid/aem-operator/pkg/generated/clientset/versioned/fake"
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/auth"
+	bedrockhttp "github.com/xumak-grid/bedrock/http"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestClient returns a client of an api server that uses fake kubernetes clientsets
+func newTestClient(t *testing.T) (*Client, func()) {
+	server := bedrockhttp.NewServer(nil, &bedrockhttp.Dependencies{
+		KubeClient:        fake.NewSimpleClientset(),
+		AEMClient:         aemfake.NewSimpleClientset(),
+		CertManagerClient: certfake.NewSimpleClientset(),
+	})
+	server.Auth = auth.Anonymous()
+	ts := httptest.NewServer(server.Handler())
+	c, err := New(ts.URL + "/api/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, ts.Close
+}
+
+func TestClients(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+	ctx := context.Background()
+
+	result, err := c.CreateClient(ctx, bedrock.Client{ClientID: "acme", MetaData: map[string]string{"team": "web"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Client == nil || result.Client.ClientID != "acme" {
+		t.Fatalf("expected the client acme, got %+v", result)
+	}
+	client, err := c.GetClient(ctx, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.MetaData["team"] != "web" {
+		t.Errorf("expected the metadata of the client, got %v", client.MetaData)
+	}
+	tier := "gold"
+	client, err = c.UpdateClient(ctx, "acme", bedrock.ClientPatch{MetaData: map[string]*string{"tier": &tier}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.MetaData["tier"] != "gold" || client.MetaData["team"] != "web" {
+		t.Errorf("expected the tier added to the metadata, got %v", client.MetaData)
+	}
+	clients, err := c.ListClients(ctx, ClientQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clients) != 1 {
+		t.Errorf("expected 1 client, got %v", clients)
+	}
+	clients, err = c.ListClients(ctx, ClientQuery{Selector: "tier=silver", Status: []string{bedrock.ClientActive}, Sort: "-createdAt", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clients) != 0 {
+		t.Errorf("expected no clients with the tier silver, got %v", clients)
+	}
+
+	_, err = c.GetArtifactory(ctx, "unknown", "nexus")
+	if !IsNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	_, err = c.CreateClient(ctx, bedrock.Client{ClientID: "Acme Inc"})
+	if !IsValidation(err) || len(err.(*Error).Details) != 1 || err.(*Error).Details[0].Field != "clientId" {
+		t.Errorf("expected a validation error of clientId, got %v", err)
+	}
+
+	_, err = c.DeleteClient(ctx, "acme", DeleteOptions{})
+	if !IsConflict(err) {
+		t.Errorf("expected a conflict error deleting a protected client, got %v", err)
+	}
+	_, err = c.UnprotectClient(ctx, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gracePeriod := time.Hour
+	deletion, err := c.DeleteClient(ctx, "acme", DeleteOptions{GracePeriod: &gracePeriod})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deletion.Status != bedrock.DeletionPending || deletion.DeleteAt.Sub(deletion.RequestedAt) != gracePeriod {
+		t.Errorf("expected a pending deletion in an hour, got %+v", deletion)
+	}
+	deletion, err = c.CancelDeletion(ctx, "acme")
+	if err != nil || deletion.Status != bedrock.DeletionCancelled {
+		t.Errorf("expected the deletion cancelled, got %+v %v", deletion, err)
+	}
+	_, err = c.GetDeletion(ctx, "acme")
+	if !IsNotFound(err) {
+		t.Errorf("expected a not found error after cancelling the deletion, got %v", err)
+	}
+}
+
+func TestArtifactory(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+	ctx := context.Background()
+
+	_, err := c.CreateClient(ctx, bedrock.Client{ClientID: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vendors, err := c.ListArtifactoryVendors(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vendors) == 0 || len(vendors[0].Images) == 0 {
+		t.Fatalf("expected the artifactory vendors, got %v", vendors)
+	}
+	created, err := c.CreateArtifactory(ctx, "acme", bedrock.Artifactory{
+		ArtifactoryID: vendors[0].Name,
+		Image:         vendors[0].Images[0].Name,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifactory, err := c.GetArtifactory(ctx, "acme", vendors[0].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if artifactory.ServerName != created.ServerName || artifactory.Image != created.Image {
+		t.Errorf("expected %+v, got %+v", created, artifactory)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch {
+		case r.URL.Path == "/api/v1/operations/invalid":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code": 400, "reason": "Validation", "msg": "invalid operation"}`))
+		case attempts < 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("upstream connect error"))
+		default:
+			w.Write([]byte(`{"operationId": "op-1", "status": "running"}`))
+		}
+	}))
+	defer ts.Close()
+	c, err := New(ts.URL + "/api/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.RetryBackoff = time.Millisecond
+
+	op, err := c.GetOperation(context.Background(), "op-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.OperationID != "op-1" || attempts != 3 {
+		t.Errorf("expected op-1 after 3 attempts, got %+v after %v", op, attempts)
+	}
+
+	attempts = 0
+	_, err = c.GetOperation(context.Background(), "invalid")
+	if !IsValidation(err) || attempts != 1 {
+		t.Errorf("expected a validation error without retries, got %v after %v attempts", err, attempts)
+	}
+
+	attempts = 0
+	c.MaxRetries = 1
+	_, err = c.GetOperation(context.Background(), "op-1")
+	if !IsUpstreamFailure(err) || attempts != 2 {
+		t.Errorf("expected an upstream failure after 2 attempts, got %v after %v", err, attempts)
+	}
+
+	attempts = 0
+	_, err = c.CreateClient(context.Background(), bedrock.Client{ClientID: "acme"})
+	if !IsUpstreamFailure(err) || attempts != 1 {
+		t.Errorf("expected a post without retries, got %v after %v attempts", err, attempts)
+	}
+}
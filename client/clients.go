@@ -0,0 +1,215 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xumak-grid/bedrock"
+)
+
+// CreateClientResult is the response of CreateClient, only one of the fields is set:
+// Client when the request has no customConfig, Operation of the full deploy running
+// in background with customConfig and FullDeploy with dryRun
+type CreateClientResult struct {
+	Client     *bedrock.Client
+	Operation  *bedrock.Operation
+	FullDeploy *bedrock.FullDeploy
+}
+
+// ClientQuery filters, sorts and paginates the clients returned by ListClients, the zero values are ignored
+type ClientQuery struct {
+	// Selector is a label selector of the metadata, for example team=web,tier in (gold,silver)
+	Selector string
+	// Status are the statuses of the clients, for example bedrock.ClientSuspended
+	Status        []string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Sort is clientId, createdAt or status, a - prefix sorts in descending order
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+// ListClients returns the clients accessible with the token
+func (c *Client) ListClients(ctx context.Context, q ClientQuery) ([]bedrock.Client, error) {
+	params := url.Values{}
+	if q.Selector != "" {
+		params.Set("selector", q.Selector)
+	}
+	if len(q.Status) > 0 {
+		params.Set("status", strings.Join(q.Status, ","))
+	}
+	if !q.CreatedAfter.IsZero() {
+		params.Set("createdAfter", q.CreatedAfter.UTC().Format(time.RFC3339))
+	}
+	if !q.CreatedBefore.IsZero() {
+		params.Set("createdBefore", q.CreatedBefore.UTC().Format(time.RFC3339))
+	}
+	if q.Sort != "" {
+		params.Set("sort", q.Sort)
+	}
+	if q.Limit > 0 {
+		params.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Offset > 0 {
+		params.Set("offset", strconv.Itoa(q.Offset))
+	}
+	p := path("clients")
+	if len(params) > 0 {
+		p += "?" + params.Encode()
+	}
+	clients := []bedrock.Client{}
+	_, err := c.do(ctx, http.MethodGet, p, nil, &clients)
+	return clients, err
+}
+
+// CreateClient creates a client, with customConfig the full deploy is started and tracked by the returned operation
+func (c *Client) CreateClient(ctx context.Context, client bedrock.Client) (*CreateClientResult, error) {
+	result := &CreateClientResult{}
+	var out interface{}
+	switch {
+	case client.DryRun:
+		result.FullDeploy = &bedrock.FullDeploy{}
+		out = result.FullDeploy
+	case client.CustomConfig:
+		result.Operation = &bedrock.Operation{}
+		out = result.Operation
+	default:
+		result.Client = &bedrock.Client{}
+		out = result.Client
+	}
+	_, err := c.do(ctx, http.MethodPost, path("clients"), client, out)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetClient returns a client
+func (c *Client) GetClient(ctx context.Context, clientID string) (bedrock.Client, error) {
+	client := bedrock.Client{}
+	_, err := c.do(ctx, http.MethodGet, path("clients", clientID), nil, &client)
+	return client, err
+}
+
+// GetClientSummary returns a client with the state of all its stacks
+func (c *Client) GetClientSummary(ctx context.Context, clientID string) (bedrock.ClientSummary, error) {
+	summary := bedrock.ClientSummary{}
+	_, err := c.do(ctx, http.MethodGet, path("clients", clientID, "summary"), nil, &summary)
+	return summary, err
+}
+
+// UpdateClient changes the metadata of a client, the keys with a nil value are removed
+func (c *Client) UpdateClient(ctx context.Context, clientID string, patch bedrock.ClientPatch) (bedrock.Client, error) {
+	client := bedrock.Client{}
+	_, err := c.do(ctx, http.MethodPatch, path("clients", clientID), patch, &client)
+	return client, err
+}
+
+// DeleteOptions are the options of DeleteClient, the zero values are the defaults of the api
+type DeleteOptions struct {
+	// GracePeriod is the time before the deletion, 24h by default
+	GracePeriod *time.Duration
+	// Export uploads the scm repositories and the artifactory blobs to S3 before the deletion
+	Export bool
+}
+
+// DeleteClient schedules the deletion of a client and all its resources after the grace period,
+// the protected clients return a conflict error
+func (c *Client) DeleteClient(ctx context.Context, clientID string, opts DeleteOptions) (bedrock.ClientDeletion, error) {
+	params := url.Values{}
+	if opts.GracePeriod != nil {
+		params.Set("gracePeriod", opts.GracePeriod.String())
+	}
+	if opts.Export {
+		params.Set("export", "true")
+	}
+	p := path("clients", clientID)
+	if len(params) > 0 {
+		p += "?" + params.Encode()
+	}
+	deletion := bedrock.ClientDeletion{}
+	_, err := c.do(ctx, http.MethodDelete, p, nil, &deletion)
+	return deletion, err
+}
+
+// GetDeletion returns the deletion of a client scheduled for deletion
+func (c *Client) GetDeletion(ctx context.Context, clientID string) (bedrock.ClientDeletion, error) {
+	deletion := bedrock.ClientDeletion{}
+	_, err := c.do(ctx, http.MethodGet, path("clients", clientID, "deletion"), nil, &deletion)
+	return deletion, err
+}
+
+// CancelDeletion cancels the deletion of a client
+func (c *Client) CancelDeletion(ctx context.Context, clientID string) (bedrock.ClientDeletion, error) {
+	deletion := bedrock.ClientDeletion{}
+	_, err := c.do(ctx, http.MethodDelete, path("clients", clientID, "deletion"), nil, &deletion)
+	return deletion, err
+}
+
+// ProtectClient protects a client against the deletion
+func (c *Client) ProtectClient(ctx context.Context, clientID string) (bedrock.Client, error) {
+	client := bedrock.Client{}
+	_, err := c.do(ctx, http.MethodPost, path("clients", clientID, "protect"), nil, &client)
+	return client, err
+}
+
+// UnprotectClient removes the protection of a client, it can be deleted after
+func (c *Client) UnprotectClient(ctx context.Context, clientID string) (bedrock.Client, error) {
+	client := bedrock.Client{}
+	_, err := c.do(ctx, http.MethodPost, path("clients", clientID, "unprotect"), nil, &client)
+	return client, err
+}
+
+// SuspendClient scales to zero the AEM deployments and the stack servers of a client
+func (c *Client) SuspendClient(ctx context.Context, clientID string) (bedrock.ClientSuspension, error) {
+	suspension := bedrock.ClientSuspension{}
+	_, err := c.do(ctx, http.MethodPost, path("clients", clientID, "suspend"), nil, &suspension)
+	return suspension, err
+}
+
+// ResumeClient restores the replicas that a suspended client had before the suspension
+func (c *Client) ResumeClient(ctx context.Context, clientID string) (bedrock.ClientSuspension, error) {
+	suspension := bedrock.ClientSuspension{}
+	_, err := c.do(ctx, http.MethodPost, path("clients", clientID, "resume"), nil, &suspension)
+	return suspension, err
+}
+
+// AuditQuery filters the audit records returned by ListAudit, the zero values are ignored
+type AuditQuery struct {
+	EnvironmentID string
+	Since         time.Time
+	Limit         int
+}
+
+// ListAudit returns the audit records of a client in chronological order
+func (c *Client) ListAudit(ctx context.Context, clientID string, q AuditQuery) ([]bedrock.AuditRecord, error) {
+	params := url.Values{}
+	if q.EnvironmentID != "" {
+		params.Set("environmentId", q.EnvironmentID)
+	}
+	if !q.Since.IsZero() {
+		params.Set("since", q.Since.UTC().Format(time.RFC3339))
+	}
+	if q.Limit > 0 {
+		params.Set("limit", strconv.Itoa(q.Limit))
+	}
+	p := path("clients", clientID, "audit")
+	if len(params) > 0 {
+		p += "?" + params.Encode()
+	}
+	records := []bedrock.AuditRecord{}
+	_, err := c.do(ctx, http.MethodGet, p, nil, &records)
+	return records, err
+}
+
+// GetOperation returns the status of an operation, for example the full deploy started by CreateClient
+func (c *Client) GetOperation(ctx context.Context, operationID string) (bedrock.Operation, error) {
+	op := bedrock.Operation{}
+	_, err := c.do(ctx, http.MethodGet, path("operations", operationID), nil, &op)
+	return op, err
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/xumak-grid/bedrock"
+)
+
+// aemPath returns the path of the AEM deployment of an environment
+func aemPath(clientID, environmentID string, segments ...string) string {
+	return path(append([]string{"clients", clientID, "environments", environmentID, "aem"}, segments...)...)
+}
+
+// ListEnvironments returns the environments of a client
+func (c *Client) ListEnvironments(ctx context.Context, clientID string) ([]bedrock.Environment, error) {
+	envs := []bedrock.Environment{}
+	_, err := c.do(ctx, http.MethodGet, path("clients", clientID, "environments"), nil, &envs)
+	return envs, err
+}
+
+// GetAEMDeployment returns the AEM deployment of an environment
+func (c *Client) GetAEMDeployment(ctx context.Context, clientID, environmentID string) (bedrock.AEMDeployment, error) {
+	d := bedrock.AEMDeployment{}
+	_, err := c.do(ctx, http.MethodGet, aemPath(clientID, environmentID), nil, &d)
+	return d, err
+}
+
+// CreateAEMDeployment creates the AEM deployment of deploy.ClientID in the environment deploy.EnvironmentID
+func (c *Client) CreateAEMDeployment(ctx context.Context, deploy bedrock.AEMDeployment) (bedrock.AEMDeployment, error) {
+	d := bedrock.AEMDeployment{}
+	_, err := c.do(ctx, http.MethodPost, aemPath(deploy.ClientID, deploy.EnvironmentID), deploy, &d)
+	return d, err
+}
+
+// UpdateAEMDeployment updates the spec of the AEM deployment of deploy.ClientID in the environment deploy.EnvironmentID
+func (c *Client) UpdateAEMDeployment(ctx context.Context, deploy bedrock.AEMDeployment) (bedrock.AEMDeployment, error) {
+	d := bedrock.AEMDeployment{}
+	_, err := c.do(ctx, http.MethodPatch, aemPath(deploy.ClientID, deploy.EnvironmentID), deploy, &d)
+	return d, err
+}
+
+// DeleteAEMDeployment deletes the AEM deployment of an environment
+func (c *Client) DeleteAEMDeployment(ctx context.Context, clientID, environmentID string) error {
+	_, err := c.do(ctx, http.MethodDelete, aemPath(clientID, environmentID), nil, nil)
+	return err
+}
+
+// ListInstances returns the AEM instances of an environment
+func (c *Client) ListInstances(ctx context.Context, clientID, environmentID string) ([]bedrock.Instance, error) {
+	instances := []bedrock.Instance{}
+	_, err := c.do(ctx, http.MethodGet, aemPath(clientID, environmentID, "instances"), nil, &instances)
+	return instances, err
+}
+
+// RevealPassword returns the admin password of an AEM instance, the caller requires the secrets-admin role,
+// when wrapTTL is positive the password is replaced by a vault wrapping token that expires after wrapTTL
+func (c *Client) RevealPassword(ctx context.Context, clientID, environmentID, instance string, wrapTTL time.Duration) (bedrock.InstanceCredentials, error) {
+	creds := bedrock.InstanceCredentials{}
+	p := aemPath(clientID, environmentID, "instances", instance, "password")
+	if wrapTTL > 0 {
+		p += "?wrapTTL=" + url.QueryEscape(wrapTTL.String())
+	}
+	_, err := c.do(ctx, http.MethodGet, p, nil, &creds)
+	return creds, err
+}
+
+// RotatePasswords generates a new admin password for all the AEM instances of an environment
+func (c *Client) RotatePasswords(ctx context.Context, clientID, environmentID string) (bedrock.PasswordRotation, error) {
+	rotation := bedrock.PasswordRotation{}
+	_, err := c.do(ctx, http.MethodPost, aemPath(clientID, environmentID, "passwords", "rotate"), nil, &rotation)
+	return rotation, err
+}
+
+// RotateInstancePassword generates a new admin password for an AEM instance
+func (c *Client) RotateInstancePassword(ctx context.Context, clientID, environmentID, instance string) (bedrock.PasswordRotation, error) {
+	rotation := bedrock.PasswordRotation{}
+	_, err := c.do(ctx, http.MethodPost, aemPath(clientID, environmentID, "instances", instance, "password", "rotate"), nil, &rotation)
+	return rotation, err
+}
+
+// GetDispatcherConfig returns the configMap of the dispatcher of an environment
+func (c *Client) GetDispatcherConfig(ctx context.Context, clientID, environmentID string) (bedrock.ConfigMap, error) {
+	cm := bedrock.ConfigMap{}
+	_, err := c.do(ctx, http.MethodGet, aemPath(clientID, environmentID, "dispatcherconfig"), nil, &cm)
+	return cm, err
+}
+
+// UpdateDispatcherConfig replaces the data of the configMap config.Name of the dispatcher
+// of config.ClientID in the environment config.EnvironmentID
+func (c *Client) UpdateDispatcherConfig(ctx context.Context, config bedrock.ConfigMap) (bedrock.ConfigMap, error) {
+	cm := bedrock.ConfigMap{}
+	_, err := c.do(ctx, http.MethodPatch, aemPath(config.ClientID, config.EnvironmentID, "dispatcherconfig"), config, &cm)
+	return cm, err
+}
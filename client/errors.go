@@ -0,0 +1,95 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/xumak-grid/bedrock"
+)
+
+// Error is an error response of the api
+type Error struct {
+	StatusCode int
+	// Reason is one of the bedrock.Reason* values
+	Reason  string
+	Message string
+	// Details are the invalid fields of a validation error
+	Details []bedrock.FieldError
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("bedrock: %v %v: %v", e.StatusCode, e.Reason, e.Message)
+	for _, d := range e.Details {
+		msg += fmt.Sprintf("; %v: %v", d.Field, d.Message)
+	}
+	return msg
+}
+
+// errorBody is the json body of the error responses
+type errorBody struct {
+	Code    int                  `json:"code"`
+	Reason  string               `json:"reason"`
+	Msg     string               `json:"msg"`
+	Details []bedrock.FieldError `json:"details"`
+}
+
+// responseError returns the Error of a response, the responses without a json body
+// like the ones of a proxy use the status as reason
+func responseError(resp *http.Response) error {
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	e := &Error{StatusCode: resp.StatusCode}
+	body := errorBody{}
+	if json.Unmarshal(data, &body) == nil && body.Msg != "" {
+		e.Reason, e.Message, e.Details = body.Reason, body.Msg, body.Details
+	} else {
+		e.Message = strings.TrimSpace(string(data))
+	}
+	if e.Reason == "" {
+		e.Reason = bedrock.StatusReason(resp.StatusCode)
+	}
+	return e
+}
+
+// Reason returns the reason of an api error, empty when err is not an api error
+func Reason(err error) string {
+	if e, ok := err.(*Error); ok {
+		return e.Reason
+	}
+	return ""
+}
+
+// IsNotFound returns true when the resource does not exist
+func IsNotFound(err error) bool {
+	return Reason(err) == bedrock.ReasonNotFound
+}
+
+// IsConflict returns true when the resource conflicts with an existing one
+func IsConflict(err error) bool {
+	return Reason(err) == bedrock.ReasonConflict
+}
+
+// IsValidation returns true when the request is invalid, the invalid fields are in the Details of the error
+func IsValidation(err error) bool {
+	return Reason(err) == bedrock.ReasonValidation
+}
+
+// IsUnauthorized returns true when the token is missing or invalid
+func IsUnauthorized(err error) bool {
+	return Reason(err) == bedrock.ReasonUnauthorized
+}
+
+// IsForbidden returns true when the token does not grant access to the resource
+func IsForbidden(err error) bool {
+	return Reason(err) == bedrock.ReasonForbidden
+}
+
+// IsUpstreamFailure returns true when a dependency of the api failed, for example kubernetes or vault
+func IsUpstreamFailure(err error) bool {
+	return Reason(err) == bedrock.ReasonUpstreamFailure
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/xumak-grid/bedrock"
+)
+
+// CreateArtifactory creates the artifact manager of a client, it is updated to the desired state when it already exists
+func (c *Client) CreateArtifactory(ctx context.Context, clientID string, artifactory bedrock.Artifactory) (bedrock.Artifactory, error) {
+	a := bedrock.Artifactory{}
+	_, err := c.do(ctx, http.MethodPost, path("clients", clientID, "artifactory"), artifactory, &a)
+	return a, err
+}
+
+// GetArtifactory returns the artifact manager of a client
+func (c *Client) GetArtifactory(ctx context.Context, clientID, artifactoryID string) (bedrock.Artifactory, error) {
+	a := bedrock.Artifactory{}
+	_, err := c.do(ctx, http.MethodGet, path("clients", clientID, "artifactory", artifactoryID), nil, &a)
+	return a, err
+}
+
+// UpdateArtifactory applies the patch to the artifact manager of a client
+func (c *Client) UpdateArtifactory(ctx context.Context, clientID, artifactoryID string, patch bedrock.ArtifactoryPatch) (bedrock.Artifactory, error) {
+	a := bedrock.Artifactory{}
+	_, err := c.do(ctx, http.MethodPatch, path("clients", clientID, "artifactory", artifactoryID), patch, &a)
+	return a, err
+}
+
+// DeleteArtifactory deletes the artifact manager of a client
+func (c *Client) DeleteArtifactory(ctx context.Context, clientID, artifactoryID string) error {
+	_, err := c.do(ctx, http.MethodDelete, path("clients", clientID, "artifactory", artifactoryID), nil, nil)
+	return err
+}
+
+// CreateSCM creates the source control manager of a client, it is updated to the desired state when it already exists
+func (c *Client) CreateSCM(ctx context.Context, clientID string, scm bedrock.SCM) (bedrock.SCM, error) {
+	s := bedrock.SCM{}
+	_, err := c.do(ctx, http.MethodPost, path("clients", clientID, "scm"), scm, &s)
+	return s, err
+}
+
+// GetSCM returns the source control manager of a client
+func (c *Client) GetSCM(ctx context.Context, clientID, scmID string) (bedrock.SCM, error) {
+	s := bedrock.SCM{}
+	_, err := c.do(ctx, http.MethodGet, path("clients", clientID, "scm", scmID), nil, &s)
+	return s, err
+}
+
+// UpdateSCM applies the patch to the source control manager of a client
+func (c *Client) UpdateSCM(ctx context.Context, clientID, scmID string, patch bedrock.SCMPatch) (bedrock.SCM, error) {
+	s := bedrock.SCM{}
+	_, err := c.do(ctx, http.MethodPatch, path("clients", clientID, "scm", scmID), patch, &s)
+	return s, err
+}
+
+// DeleteSCM deletes the source control manager of a client
+func (c *Client) DeleteSCM(ctx context.Context, clientID, scmID string) error {
+	_, err := c.do(ctx, http.MethodDelete, path("clients", clientID, "scm", scmID), nil, nil)
+	return err
+}
+
+// CreateCI creates the continuous integration manager of a client, it is updated to the desired state when it already exists
+func (c *Client) CreateCI(ctx context.Context, clientID string, ci bedrock.CI) (bedrock.CI, error) {
+	i := bedrock.CI{}
+	_, err := c.do(ctx, http.MethodPost, path("clients", clientID, "ci"), ci, &i)
+	return i, err
+}
+
+// GetCI returns the continuous integration manager of a client
+func (c *Client) GetCI(ctx context.Context, clientID, ciID string) (bedrock.CI, error) {
+	i := bedrock.CI{}
+	_, err := c.do(ctx, http.MethodGet, path("clients", clientID, "ci", ciID), nil, &i)
+	return i, err
+}
+
+// UpdateCI applies the patch to the continuous integration manager of a client
+func (c *Client) UpdateCI(ctx context.Context, clientID, ciID string, patch bedrock.CIPatch) (bedrock.CI, error) {
+	i := bedrock.CI{}
+	_, err := c.do(ctx, http.MethodPatch, path("clients", clientID, "ci", ciID), patch, &i)
+	return i, err
+}
+
+// DeleteCI deletes the continuous integration manager of a client
+func (c *Client) DeleteCI(ctx context.Context, clientID, ciID string) error {
+	_, err := c.do(ctx, http.MethodDelete, path("clients", clientID, "ci", ciID), nil, nil)
+	return err
+}
+
+// GetToolbelt returns the toolbelt box of a client
+func (c *Client) GetToolbelt(ctx context.Context, clientID string) (bedrock.Toolbelt, error) {
+	tb := bedrock.Toolbelt{}
+	_, err := c.do(ctx, http.MethodGet, path("clients", clientID, "tools", "toolbelt"), nil, &tb)
+	return tb, err
+}
+
+// CreateToolbelt creates the toolbelt box of a client
+func (c *Client) CreateToolbelt(ctx context.Context, clientID string) (bedrock.Toolbelt, error) {
+	tb := bedrock.Toolbelt{}
+	_, err := c.do(ctx, http.MethodPost, path("clients", clientID, "tools", "toolbelt"), nil, &tb)
+	return tb, err
+}
+
+// DeleteToolbelt deletes the toolbelt box of a client
+func (c *Client) DeleteToolbelt(ctx context.Context, clientID string) error {
+	_, err := c.do(ctx, http.MethodDelete, path("clients", clientID, "tools", "toolbelt"), nil, nil)
+	return err
+}
@@ -1,27 +1,206 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/xumak-grid/bedrock/audit"
+	"github.com/xumak-grid/bedrock/auth"
 	"github.com/xumak-grid/bedrock/http"
+	"github.com/xumak-grid/bedrock/secrets"
+	"github.com/xumak-grid/bedrock/secrets/file"
+	"github.com/xumak-grid/bedrock/secrets/kube"
+	"github.com/xumak-grid/bedrock/secrets/memory"
+	"github.com/xumak-grid/bedrock/secrets/vault"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// kubeConfigCheckInterval is the time between two checks of the KUBECONFIG file
+	kubeConfigCheckInterval = 30 * time.Second
+	// defaultShutdownTimeout is the deadline to drain the requests and the operations on SIGTERM,
+	// the full deploys still running are interrupted and rolled back
+	defaultShutdownTimeout = 60 * time.Second
+	// deletionCheckInterval is the time between two checks of the clients scheduled for deletion
+	deletionCheckInterval = time.Minute
 )
 
 func main() {
 	log := logrus.New()
 	checkEnvVar(log)
-	server := http.NewServer(log)
-	server.Open()
+	deps, err := http.LoadDependencies()
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := http.NewServer(log, deps)
+	deps.Secrets = secretService(log, server)
+	server.Auth = authenticator(log)
+	server.Audit = auditLog(log, server)
+	if kubeconfig := os.Getenv("KUBECONFIG"); kubeconfig != "" {
+		go server.WatchKubeConfig(kubeconfig, kubeConfigCheckInterval)
+	}
+	go server.FinalizeDeletions(deletionCheckInterval)
+	server.ReadTimeout = durationEnv(log, "BEDROCK_READ_TIMEOUT", server.ReadTimeout)
+	server.WriteTimeout = durationEnv(log, "BEDROCK_WRITE_TIMEOUT", server.WriteTimeout)
+	server.IdleTimeout = durationEnv(log, "BEDROCK_IDLE_TIMEOUT", server.IdleTimeout)
+	server.ShutdownDelay = durationEnv(log, "BEDROCK_SHUTDOWN_DELAY", server.ShutdownDelay)
+	shutdownTimeout := durationEnv(log, "BEDROCK_SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
+	server.ValidateRequests = os.Getenv("BEDROCK_VALIDATE_REQUESTS") == "true"
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.Open()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	select {
+	case err = <-errc:
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	case sig := <-signals:
+		log.WithField("signal", sig).Info("Signal received")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = server.Shutdown(ctx)
+	if err != nil {
+		log.WithError(err).Error("Graceful shutdown incomplete")
+		os.Exit(1)
+	}
 }
 
-// checkEnvVar checks critical environment variables and exits if one is not present
-func checkEnvVar(log *logrus.Logger) {
-	if os.Getenv("VAULT_ADDR") == "" {
-		log.Fatalf("VAULT_ADDR is not set and is required")
+// durationEnv returns the duration in the envVar name or def when is not set
+func durationEnv(log *logrus.Logger, name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("%v is not a valid duration: %v", name, err)
+	}
+	return d
+}
+
+// authenticator returns the authenticator configured by BEDROCK_AUTH_CONFIG
+// authentication can be disabled only explicitly with BEDROCK_AUTH_DISABLED=true
+func authenticator(log *logrus.Logger) auth.Authenticator {
+	if os.Getenv("BEDROCK_AUTH_DISABLED") == "true" {
+		log.Warn("authentication is disabled, every request has admin access")
+		return auth.Anonymous()
+	}
+	path := os.Getenv("BEDROCK_AUTH_CONFIG")
+	if path == "" {
+		log.Fatalf("BEDROCK_AUTH_CONFIG is not set and is required")
+	}
+	cfg, err := auth.LoadConfig(path)
+	if err != nil {
+		log.Fatalf("error loading the auth config: %v", err)
+	}
+	a, err := auth.New(cfg)
+	if err != nil {
+		log.Fatalf("error creating the authenticator: %v", err)
+	}
+	return a
+}
+
+// secretService returns the secret service selected by BEDROCK_SECRETS_BACKEND: vault (default),
+// kubernetes for the clusters without vault, file for single node clusters or memory for local development
+func secretService(log *logrus.Logger, server *http.Server) secrets.SecretService {
+	backend := os.Getenv("BEDROCK_SECRETS_BACKEND")
+	switch backend {
+	case "", "vault":
+		if os.Getenv("VAULT_ADDR") == "" {
+			log.Fatalf("VAULT_ADDR is not set and is required")
+		}
+		config, err := vault.ConfigFromEnv()
+		if err != nil {
+			log.Fatalf("invalid vault config: %v", err)
+		}
+		config.Log = log
+		ss, err := vault.NewSecretService(config)
+		if err != nil {
+			log.Fatalf("error creating the vault client: %v", err)
+		}
+		return ss
+	case "kubernetes":
+		// the secrets follow the clients reloaded from the kubeconfig
+		return kube.NewSecretService(func() kubernetes.Interface {
+			return server.Dependencies().KubeClient
+		})
+	case "file":
+		path := os.Getenv("BEDROCK_SECRETS_FILE")
+		if path == "" {
+			log.Fatalf("BEDROCK_SECRETS_FILE is not set and is required by the file backend")
+		}
+		key, err := file.ParseKey(os.Getenv("BEDROCK_SECRETS_KEY"))
+		if err != nil {
+			log.Fatalf("BEDROCK_SECRETS_KEY is not valid: %v", err)
+		}
+		ss, err := file.NewSecretService(path, key)
+		if err != nil {
+			log.Fatalf("error opening the secrets file: %v", err)
+		}
+		return ss
+	case "memory":
+		log.Warn("the secrets are stored in memory and are lost on restart")
+		return memory.NewSecretService()
 	}
-	if os.Getenv("VAULT_TOKEN") == "" {
-		log.Fatalf("VAULT_TOKEN is not set and is required")
+	log.Fatalf("unknown BEDROCK_SECRETS_BACKEND %v, use vault, kubernetes, file or memory", backend)
+	return nil
+}
+
+// auditLog returns the audit log with the sinks in the comma separated list BEDROCK_AUDIT_SINKS:
+// memory (default), file, events and webhook, the queries use the first file or memory sink
+// because the events expire
+func auditLog(log *logrus.Logger, server *http.Server) *audit.Log {
+	names := os.Getenv("BEDROCK_AUDIT_SINKS")
+	if names == "" {
+		names = "memory"
 	}
+	sinks := []audit.Sink{}
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "memory":
+			sinks = append(sinks, audit.NewMemorySink(audit.DefaultMemoryRecords))
+		case "file":
+			path := os.Getenv("BEDROCK_AUDIT_FILE")
+			if path == "" {
+				log.Fatalf("BEDROCK_AUDIT_FILE is not set and is required by the file sink")
+			}
+			sink, err := audit.NewFileSink(path)
+			if err != nil {
+				log.Fatalf("error opening the audit file: %v", err)
+			}
+			sinks = append(sinks, sink)
+		case "events":
+			sinks = append(sinks, audit.NewEventSink(func() kubernetes.Interface {
+				return server.Dependencies().KubeClient
+			}))
+		case "webhook":
+			url := os.Getenv("BEDROCK_AUDIT_WEBHOOK_URL")
+			if url == "" {
+				log.Fatalf("BEDROCK_AUDIT_WEBHOOK_URL is not set and is required by the webhook sink")
+			}
+			sinks = append(sinks, audit.NewWebhookSink(log, url))
+		default:
+			log.Fatalf("unknown audit sink %v, use memory, file, events or webhook", name)
+		}
+	}
+	return audit.New(log, sinks...)
+}
+
+// checkEnvVar checks critical environment variables and exits if one is not present
+func checkEnvVar(log *logrus.Logger) {
 	if os.Getenv("GRID_EXTERNAL_DOMAIN") == "" {
 		log.Fatalf("GRID_EXTERNAL_DOMAIN is not set and is required")
 	}
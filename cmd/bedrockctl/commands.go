@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/client"
+)
+
+// commands are the subcommands in the order of the usage
+var commands = []command{
+	{name: "clients list", help: "list the clients", run: listClients, flags: listClientsFlags},
+	{name: "clients get", args: "CLIENT", help: "show a client", run: getClient},
+	{name: "clients summary", args: "CLIENT", help: "show a client with its environments, stack servers, certificate and toolbelt", run: getClientSummary},
+	{name: "clients update", args: "CLIENT", help: "set or remove the metadata of a client", run: updateClient, flags: updateClientFlags},
+	{name: "clients create", help: "create a client from a bedrock.Client manifest, with customConfig the full deploy is started", run: createClient, flags: manifestFlags},
+	{name: "clients delete", args: "CLIENT", help: "schedule the deletion of a client and all its resources, the client must be unprotected", run: deleteClient, flags: deleteFlags},
+	{name: "clients deletion", args: "CLIENT", help: "show the deletion of a client and its exports", run: getDeletion},
+	{name: "clients cancel-deletion", args: "CLIENT", help: "cancel the deletion of a client", run: cancelDeletion},
+	{name: "clients protect", args: "CLIENT", help: "protect a client against the deletion", run: protectClient},
+	{name: "clients unprotect", args: "CLIENT", help: "remove the protection of a client against the deletion", run: unprotectClient},
+	{name: "clients suspend", args: "CLIENT", help: "scale to zero the AEM deployments and the stack servers of a client", run: suspendClient},
+	{name: "clients resume", args: "CLIENT", help: "restore the replicas of a suspended client", run: resumeClient},
+	{name: "operations get", args: "OPERATION", help: "show the status of an operation", run: getOperation, flags: waitFlags},
+	{name: "audit list", args: "CLIENT", help: "list the audit records of the requests that changed the resources of a client", run: listAudit, flags: auditFlags},
+
+	{name: "env list", args: "CLIENT", help: "list the environments of a client", run: listEnvironments},
+	{name: "env get", args: "CLIENT ENVIRONMENT", help: "show the AEM deployment of an environment", run: getEnvironment},
+	{name: "env create", args: "CLIENT ENVIRONMENT", help: "create the AEM deployment of an environment from a bedrock.AEMDeployment manifest", run: createEnvironment, flags: manifestFlags},
+	{name: "env scale", args: "CLIENT ENVIRONMENT", help: "change the replicas of the AEM deployment of an environment", run: scaleEnvironment, flags: scaleFlags},
+	{name: "env delete", args: "CLIENT ENVIRONMENT", help: "delete the AEM deployment of an environment", run: deleteEnvironment},
+	{name: "instances list", args: "CLIENT ENVIRONMENT", help: "list the AEM instances of an environment", run: listInstances},
+	{name: "instances password", args: "CLIENT ENVIRONMENT INSTANCE", help: "show the admin password of an AEM instance, requires the secrets-admin role", run: revealPassword, flags: revealFlags},
+	{name: "instances rotate-password", args: "CLIENT ENVIRONMENT", help: "generate a new admin password for the AEM instances of an environment", run: rotatePasswords, flags: rotateFlags},
+	{name: "dispatcher get", args: "CLIENT ENVIRONMENT", help: "show the dispatcher configuration of an environment", run: getDispatcher},
+	{name: "dispatcher edit", args: "CLIENT ENVIRONMENT", help: "edit the dispatcher configuration of an environment with $EDITOR", run: editDispatcher},
+
+	{name: "artifactory create", args: "CLIENT", help: "create the artifact manager of a client from a bedrock.Artifactory manifest", run: createArtifactory, flags: manifestFlags},
+	{name: "artifactory get", args: "CLIENT VENDOR", help: "show the artifact manager of a client", run: getArtifactory},
+	{name: "artifactory delete", args: "CLIENT VENDOR", help: "delete the artifact manager of a client", run: deleteArtifactory},
+	{name: "scm create", args: "CLIENT", help: "create the source control manager of a client from a bedrock.SCM manifest", run: createSCM, flags: manifestFlags},
+	{name: "scm get", args: "CLIENT VENDOR", help: "show the source control manager of a client", run: getSCM},
+	{name: "scm delete", args: "CLIENT VENDOR", help: "delete the source control manager of a client", run: deleteSCM},
+	{name: "ci create", args: "CLIENT", help: "create the continuous integration manager of a client from a bedrock.CI manifest", run: createCI, flags: manifestFlags},
+	{name: "ci get", args: "CLIENT VENDOR", help: "show the continuous integration manager of a client", run: getCI},
+	{name: "ci delete", args: "CLIENT VENDOR", help: "delete the continuous integration manager of a client", run: deleteCI},
+	{name: "toolbelt create", args: "CLIENT", help: "create the toolbelt box of a client", run: createToolbelt},
+	{name: "toolbelt get", args: "CLIENT", help: "show the toolbelt box of a client", run: getToolbelt},
+	{name: "toolbelt delete", args: "CLIENT", help: "delete the toolbelt box of a client", run: deleteToolbelt},
+
+	{name: "vendors list", args: "artifactory|scm|ci", help: "list the vendors of a stack and their images", run: listVendors},
+	{name: "images list", args: "aem|dispatcher", help: "list the images of the AEM deployments", run: listImages},
+	{name: "instance-types list", help: "list the types of the AEM and dispatcher instances", run: listInstanceTypes},
+}
+
+func manifestFlags(fs *flag.FlagSet) {
+	fs.String("f", "", "manifest file in YAML or JSON, - reads the standard input")
+}
+
+func waitFlags(fs *flag.FlagSet) {
+	fs.Bool("wait", false, "wait until the operation finishes")
+}
+
+// flagString returns the value of a flag registered by the flags of the command
+func flagString(fs *flag.FlagSet, name string) string {
+	return fs.Lookup(name).Value.String()
+}
+
+func flagValue(fs *flag.FlagSet, name string) interface{} {
+	return fs.Lookup(name).Value.(flag.Getter).Get()
+}
+
+func clientsTable(clients ...bedrock.Client) table {
+	t := table{{"CLIENT", "STATUS", "PROTECTED", "CREATED", "META"}}
+	for _, c := range clients {
+		meta := []string{}
+		for k, v := range c.MetaData {
+			meta = append(meta, k+"="+v)
+		}
+		sort.Strings(meta)
+		created := ""
+		if c.CreatedAt != nil {
+			created = c.CreatedAt.Format(time.RFC3339)
+		}
+		t = append(t, []string{c.ClientID, c.Status, fmt.Sprint(c.Protected), orNone(created), orNone(strings.Join(meta, ","))})
+	}
+	return t
+}
+
+func summaryTable(s bedrock.ClientSummary) table {
+	t := table{{"RESOURCE", "NAME", "STATUS", "READY", "DETAILS"}}
+	t = append(t, []string{"client", s.Client.ClientID, s.Client.Status, "", fmt.Sprintf("protected=%v", s.Client.Protected)})
+	for _, env := range s.Environments {
+		t = append(t, []string{"environment", env.EnvironmentID, orNone(env.Status), fmt.Sprintf("%v/%v authors, %v/%v publishers, %v/%v dispatchers",
+			env.ReadyReplicas.Authors, env.Replicas.Authors, env.ReadyReplicas.Publishers, env.Replicas.Publishers,
+			env.ReadyReplicas.Dispatchers, env.Replicas.Dispatchers), "aem " + env.Version})
+	}
+	for _, stack := range []struct {
+		name    string
+		summary *bedrock.StackSummary
+	}{{"artifactory", s.Artifactory}, {"scm", s.SCM}, {"ci", s.CI}} {
+		if stack.summary == nil {
+			continue
+		}
+		status := "init " + orNone(stack.summary.InitJob)
+		t = append(t, []string{stack.name, stack.summary.Vendor, status, fmt.Sprintf("%v/%v", stack.summary.ReadyReplicas, stack.summary.Replicas), orNone(stack.summary.Host)})
+	}
+	if s.Certificate != nil {
+		t = append(t, []string{"certificate", s.Certificate.Name, "", fmt.Sprint(s.Certificate.Ready), orNone(s.Certificate.Message)})
+	}
+	if s.Toolbelt != nil {
+		expires := "<unknown>"
+		if s.Toolbelt.ExpiresAt != nil {
+			expires = s.Toolbelt.ExpiresAt.Format(time.RFC3339)
+		}
+		status := "valid"
+		if s.Toolbelt.Expired {
+			status = "expired"
+		}
+		t = append(t, []string{"toolbelt", "toolbelt", status, "", "expires " + expires})
+	}
+	for _, e := range s.Errors {
+		t = append(t, []string{"error", "", "", "", e})
+	}
+	return t
+}
+
+func getClientSummary(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	summary, err := c.api.GetClientSummary(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(summary, summaryTable(summary))
+}
+
+func listClientsFlags(fs *flag.FlagSet) {
+	fs.String("selector", "", "label selector of the metadata, for example team=web,tier in (gold,silver)")
+	fs.String("status", "", "only the clients with these statuses separated by commas: active, suspended or deleting")
+	fs.String("created-after", "", "only the clients created after this RFC3339 time")
+	fs.String("created-before", "", "only the clients created before this RFC3339 time")
+	fs.String("sort", "", "clientId, createdAt or status, a - prefix sorts in descending order")
+	fs.Int("limit", 0, "maximum number of clients")
+	fs.Int("offset", 0, "number of clients skipped, with limit it returns the next pages")
+}
+
+func listClients(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	q := client.ClientQuery{
+		Selector: flagString(fs, "selector"),
+		Sort:     flagString(fs, "sort"),
+		Limit:    flagValue(fs, "limit").(int),
+		Offset:   flagValue(fs, "offset").(int),
+	}
+	if status := flagString(fs, "status"); status != "" {
+		q.Status = strings.Split(status, ",")
+	}
+	for name, t := range map[string]*time.Time{"created-after": &q.CreatedAfter, "created-before": &q.CreatedBefore} {
+		if v := flagString(fs, name); v != "" {
+			var err error
+			*t, err = time.Parse(time.RFC3339, v)
+			if err != nil {
+				return fmt.Errorf("invalid --%v: %v", name, err)
+			}
+		}
+	}
+	clients, err := c.api.ListClients(ctx, q)
+	if err != nil {
+		return err
+	}
+	return c.print(clients, clientsTable(clients...))
+}
+
+func updateClientFlags(fs *flag.FlagSet) {
+	fs.String("meta", "", "metadata to set separated by commas, for example team=web,tier=gold")
+	fs.String("remove", "", "keys of the metadata to remove separated by commas")
+}
+
+func updateClient(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	patch := bedrock.ClientPatch{MetaData: map[string]*string{}}
+	if meta := flagString(fs, "meta"); meta != "" {
+		for _, kv := range strings.Split(meta, ",") {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid --meta %q, the format is key=value", kv)
+			}
+			value := parts[1]
+			patch.MetaData[parts[0]] = &value
+		}
+	}
+	if remove := flagString(fs, "remove"); remove != "" {
+		for _, k := range strings.Split(remove, ",") {
+			patch.MetaData[k] = nil
+		}
+	}
+	if len(patch.MetaData) == 0 {
+		return fmt.Errorf("nothing to update, use --meta or --remove")
+	}
+	client, err := c.api.UpdateClient(ctx, args[0], patch)
+	if err != nil {
+		return err
+	}
+	return c.print(client, clientsTable(client))
+}
+
+func getClient(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	client, err := c.api.GetClient(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(client, clientsTable(client))
+}
+
+func createClient(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	client := bedrock.Client{}
+	err := c.readManifest(flagString(fs, "f"), &client)
+	if err != nil {
+		return err
+	}
+	result, err := c.api.CreateClient(ctx, client)
+	if err != nil {
+		return err
+	}
+	switch {
+	case result.Operation != nil:
+		return c.print(result.Operation, operationTable(*result.Operation))
+	case result.FullDeploy != nil:
+		// the dry run has too many details for a table
+		if c.format == formatTable {
+			c.format = formatYAML
+		}
+		return c.print(result.FullDeploy, nil)
+	}
+	return c.print(result.Client, clientsTable(*result.Client))
+}
+
+func deleteFlags(fs *flag.FlagSet) {
+	fs.Duration("grace-period", 24*time.Hour, "time before the deletion, it can be cancelled until then")
+	fs.Bool("export", false, "export the scm repositories and the artifactory blobs to S3 before the deletion")
+}
+
+func deletionTable(d bedrock.ClientDeletion) table {
+	t := table{{"CLIENT", "STATUS", "DELETE AT", "EXPORT", "EXPORT STATUS"}}
+	for _, e := range d.Exports {
+		t = append(t, []string{d.ClientID, d.Status, d.DeleteAt.Format(time.RFC3339), e.Location, e.Status})
+	}
+	if len(d.Exports) == 0 {
+		t = append(t, []string{d.ClientID, d.Status, d.DeleteAt.Format(time.RFC3339), "<none>", ""})
+	}
+	return t
+}
+
+func deleteClient(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	gracePeriod := flagValue(fs, "grace-period").(time.Duration)
+	deletion, err := c.api.DeleteClient(ctx, args[0], client.DeleteOptions{
+		GracePeriod: &gracePeriod,
+		Export:      flagValue(fs, "export").(bool),
+	})
+	if err != nil {
+		return err
+	}
+	return c.print(deletion, deletionTable(deletion))
+}
+
+func getDeletion(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	deletion, err := c.api.GetDeletion(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(deletion, deletionTable(deletion))
+}
+
+func cancelDeletion(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	deletion, err := c.api.CancelDeletion(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(deletion, deletionTable(deletion))
+}
+
+func protectClient(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	client, err := c.api.ProtectClient(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(client, clientsTable(client))
+}
+
+func unprotectClient(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	client, err := c.api.UnprotectClient(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(client, clientsTable(client))
+}
+
+func suspensionTable(s bedrock.ClientSuspension) table {
+	t := table{{"CLIENT", "SUSPENDED", "RESOURCE", "REPLICAS"}}
+	envs := []string{}
+	for env := range s.AEMDeployments {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	for _, env := range envs {
+		r := s.AEMDeployments[env]
+		t = append(t, []string{s.ClientID, fmt.Sprint(s.Suspended), "aem/" + env,
+			fmt.Sprintf("authors=%v,publishers=%v,dispatchers=%v", r.Authors, r.Publishers, r.Dispatchers)})
+	}
+	names := []string{}
+	for name := range s.StatefulSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t = append(t, []string{s.ClientID, fmt.Sprint(s.Suspended), "statefulset/" + name, fmt.Sprint(s.StatefulSets[name])})
+	}
+	return t
+}
+
+func suspendClient(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	suspension, err := c.api.SuspendClient(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(suspension, suspensionTable(suspension))
+}
+
+func resumeClient(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	suspension, err := c.api.ResumeClient(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(suspension, suspensionTable(suspension))
+}
+
+func operationTable(op bedrock.Operation) table {
+	t := table{{"OPERATION", "CLIENT", "TYPE", "STATUS", "STEP", "STEP STATUS", "ERROR"}}
+	for _, s := range op.Steps {
+		t = append(t, []string{op.OperationID, op.ClientID, op.Type, op.Status, s.Name, s.Status, s.Error})
+	}
+	if len(op.Steps) == 0 {
+		t = append(t, []string{op.OperationID, op.ClientID, op.Type, op.Status, "", "", op.Error})
+	}
+	return t
+}
+
+// operationPollInterval is the time between two checks of an operation with --wait
+const operationPollInterval = 5 * time.Second
+
+func getOperation(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	for {
+		op, err := c.api.GetOperation(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		finished := op.Status == bedrock.OperationSucceeded || op.Status == bedrock.OperationFailed
+		if finished || !flagValue(fs, "wait").(bool) {
+			return c.print(op, operationTable(op))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(operationPollInterval):
+		}
+	}
+}
+
+func auditFlags(fs *flag.FlagSet) {
+	fs.String("environment", "", "only the records of this environment")
+	fs.String("since", "", "only the records since this RFC3339 time, for example 2018-01-02T15:04:05Z")
+	fs.Int("limit", 0, "maximum number of records, the last ones")
+}
+
+func listAudit(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	q := client.AuditQuery{
+		EnvironmentID: flagString(fs, "environment"),
+		Limit:         flagValue(fs, "limit").(int),
+	}
+	if since := flagString(fs, "since"); since != "" {
+		var err error
+		q.Since, err = time.Parse(time.RFC3339, since)
+		if err != nil {
+			return fmt.Errorf("invalid --since: %v", err)
+		}
+	}
+	records, err := c.api.ListAudit(ctx, args[0], q)
+	if err != nil {
+		return err
+	}
+	t := table{{"TIME", "ACTOR", "ENVIRONMENT", "METHOD", "PATH", "STATUS", "DURATION"}}
+	for _, r := range records {
+		t = append(t, []string{r.Time.Format(time.RFC3339), r.Actor, orNone(r.EnvironmentID), r.Method, r.Path,
+			fmt.Sprint(r.Status), fmt.Sprintf("%vms", r.DurationMs)})
+	}
+	return c.print(records, t)
+}
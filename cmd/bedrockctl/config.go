@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+)
+
+// config is the config file of bedrockctl, for example:
+//
+//	server: https://bedrock.example.com/api/v1
+//	token: my-static-token
+//
+// BEDROCK_SERVER and BEDROCK_TOKEN override the file, the flags override both
+type config struct {
+	Server string `json:"server"`
+	Token  string `json:"token"`
+}
+
+// defaultConfigPath returns BEDROCK_CONFIG or ~/.bedrock/config.yaml
+func defaultConfigPath() string {
+	if path := os.Getenv("BEDROCK_CONFIG"); path != "" {
+		return path
+	}
+	return filepath.Join(os.Getenv("HOME"), ".bedrock", "config.yaml")
+}
+
+// loadConfig reads the config file in path, a missing file is an empty config
+func loadConfig(path string) (config, error) {
+	cfg := config{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return cfg, err
+	}
+	if err == nil {
+		err = yaml.Unmarshal(data, &cfg)
+		if err != nil {
+			return cfg, err
+		}
+	}
+	if server := os.Getenv("BEDROCK_SERVER"); server != "" {
+		cfg.Server = server
+	}
+	if token := os.Getenv("BEDROCK_TOKEN"); token != "" {
+		cfg.Token = token
+	}
+	return cfg, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/xumak-grid/bedrock"
+)
+
+func scaleFlags(fs *flag.FlagSet) {
+	fs.Int("authors", -1, "replicas of the authors, unchanged when is negative")
+	fs.Int("publishers", -1, "replicas of the publishers, unchanged when is negative")
+	fs.Int("dispatchers", -1, "replicas of the dispatchers, unchanged when is negative")
+}
+
+func replicas(config bedrock.Config) string {
+	return strconv.Itoa(config.Replicas) + " " + config.Type
+}
+
+func deploymentTable(d bedrock.AEMDeployment) table {
+	return table{
+		{"CLIENT", "ENVIRONMENT", "VERSION", "DISPATCHER", "AUTHORS", "PUBLISHERS", "DISPATCHERS", "STATUS"},
+		{d.ClientID, d.EnvironmentID, d.Spec.Version, d.Spec.DispatcherVersion,
+			replicas(d.Spec.Authors), replicas(d.Spec.Publishers), replicas(d.Spec.Dispatchers), orNone(d.Status)},
+	}
+}
+
+func listEnvironments(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	envs, err := c.api.ListEnvironments(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	t := table{{"ENVIRONMENT"}}
+	for _, e := range envs {
+		t = append(t, []string{e.EnvironmentID})
+	}
+	return c.print(envs, t)
+}
+
+func getEnvironment(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	d, err := c.api.GetAEMDeployment(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	return c.print(d, deploymentTable(d))
+}
+
+func createEnvironment(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	d := bedrock.AEMDeployment{}
+	err := c.readManifest(flagString(fs, "f"), &d)
+	if err != nil {
+		return err
+	}
+	d.ClientID, d.EnvironmentID = args[0], args[1]
+	d, err = c.api.CreateAEMDeployment(ctx, d)
+	if err != nil {
+		return err
+	}
+	return c.print(d, deploymentTable(d))
+}
+
+// scaleEnvironment updates the replicas keeping the rest of the current spec
+func scaleEnvironment(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	d, err := c.api.GetAEMDeployment(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	changed := false
+	for name, config := range map[string]*bedrock.Config{
+		"authors":     &d.Spec.Authors,
+		"publishers":  &d.Spec.Publishers,
+		"dispatchers": &d.Spec.Dispatchers,
+	} {
+		if n := flagValue(fs, name).(int); n >= 0 {
+			config.Replicas = n
+			changed = true
+		}
+	}
+	if !changed {
+		return fmt.Errorf("nothing to scale, use --authors, --publishers or --dispatchers")
+	}
+	d.ClientID, d.EnvironmentID = args[0], args[1]
+	d, err = c.api.UpdateAEMDeployment(ctx, d)
+	if err != nil {
+		return err
+	}
+	return c.print(d, deploymentTable(d))
+}
+
+func deleteEnvironment(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	err := c.api.DeleteAEMDeployment(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "environment %v of %v deleted\n", args[1], args[0])
+	return nil
+}
+
+func listInstances(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	instances, err := c.api.ListInstances(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	t := table{{"NAME", "ENVIRONMENT", "RUNMODE", "RUNNING", "READY"}}
+	for _, i := range instances {
+		t = append(t, []string{i.Name, i.Environment, i.Runmode, yesNo(i.Running), yesNo(i.Ready)})
+	}
+	return c.print(instances, t)
+}
+
+func revealFlags(fs *flag.FlagSet) {
+	fs.Duration("wrap-ttl", 0, "return a vault wrapping token that expires after the duration instead of the password")
+}
+
+func revealPassword(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	creds, err := c.api.RevealPassword(ctx, args[0], args[1], args[2], flagValue(fs, "wrap-ttl").(time.Duration))
+	if err != nil {
+		return err
+	}
+	if creds.WrappingToken != "" {
+		t := table{{"INSTANCE", "WRAPPING TOKEN", "EXPIRES"}, {creds.Instance, creds.WrappingToken, creds.WrappingExpiresAt.Format(time.RFC3339)}}
+		return c.print(creds, t)
+	}
+	return c.print(creds, table{{"INSTANCE", "PASSWORD"}, {creds.Instance, creds.Password}})
+}
+
+func rotateFlags(fs *flag.FlagSet) {
+	fs.String("instance", "", "rotate only the password of this instance")
+}
+
+func rotatePasswords(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	var rotation bedrock.PasswordRotation
+	var err error
+	if instance := flagString(fs, "instance"); instance != "" {
+		rotation, err = c.api.RotateInstancePassword(ctx, args[0], args[1], instance)
+	} else {
+		rotation, err = c.api.RotatePasswords(ctx, args[0], args[1])
+	}
+	if err != nil {
+		return err
+	}
+	t := table{{"CLIENT", "ENVIRONMENT", "INSTANCES", "ROTATED"}}
+	t = append(t, []string{rotation.ClientID, rotation.EnvironmentID, orNone(strings.Join(rotation.Instances, ",")), rotation.RotatedAt.Format(time.RFC3339)})
+	return c.print(rotation, t)
+}
+
+func configMapTable(cm bedrock.ConfigMap) table {
+	keys := []string{}
+	for k := range cm.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return table{{"NAME", "CLIENT", "ENVIRONMENT", "KEYS"}, {cm.Name, cm.ClientID, cm.EnvironmentID, orNone(strings.Join(keys, ","))}}
+}
+
+func getDispatcher(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	cm, err := c.api.GetDispatcherConfig(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	return c.print(cm, configMapTable(cm))
+}
+
+// editDispatcher opens the dispatcher configMap as YAML in the editor and applies it when it changed
+func editDispatcher(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	cm, err := c.api.GetDispatcherConfig(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	original, err := yaml.Marshal(cm)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile("", "bedrock-dispatcher-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(original)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	// the editor can include arguments, for example "code --wait"
+	editor := editorCommand()
+	cmd := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("error running the editor: %v", err)
+	}
+	edited, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(original, edited) {
+		fmt.Fprintln(c.out, "edit cancelled, no changes made")
+		return nil
+	}
+	updated := bedrock.ConfigMap{}
+	err = yaml.Unmarshal(edited, &updated)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
+	}
+	updated.ClientID, updated.EnvironmentID = args[0], args[1]
+	cm, err = c.api.UpdateDispatcherConfig(ctx, updated)
+	if err != nil {
+		return err
+	}
+	return c.print(cm, configMapTable(cm))
+}
@@ -0,0 +1,176 @@
+// Command bedrockctl manages the clients of the bedrock API and their stacks.
+//
+//	bedrockctl clients create -f client.yaml
+//	bedrockctl env scale acme dev --publishers 2
+//	bedrockctl instances list acme dev -o yaml
+//	bedrockctl dispatcher edit acme dev
+//
+// The manifests are the YAML or JSON of the bedrock types, the server and the token
+// are read from the config file, see config.go.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/xumak-grid/bedrock/client"
+)
+
+// errUsage is returned when the arguments are invalid, the usage was already printed
+var errUsage = errors.New("invalid arguments")
+
+// ctl is the state shared by the commands
+type ctl struct {
+	api    *client.Client
+	in     io.Reader
+	out    io.Writer
+	format string
+}
+
+// command is a subcommand, name is the resource and the verb, for example "clients create"
+type command struct {
+	name string
+	// args are the positional arguments, for example CLIENT ENVIRONMENT
+	args  string
+	help  string
+	run   func(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error
+	flags func(fs *flag.FlagSet)
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout)
+	if err == errUsage {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, in io.Reader, out io.Writer) error {
+	global := flag.NewFlagSet("bedrockctl", flag.ContinueOnError)
+	global.SetOutput(out)
+	configPath := global.String("config", defaultConfigPath(), "config file with the server and the token")
+	server := global.String("server", "", "url of the api, for example https://bedrock.example.com/api/v1")
+	token := global.String("token", "", "bearer token of the api")
+	timeout := global.Duration("timeout", 5*time.Minute, "maximum duration of the command")
+	global.Usage = func() { usage(global, out) }
+	err := global.Parse(args)
+	if err == flag.ErrHelp {
+		return nil
+	}
+	if err != nil {
+		return errUsage
+	}
+	args = global.Args()
+	if len(args) < 2 {
+		usage(global, out)
+		return errUsage
+	}
+	cmd, ok := findCommand(args[0], args[1])
+	if !ok {
+		fmt.Fprintf(out, "unknown command %v %v\n\n", args[0], args[1])
+		usage(global, out)
+		return errUsage
+	}
+
+	c := &ctl{in: in, out: out}
+	fs := flag.NewFlagSet("bedrockctl "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.StringVar(&c.format, "o", formatTable, "output format: table, json or yaml")
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(out, "usage: bedrockctl %v [flags] %v\n\n%v\n\n", cmd.name, cmd.args, cmd.help)
+		fs.PrintDefaults()
+	}
+	args, err = parseArgs(fs, args[2:])
+	if err == flag.ErrHelp {
+		return nil
+	}
+	if err != nil {
+		return errUsage
+	}
+	if len(args) != len(strings.Fields(cmd.args)) {
+		fs.Usage()
+		return errUsage
+	}
+	if c.format != formatTable && c.format != formatJSON && c.format != formatYAML {
+		return fmt.Errorf("unknown output format %v", c.format)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *server != "" {
+		cfg.Server = *server
+	}
+	if *token != "" {
+		cfg.Token = *token
+	}
+	if cfg.Server == "" {
+		return fmt.Errorf("the server is not configured, use --server or set it in %v", *configPath)
+	}
+	c.api, err = client.New(cfg.Server)
+	if err != nil {
+		return err
+	}
+	c.api.Token = cfg.Token
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	return cmd.run(ctx, c, fs, args)
+}
+
+// parseArgs parses the flags of fs before, between and after the positional arguments,
+// for example "acme dev --publishers 2", and returns the positional arguments, the
+// arguments after "--" are always positional
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for len(args) > 0 {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) < len(args) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+	return positional, nil
+}
+
+func findCommand(resource, verb string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == resource+" "+verb {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func usage(global *flag.FlagSet, out io.Writer) {
+	fmt.Fprintf(out, "usage: bedrockctl [global flags] RESOURCE VERB [flags] [ARGS]\n\ncommands:\n")
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %v %v\t%v\n", cmd.name, cmd.args, cmd.help)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\nglobal flags:\n")
+	global.PrintDefaults()
+}
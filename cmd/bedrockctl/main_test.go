@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xumak-grid/bedrock"
+)
+
+func TestRun(t *testing.T) {
+	var updated bedrock.AEMDeployment
+	mux := http.NewServeMux()
+	mux.HandleFunc("/clients", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode([]bedrock.Client{{ClientID: "acme"}})
+	})
+	mux.HandleFunc("/clients/acme/environments/dev/aem", func(w http.ResponseWriter, r *http.Request) {
+		d := bedrock.AEMDeployment{ClientID: "acme", EnvironmentID: "dev"}
+		d.Spec.Publishers = bedrock.Config{Type: "small", Replicas: 1}
+		d.Spec.Authors = bedrock.Config{Type: "small", Replicas: 1}
+		if r.Method == http.MethodPatch {
+			json.NewDecoder(r.Body).Decode(&updated)
+			d = updated
+		}
+		json.NewEncoder(w).Encode(d)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "bedrockctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configPath := filepath.Join(dir, "config.yaml")
+	err = ioutil.WriteFile(configPath, []byte(`{"server": "`+server.URL+`", "token": "secret"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	err = run([]string{"-config", configPath, "clients", "list"}, nil, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "CLIENT") || !strings.Contains(out.String(), "acme") {
+		t.Errorf("unexpected table:\n%v", out)
+	}
+
+	out.Reset()
+	err = run([]string{"-config", configPath, "clients", "list", "-o", "json"}, nil, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := []bedrock.Client{}
+	err = json.Unmarshal(out.Bytes(), &clients)
+	if err != nil || len(clients) != 1 {
+		t.Errorf("unexpected json %v: %v", out, err)
+	}
+
+	out.Reset()
+	err = run([]string{"-config", configPath, "env", "scale", "--publishers", "3", "acme", "dev"}, nil, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Spec.Publishers.Replicas != 3 || updated.Spec.Authors.Replicas != 1 {
+		t.Errorf("unexpected scaled spec %+v", updated.Spec)
+	}
+
+	out.Reset()
+	err = run([]string{"-config", configPath, "env", "scale", "acme", "dev", "--publishers", "2"}, nil, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Spec.Publishers.Replicas != 2 {
+		t.Errorf("expected the flags after the arguments parsed, got %+v", updated.Spec)
+	}
+
+	out.Reset()
+	err = run([]string{"-config", configPath, "env", "scale", "acme"}, nil, out)
+	if err != errUsage {
+		t.Errorf("expected usage error with missing arguments, got %v", err)
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args       []string
+		positional []string
+		publishers int
+	}{
+		{args: []string{"acme", "dev"}, positional: []string{"acme", "dev"}},
+		{args: []string{"--publishers", "2", "acme", "dev"}, positional: []string{"acme", "dev"}, publishers: 2},
+		{args: []string{"acme", "--publishers", "2", "dev"}, positional: []string{"acme", "dev"}, publishers: 2},
+		{args: []string{"acme", "dev", "--publishers=2"}, positional: []string{"acme", "dev"}, publishers: 2},
+		{args: []string{"acme", "--", "-dev"}, positional: []string{"acme", "-dev"}},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		publishers := fs.Int("publishers", 0, "")
+		positional, err := parseArgs(fs, tc.args)
+		if err != nil {
+			t.Errorf("%v: %v", tc.args, err)
+			continue
+		}
+		if strings.Join(positional, " ") != strings.Join(tc.positional, " ") || *publishers != tc.publishers {
+			t.Errorf("%v: expected %v and %v publishers, got %v and %v", tc.args, tc.positional, tc.publishers, positional, *publishers)
+		}
+	}
+}
+
+func TestEditorCommand(t *testing.T) {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		defer os.Setenv(env, os.Getenv(env))
+	}
+	os.Setenv("VISUAL", "  ")
+	os.Setenv("EDITOR", "code --wait")
+	if editor := editorCommand(); strings.Join(editor, " ") != "code --wait" {
+		t.Errorf("expected the blank VISUAL ignored, got %v", editor)
+	}
+	os.Setenv("EDITOR", "")
+	if editor := editorCommand(); strings.Join(editor, " ") != "vi" {
+		t.Errorf("expected vi by default, got %v", editor)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ghodss/yaml"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+// table is the tabular output of a value, the first row is the header
+type table [][]string
+
+// print writes v in the output format, t is used by the table format
+func (c *ctl) print(v interface{}, t table) error {
+	switch c.format {
+	case formatJSON:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(c.out, string(data))
+		return err
+	case formatYAML:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = c.out.Write(data)
+		return err
+	}
+	return printTable(c.out, t)
+}
+
+func printTable(out io.Writer, t table) error {
+	w := tabwriter.NewWriter(out, 0, 4, 3, ' ', 0)
+	for _, row := range t {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// readManifest decodes the YAML or JSON file in path into v, - is the standard input
+func (c *ctl) readManifest(path string, v interface{}) error {
+	if path == "" {
+		return fmt.Errorf("the manifest is required, use -f FILE")
+	}
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = ioutil.ReadAll(c.in)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	err = yaml.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("invalid manifest %v: %v", path, err)
+	}
+	return nil
+}
+
+// yesNo formats a boolean column
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// orNone formats an optional column
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+// editorCommand returns the editor configured in VISUAL or EDITOR split in the command and
+// its arguments, vi by default, the blank variables are ignored
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.Fields(os.Getenv(env)); len(editor) > 0 {
+			return editor
+		}
+	}
+	return []string{"vi"}
+}
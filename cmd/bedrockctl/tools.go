@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/xumak-grid/bedrock"
+)
+
+func serverTable(vendor, server, image, host string, customConfig bool) table {
+	return table{
+		{"VENDOR", "SERVER", "IMAGE", "HOST", "CUSTOM CONFIG"},
+		{vendor, server, image, orNone(host), yesNo(customConfig)},
+	}
+}
+
+func createArtifactory(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	a := bedrock.Artifactory{}
+	err := c.readManifest(flagString(fs, "f"), &a)
+	if err != nil {
+		return err
+	}
+	a, err = c.api.CreateArtifactory(ctx, args[0], a)
+	if err != nil {
+		return err
+	}
+	return c.print(a, serverTable(a.ArtifactoryID, a.ServerName, a.Image, a.Host, a.CustomConfig))
+}
+
+func getArtifactory(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	a, err := c.api.GetArtifactory(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	return c.print(a, serverTable(a.ArtifactoryID, a.ServerName, a.Image, a.Host, a.CustomConfig))
+}
+
+func deleteArtifactory(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	err := c.api.DeleteArtifactory(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "artifactory %v of %v deleted\n", args[1], args[0])
+	return nil
+}
+
+func createSCM(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	s := bedrock.SCM{}
+	err := c.readManifest(flagString(fs, "f"), &s)
+	if err != nil {
+		return err
+	}
+	s, err = c.api.CreateSCM(ctx, args[0], s)
+	if err != nil {
+		return err
+	}
+	return c.print(s, serverTable(s.SCMID, s.ServerName, s.Image, s.Host, s.CustomConfig))
+}
+
+func getSCM(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	s, err := c.api.GetSCM(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	return c.print(s, serverTable(s.SCMID, s.ServerName, s.Image, s.Host, s.CustomConfig))
+}
+
+func deleteSCM(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	err := c.api.DeleteSCM(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "scm %v of %v deleted\n", args[1], args[0])
+	return nil
+}
+
+func ciTable(ci bedrock.CI) table {
+	return table{
+		{"VENDOR", "SERVER", "IMAGE", "AGENT IMAGE", "HOST", "SCM"},
+		{ci.CIID, ci.ServerName, ci.Image, ci.SecondImage, orNone(ci.Host), ci.ScmURL},
+	}
+}
+
+func createCI(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	ci := bedrock.CI{}
+	err := c.readManifest(flagString(fs, "f"), &ci)
+	if err != nil {
+		return err
+	}
+	ci, err = c.api.CreateCI(ctx, args[0], ci)
+	if err != nil {
+		return err
+	}
+	return c.print(ci, ciTable(ci))
+}
+
+func getCI(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	ci, err := c.api.GetCI(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	return c.print(ci, ciTable(ci))
+}
+
+func deleteCI(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	err := c.api.DeleteCI(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "ci %v of %v deleted\n", args[1], args[0])
+	return nil
+}
+
+func toolbeltTable(tb bedrock.Toolbelt) table {
+	return table{{"CLIENT", "URL", "MESSAGE"}, {tb.ClientID, orNone(tb.URL), tb.Message}}
+}
+
+func createToolbelt(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	tb, err := c.api.CreateToolbelt(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(tb, toolbeltTable(tb))
+}
+
+func getToolbelt(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	tb, err := c.api.GetToolbelt(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(tb, toolbeltTable(tb))
+}
+
+func deleteToolbelt(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	err := c.api.DeleteToolbelt(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "toolbelt of %v deleted\n", args[0])
+	return nil
+}
+
+func listVendors(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	var vendors []bedrock.Vendor
+	var err error
+	switch args[0] {
+	case "artifactory":
+		vendors, err = c.api.ListArtifactoryVendors(ctx)
+	case "scm":
+		vendors, err = c.api.ListSCMVendors(ctx)
+	case "ci":
+		vendors, err = c.api.ListCIVendors(ctx)
+	default:
+		return fmt.Errorf("unknown stack %v, use artifactory, scm or ci", args[0])
+	}
+	if err != nil {
+		return err
+	}
+	t := table{{"VENDOR", "IMAGES"}}
+	for _, v := range vendors {
+		images := []string{}
+		for _, i := range v.Images {
+			images = append(images, i.Name)
+		}
+		t = append(t, []string{v.Name, strings.Join(images, ",")})
+	}
+	return c.print(vendors, t)
+}
+
+func listImages(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	var images []bedrock.Image
+	var err error
+	switch args[0] {
+	case "aem":
+		images, err = c.api.ListAEMImages(ctx)
+	case "dispatcher":
+		images, err = c.api.ListDispatcherImages(ctx)
+	default:
+		return fmt.Errorf("unknown image kind %v, use aem or dispatcher", args[0])
+	}
+	if err != nil {
+		return err
+	}
+	t := table{{"IMAGE"}}
+	for _, i := range images {
+		t = append(t, []string{i.Name})
+	}
+	return c.print(images, t)
+}
+
+func listInstanceTypes(ctx context.Context, c *ctl, fs *flag.FlagSet, args []string) error {
+	types, err := c.api.ListInstanceTypes(ctx)
+	if err != nil {
+		return err
+	}
+	t := table{{"TYPE", "DESCRIPTION"}}
+	for _, i := range types {
+		t = append(t, []string{i.Name, i.Description})
+	}
+	return c.print(types, t)
+}
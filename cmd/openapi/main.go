@@ -0,0 +1,28 @@
+// Command openapi writes to stdout the OpenAPI spec of the api generated from the routes
+// and the bedrock types, it is used to update openapi.yaml:
+//
+//	go run ./cmd/openapi > openapi.yaml
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/ghodss/yaml"
+	"github.com/xumak-grid/bedrock/http"
+)
+
+func main() {
+	spec, err := http.Spec()
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := yaml.Marshal(spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = os.Stdout.Write(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -40,15 +40,11 @@ func InitPackages() []InitPackage {
 }
 
 // PreSignedURL creates a new pre-signed url for an InitPackage with 1 hour expiration
-func (p *InitPackage) PreSignedURL() (string, error) {
+func (p *InitPackage) PreSignedURL(presigner awscli.Presigner) (string, error) {
 
-	sess, err := awscli.Session()
-	if err != nil {
-		return "", err
-	}
 	s3obj := awscli.NewS3Object(p.Bucket, p.Key)
 
-	return s3obj.PreSignedURL(sess, 1)
+	return presigner.PreSignedURL(s3obj, 1)
 }
 
 // FindInitPackage finds an init package version from the InitPackages list
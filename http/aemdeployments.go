@@ -9,7 +9,8 @@ import (
 	"github.com/go-chi/chi"
 	aemclientset "github.com/xumak-grid/aem-operator/pkg/generated/clientset/versioned"
 	"github.com/xumak-grid/bedrock/k8s"
-	"github.com/xumak-grid/bedrock/secrets/vault"
+	"github.com/xumak-grid/bedrock/metrics"
+	"github.com/xumak-grid/bedrock/secrets"
 )
 
 // createAEMDeploymentHandler to create AEMDeployments
@@ -17,39 +18,44 @@ func createAEMDeploymentHandler(w http.ResponseWriter, r *http.Request) {
 	aemDeploy := bedrock.AEMDeployment{}
 	err := decode(r, &aemDeploy)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if aemDeploy.Spec.DispatcherVersion == "" || aemDeploy.Spec.Version == "" {
-		jsonError(w, "dispatcher_version and version are required", http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	aemDeploy.ClientID = chi.URLParam(r, "clientId")
 	aemDeploy.EnvironmentID = chi.URLParam(r, "environmentId")
-	err = checkClient(r, aemDeploy.ClientID)
+	err = bedrock.Validate(aemDeploy)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = checkActiveClient(r, aemDeploy.ClientID)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusNotFound)
+		writeError(w, err)
 		return
 	}
 
 	aemClient := getAEMClient(r)
-	err = createAEMDeployment(aemClient, aemDeploy)
+	result, err := createAEMDeployment(aemClient, aemDeploy, nil)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(applyStatus(result))
 	encode(w, aemDeploy)
 }
 
-// createAEMDeployment creates an k8s AEM deployment
-func createAEMDeployment(aemClient aemclientset.Interface, deploy bedrock.AEMDeployment) error {
-	_, err := k8s.CreateAEMDeployment(aemClient, &deploy)
+// createAEMDeployment creates or updates an k8s AEM deployment, the deployment is recorded in tracker when is created
+func createAEMDeployment(aemClient aemclientset.Interface, deploy bedrock.AEMDeployment, tracker *deployTracker) (k8s.ApplyResult, error) {
+	result, err := k8s.ApplyAEMDeployment(aemClient, &deploy)
+	metrics.ObserveProvisioning("aem", "aem-operator", err)
 	if err != nil {
-		return err
+		return result, err
 	}
-	return nil
+	tracker.track(result, "AEMDeployment", deploy.ClientID+"/"+deploy.EnvironmentID, func() error {
+		return k8s.DeleteAEMDeployment(aemClient, &deploy)
+	})
+	return result, nil
 }
 
 // getAEMDeployment router to get an AEM deployment
@@ -59,14 +65,14 @@ func getAEMDeployment(w http.ResponseWriter, r *http.Request) {
 	aemDeploy.EnvironmentID = chi.URLParam(r, "environmentId")
 	err := checkClient(r, aemDeploy.ClientID)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusNotFound)
+		writeError(w, err)
 		return
 	}
 
 	aemClient := getAEMClient(r)
 	k8sDep, err := k8s.GetAEMDeployment(aemClient, &aemDeploy)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	aemDeploy.Spec.Authors.Type = k8sDep.Spec.Authors.Type
@@ -88,14 +94,14 @@ func deleteAEMDeployment(w http.ResponseWriter, r *http.Request) {
 	aemDeploy.EnvironmentID = chi.URLParam(r, "environmentId")
 	err := checkClient(r, aemDeploy.ClientID)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusNotFound)
+		writeError(w, err)
 		return
 	}
 
 	aemClient := getAEMClient(r)
 	err = k8s.DeleteAEMDeployment(aemClient, &aemDeploy)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	encode(w, aemDeploy)
@@ -106,32 +112,34 @@ func deleteAEMDeployment(w http.ResponseWriter, r *http.Request) {
 func updateAEMDeployment(w http.ResponseWriter, r *http.Request) {
 	aemDeploy := bedrock.AEMDeployment{}
 	err := decode(r, &aemDeploy)
-	aemDeploy.ClientID = chi.URLParam(r, "clientId")
-	aemDeploy.EnvironmentID = chi.URLParam(r, "environmentId")
-	err = checkClient(r, aemDeploy.ClientID)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusNotFound)
+		writeError(w, err)
 		return
 	}
-	if aemDeploy.Spec.DispatcherVersion == "" || aemDeploy.Spec.Version == "" {
-		jsonError(w, "dispatcher_version and version are required", http.StatusInternalServerError)
+	aemDeploy.ClientID = chi.URLParam(r, "clientId")
+	aemDeploy.EnvironmentID = chi.URLParam(r, "environmentId")
+	err = checkActiveClient(r, aemDeploy.ClientID)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-
+	err = bedrock.Validate(aemDeploy)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
+
 	aemClient := getAEMClient(r)
 	err = k8s.UpdateAEMDeployment(aemClient, &aemDeploy)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	encode(w, aemDeploy)
 }
 
 // ListAEMPods list all pods for a given deployment in k8s.
+// the passwords are not included, they are returned by revealInstancePassword
 func ListAEMPods(w http.ResponseWriter, r *http.Request) {
 	aemDeploy := bedrock.AEMDeployment{}
 	aemDeploy.ClientID = chi.URLParam(r, "clientId")
@@ -141,20 +149,12 @@ func ListAEMPods(w http.ResponseWriter, r *http.Request) {
 	l, err := k8s.ListAEMDeploymentPods(k8sclient, &aemDeploy)
 	if err != nil {
 		log.Println(err)
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
 	instances := make([]bedrock.Instance, 0)
 	for _, i := range l {
-
-		password, err := getPodPassword(i.Namespace, aemDeploy.EnvironmentID, i.Name)
-		if err != nil {
-			log.Println(err)
-			jsonError(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
 		instances = append(instances, bedrock.Instance{
 			Name:        i.Name,
 			Account:     i.Namespace,
@@ -162,24 +162,18 @@ func ListAEMPods(w http.ResponseWriter, r *http.Request) {
 			Runmode:     i.Labels["runmode"],
 			Running:     k8s.IsPodRunning(&i),
 			Ready:       k8s.IsPodReady(&i),
-			Password:    password,
 		})
 	}
 	encode(w, instances)
 }
 
-// getPodPassword obtains the instance passwored stored in Vault
-func getPodPassword(namespace string, deployment string, podName string) (string, error) {
+// getPodPassword obtains the instance passwored stored in the secret service
+func getPodPassword(secretService secrets.SecretService, namespace string, deployment string, podName string) (string, error) {
 	// Getting the instance passwords
 	podSecretsKey := getPodSecretKey(namespace, deployment, podName)
-	// podSecrets, err := secrets.SecretService.Get(podSecretsKey)
-	secrets, err := vault.NewSecretService()
-	if err != nil {
-		return "", err
-	}
-	podSecrets, err := secrets.Get(podSecretsKey)
+	podSecrets, err := secretService.Get(podSecretsKey)
 	if err != nil {
-		return "", err
+		return "", upstreamError("secrets", err)
 	}
 	pwd, ok := podSecrets["password"]
 	if !ok || pwd == nil {
@@ -200,14 +194,14 @@ func listEnvironments(w http.ResponseWriter, r *http.Request) {
 	aemDeploy.EnvironmentID = chi.URLParam(r, "environmentId")
 	err := checkClient(r, aemDeploy.ClientID)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusNotFound)
+		writeError(w, err)
 		return
 	}
 
 	aemClient := getAEMClient(r)
 	k8sDeps, err := k8s.ListAEMDeployments(aemClient, aemDeploy.ClientID)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	environments := []bedrock.Environment{}
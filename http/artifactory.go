@@ -9,6 +9,7 @@ import (
 
 	"github.com/go-chi/chi"
 	"github.com/xumak-grid/bedrock/k8s"
+	"github.com/xumak-grid/bedrock/metrics"
 	"github.com/xumak-grid/bedrock/stack/nexus"
 	"k8s.io/client-go/kubernetes"
 )
@@ -30,59 +31,61 @@ func artifactoryVendors() []bedrock.Vendor {
 // this artifactory manager is created based on the list of artifactories available
 func createArtifactoryHandler(w http.ResponseWriter, r *http.Request) {
 	artifactory := bedrock.Artifactory{}
-	decode(r, &artifactory)
-	if artifactory.Image == "" {
-		jsonError(w, "image is required", http.StatusBadRequest)
+	err := decode(r, &artifactory)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = bedrock.Validate(artifactory)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 	ns := chi.URLParam(r, "clientId")
-	err := checkClient(r, ns)
+	err = checkActiveClient(r, ns)
 	if err != nil {
-		jsonError(w, "invalid clientId", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	if !validVendor(artifactory.ArtifactoryID, artifactoryVendors()) {
-		jsonError(w, "unknown artifactoryId", http.StatusBadRequest)
+		writeError(w, fieldError("artifactoryId", "unknown artifactoryId "+artifactory.ArtifactoryID))
 		return
 	}
-	if artifactory.CustomConfig {
-		if artifactory.Configuration == nil {
-			jsonError(w, "configuration not provided when the request requires cusotomConfig", http.StatusBadRequest)
-			return
-		}
-		ops := len(artifactory.Configuration.Users) + len(artifactory.Configuration.Groups) + len(artifactory.Configuration.Hosteds) + len(artifactory.Configuration.Proxies)
-		if ops == 0 {
-			jsonError(w, "requires at least 1 member of users, groups, hosteds or proxies", http.StatusBadRequest)
-			return
-		}
-	}
-
 	k8scli := getK8Client(r)
-	err = createArtifactory(k8scli, ns, &artifactory)
+	result, err := createArtifactory(k8scli, ns, &artifactory, nil)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(applyStatus(result))
 	encode(w, artifactory)
 }
 
-// createArtifactory creates a new artifactory and populates the artifactory pointer with more data
-// also creates k8s resources that are part of the artifactory
-func createArtifactory(kubeCli kubernetes.Interface, ns string, artifactory *bedrock.Artifactory) error {
-	k8Service, err := k8s.CreateService(kubeCli, ns, nexus.Service(ns))
+// createArtifactory creates or updates an artifactory and populates the artifactory pointer with more data
+// also applies the k8s resources that are part of the artifactory, the created resources are recorded in tracker
+// the returned result is k8s.Created when at least one resource was created
+func createArtifactory(kubeCli kubernetes.Interface, ns string, artifactory *bedrock.Artifactory, tracker *deployTracker) (_ k8s.ApplyResult, err error) {
+	defer func() { metrics.ObserveProvisioning("artifactory", nexus.Vendor().Name, err) }()
+	applied := k8s.Unchanged
+	k8Service, result, err := k8s.ApplyService(kubeCli, ns, nexus.Service(ns))
 	if err != nil {
-		return err
+		return applied, err
 	}
-	k8Ingress, err := k8s.CreateIngress(kubeCli, ns, nexus.Ingress(ns))
+	trackService(tracker, result, kubeCli, ns, k8Service.Name)
+	applied = applied.Merge(result)
+	k8Ingress, result, err := k8s.ApplyIngress(kubeCli, ns, nexus.Ingress(ns))
 	if err != nil {
-		return err
+		return applied, err
 	}
-	k8Statefulset, err := k8s.CreateStatefulSet(kubeCli, ns, nexus.StatefulSet(artifactory.Image, ns))
+	trackIngress(tracker, result, kubeCli, ns, k8Ingress.Name)
+	applied = applied.Merge(result)
+	k8Statefulset, result, err := k8s.ApplyStatefulSet(kubeCli, ns, nexus.StatefulSet(artifactory.Image, ns))
 	if err != nil {
-		return err
+		return applied, err
 	}
+	trackStatefulSet(tracker, result, kubeCli, ns, k8Statefulset.Name)
+	applied = applied.Merge(result)
 
 	// populating artifactory
 	artifactory.ServerName = k8Statefulset.Name
@@ -96,53 +99,51 @@ func createArtifactory(kubeCli kubernetes.Interface, ns string, artifactory *bed
 	if artifactory.CustomConfig {
 		secretData, err := json.Marshal(artifactory.Configuration)
 		if err != nil {
-			return err
+			return applied, err
 		}
-		_, err = k8s.CreateSecret(kubeCli, ns, nexus.Secret(ns, secretData))
+		result, err := applyInitJob(kubeCli, ns, nexus.Secret(ns, secretData), nexus.InitJob(artifactory.Host, ns), tracker)
 		if err != nil {
-			return err
-		}
-		_, err = k8s.CreateJob(kubeCli, ns, nexus.InitJob(artifactory.Host, ns))
-		if err != nil {
-			return err
+			return applied, err
 		}
+		applied = applied.Merge(result)
 	}
-	return nil
+	return applied, nil
 }
 
 func getArtifactory(w http.ResponseWriter, r *http.Request) {
 	ns := chi.URLParam(r, "clientId")
 	err := checkClient(r, ns)
 	if err != nil {
-		jsonError(w, "invalid clientId", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	artifactory := bedrock.Artifactory{
 		ArtifactoryID: chi.URLParam(r, "artifactoryId"),
 	}
 	if !validVendor(artifactory.ArtifactoryID, artifactoryVendors()) {
-		jsonError(w, "unknown artifactoryId", http.StatusBadRequest)
+		writeError(w, notFoundError("unknown artifactoryId %v", artifactory.ArtifactoryID))
 		return
 	}
 	k8sclient := getK8Client(r)
 	k8StatfulSet, err := k8s.GetStatefulSet(k8sclient, ns, nexus.ServerName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	k8Service, err := k8s.GetService(k8sclient, ns, nexus.ServiceName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	k8Ingress, err := k8s.GetIngress(k8sclient, ns, nexus.IngressName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	artifactory.ServerName = k8StatfulSet.Name
 	artifactory.ServiceName = k8Service.Name
 	artifactory.IngressName = k8Ingress.Name
+	artifactory.Image = containerImage(k8StatfulSet, nexus.ServerName)
 
 	if len(k8Ingress.Spec.Rules) > 0 {
 		artifactory.Host = "https://" + k8Ingress.Spec.Rules[0].Host
@@ -151,8 +152,86 @@ func getArtifactory(w http.ResponseWriter, r *http.Request) {
 	encode(w, &artifactory)
 }
 
+// updateArtifactory updates the image or the custom configuration of an existing artifactory
 func updateArtifactory(w http.ResponseWriter, r *http.Request) {
-	jsonError(w, "operation not available", http.StatusInternalServerError)
+	ns := chi.URLParam(r, "clientId")
+	err := checkActiveClient(r, ns)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	artifactory := bedrock.Artifactory{
+		ArtifactoryID: chi.URLParam(r, "artifactoryId"),
+	}
+	if !validVendor(artifactory.ArtifactoryID, artifactoryVendors()) {
+		writeError(w, notFoundError("unknown artifactoryId %v", artifactory.ArtifactoryID))
+		return
+	}
+	patch := bedrock.ArtifactoryPatch{}
+	err = decode(r, &patch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if patch.Image != nil && !validImage(*patch.Image, "", nexus.Vendor()) {
+		writeError(w, fieldError("image", "image is not available for "+artifactory.ArtifactoryID))
+		return
+	}
+	err = bedrock.Validate(patch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	k8sclient := getK8Client(r)
+	err = patchArtifactory(k8sclient, ns, &artifactory, patch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	encode(w, artifactory)
+}
+
+// patchArtifactory applies the patch to the k8s resources of an existing artifactory
+// and populates the artifactory pointer with the updated data
+func patchArtifactory(kubeCli kubernetes.Interface, ns string, artifactory *bedrock.Artifactory, patch bedrock.ArtifactoryPatch) error {
+	k8Statefulset, err := k8s.GetStatefulSet(kubeCli, ns, nexus.ServerName)
+	if err != nil {
+		return err
+	}
+	if patch.Image != nil && updateContainerImages(k8Statefulset, nexus.StatefulSet(*patch.Image, ns)) {
+		k8Statefulset, err = k8s.UpdateStatefulSet(kubeCli, ns, k8Statefulset)
+		if err != nil {
+			return err
+		}
+	}
+	k8Ingress, _, err := k8s.ApplyIngress(kubeCli, ns, nexus.Ingress(ns))
+	if err != nil {
+		return err
+	}
+
+	artifactory.ServerName = k8Statefulset.Name
+	artifactory.IngressName = k8Ingress.Name
+	artifactory.ServiceName = nexus.ServiceName
+	artifactory.Image = containerImage(k8Statefulset, nexus.ServerName)
+	if len(k8Ingress.Spec.Rules) > 0 {
+		artifactory.Host = "https://" + k8Ingress.Spec.Rules[0].Host
+	}
+
+	if patch.CustomConfig == nil {
+		return nil
+	}
+	artifactory.CustomConfig = *patch.CustomConfig
+	if !artifactory.CustomConfig {
+		return deleteInitJob(kubeCli, ns, nexus.InitJobName, nexus.InitSecretName)
+	}
+	artifactory.Configuration = patch.Configuration
+	secretData, err := json.Marshal(artifactory.Configuration)
+	if err != nil {
+		return err
+	}
+	_, err = applyInitJob(kubeCli, ns, nexus.Secret(ns, secretData), nexus.InitJob(artifactory.Host, ns), nil)
+	return err
 }
 
 func deleteArtifactory(w http.ResponseWriter, r *http.Request) {
@@ -161,24 +240,24 @@ func deleteArtifactory(w http.ResponseWriter, r *http.Request) {
 	artifactory.ArtifactoryID = chi.URLParam(r, "artifactoryId")
 	err := checkClient(r, ns)
 	if err != nil {
-		jsonError(w, "invalid clientId", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	k8sclient := getK8Client(r)
 
 	err = k8s.DeleteStatefulSet(k8sclient, ns, nexus.ServerName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	err = k8s.DeleteService(k8sclient, ns, nexus.ServiceName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	err = k8s.DeleteIngress(k8sclient, ns, nexus.IngressName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
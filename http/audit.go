@@ -0,0 +1,46 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/xumak-grid/bedrock/audit"
+)
+
+// maxAuditLimit is the max number of records returned by the audit endpoint
+const maxAuditLimit = 1000
+
+// getAuditHandler returns the audit records of the client in chronological order,
+// filtered by the query params environmentId, since (RFC3339) and limit
+func getAuditHandler(w http.ResponseWriter, r *http.Request) {
+	q := audit.Query{
+		ClientID:      chi.URLParam(r, "clientId"),
+		EnvironmentID: r.URL.Query().Get("environmentId"),
+		Limit:         audit.DefaultLimit,
+	}
+	var err error
+	if v := r.URL.Query().Get("since"); v != "" {
+		q.Since, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, fieldError("since", "since must be a RFC3339 time, for example 2018-01-02T15:04:05Z"))
+			return
+		}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		q.Limit, err = strconv.Atoi(v)
+		if err != nil || q.Limit <= 0 || q.Limit > maxAuditLimit {
+			writeError(w, fieldError("limit", fmt.Sprintf("limit must be a number between 1 and %v", maxAuditLimit)))
+			return
+		}
+	}
+
+	records, err := getAuditLog(r).Query(q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	encode(w, records)
+}
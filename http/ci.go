@@ -6,6 +6,7 @@ import (
 	"github.com/go-chi/chi"
 	"github.com/xumak-grid/bedrock"
 	"github.com/xumak-grid/bedrock/k8s"
+	"github.com/xumak-grid/bedrock/metrics"
 	"github.com/xumak-grid/bedrock/stack/drone"
 	"k8s.io/client-go/kubernetes"
 )
@@ -24,91 +25,101 @@ func ciVendors() []bedrock.Vendor {
 // createCIHandler the handler to create CI server
 func createCIHandler(w http.ResponseWriter, r *http.Request) {
 	ci := bedrock.CI{}
-	decode(r, &ci)
-	if ci.Image == "" || ci.SecondImage == "" {
-		jsonError(w, "image and secondImage are required", http.StatusBadRequest)
+	err := decode(r, &ci)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	if ci.ScmURL == "" {
-		jsonError(w, "scmURL (Source Control manager URL) is required", http.StatusBadRequest)
+	err = bedrock.Validate(ci)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 	ns := chi.URLParam(r, "clientId")
-	err := checkClient(r, ns)
+	err = checkActiveClient(r, ns)
 	if err != nil {
-		jsonError(w, "invalid clientId", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	if !validVendor(ci.CIID, ciVendors()) {
-		jsonError(w, "unknown ciId", http.StatusBadRequest)
+		writeError(w, fieldError("ciId", "unknown ciId "+ci.CIID))
 		return
 	}
 
 	k8sclient := getK8Client(r)
-	err = createCI(k8sclient, ns, &ci)
+	result, err := createCI(k8sclient, ns, &ci, nil)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(applyStatus(result))
 	encode(w, ci)
 }
 
-// createCI create a new CI server and populates ci pointer with more data
-// also creates k8s resources that are part of the CI server
-func createCI(kubeCli kubernetes.Interface, ns string, ci *bedrock.CI) error {
-	k8Service, err := k8s.CreateService(kubeCli, ns, drone.Service(ns))
+// createCI creates or updates a CI server and populates ci pointer with more data
+// also applies the k8s resources that are part of the CI server, the created resources are recorded in tracker
+// the returned result is k8s.Created when at least one resource was created
+func createCI(kubeCli kubernetes.Interface, ns string, ci *bedrock.CI, tracker *deployTracker) (_ k8s.ApplyResult, err error) {
+	defer func() { metrics.ObserveProvisioning("ci", drone.Vendor().Name, err) }()
+	applied := k8s.Unchanged
+	k8Service, result, err := k8s.ApplyService(kubeCli, ns, drone.Service(ns))
 	if err != nil {
-		return err
+		return applied, err
 	}
-	k8Ingress, err := k8s.CreateIngress(kubeCli, ns, drone.Ingress(ns))
+	trackService(tracker, result, kubeCli, ns, k8Service.Name)
+	applied = applied.Merge(result)
+	k8Ingress, result, err := k8s.ApplyIngress(kubeCli, ns, drone.Ingress(ns))
 	if err != nil {
-		return err
+		return applied, err
 	}
+	trackIngress(tracker, result, kubeCli, ns, k8Ingress.Name)
+	applied = applied.Merge(result)
 	if len(k8Ingress.Spec.Rules) > 0 {
 		ci.Host = "https://" + k8Ingress.Spec.Rules[0].Host
 	}
-	k8Statefulset, err := k8s.CreateStatefulSet(kubeCli, ns, drone.StatefulSet(ci.ScmURL, ci.Host, ns, ci.Image, ci.SecondImage))
+	k8Statefulset, result, err := k8s.ApplyStatefulSet(kubeCli, ns, drone.StatefulSet(ci.ScmURL, ci.Host, ns, ci.Image, ci.SecondImage))
 	if err != nil {
-		return err
+		return applied, err
 	}
+	trackStatefulSet(tracker, result, kubeCli, ns, k8Statefulset.Name)
+	applied = applied.Merge(result)
 
 	// populating CI
 	ci.ServerName = k8Statefulset.Name
 	ci.ServiceName = k8Service.Name
 	ci.IngressName = k8Ingress.Name
 	ci.Image = drone.ServerName
-	return nil
+	return applied, nil
 }
 
 func getCI(w http.ResponseWriter, r *http.Request) {
 	ns := chi.URLParam(r, "clientId")
 	err := checkClient(r, ns)
 	if err != nil {
-		jsonError(w, "invalid clientId", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	ci := bedrock.CI{
 		CIID: chi.URLParam(r, "ciId"),
 	}
 	if !validVendor(ci.CIID, ciVendors()) {
-		jsonError(w, "unknown ciId", http.StatusBadRequest)
+		writeError(w, notFoundError("unknown ciId %v", ci.CIID))
 		return
 	}
 	k8sclient := getK8Client(r)
 	k8StatfulSet, err := k8s.GetStatefulSet(k8sclient, ns, drone.ServerName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	k8Service, err := k8s.GetService(k8sclient, ns, drone.ServiceName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	k8Ingress, err := k8s.GetIngress(k8sclient, ns, drone.IngressName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	ci.ServerName = k8StatfulSet.Name
@@ -121,8 +132,83 @@ func getCI(w http.ResponseWriter, r *http.Request) {
 	}
 	encode(w, &ci)
 }
+
+// updateCI updates the server and agent images of an existing CI server
 func updateCI(w http.ResponseWriter, r *http.Request) {
-	jsonError(w, "operation not available", http.StatusInternalServerError)
+	ns := chi.URLParam(r, "clientId")
+	err := checkActiveClient(r, ns)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ci := bedrock.CI{
+		CIID: chi.URLParam(r, "ciId"),
+	}
+	if !validVendor(ci.CIID, ciVendors()) {
+		writeError(w, notFoundError("unknown ciId %v", ci.CIID))
+		return
+	}
+	patch := bedrock.CIPatch{}
+	err = decode(r, &patch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	k8sclient := getK8Client(r)
+	err = patchCI(k8sclient, ns, &ci, patch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	encode(w, ci)
+}
+
+// patchCI applies the patch to the k8s resources of an existing CI server
+// and populates the ci pointer with the updated data
+func patchCI(kubeCli kubernetes.Interface, ns string, ci *bedrock.CI, patch bedrock.CIPatch) error {
+	k8Statefulset, err := k8s.GetStatefulSet(kubeCli, ns, drone.ServerName)
+	if err != nil {
+		return err
+	}
+	k8Ingress, _, err := k8s.ApplyIngress(kubeCli, ns, drone.Ingress(ns))
+	if err != nil {
+		return err
+	}
+	if len(k8Ingress.Spec.Rules) > 0 {
+		ci.Host = "https://" + k8Ingress.Spec.Rules[0].Host
+	}
+
+	// the images not present in the patch are kept
+	image := containerImage(k8Statefulset, drone.ServerName)
+	if patch.Image != nil {
+		image = *patch.Image
+	}
+	secondImage := containerImage(k8Statefulset, drone.AgentName)
+	if patch.SecondImage != nil {
+		secondImage = *patch.SecondImage
+	}
+	if patch.Image != nil || patch.SecondImage != nil {
+		if !validImage(image, secondImage, drone.Vendor()) {
+			return validationError("image and secondImage are not available for "+ci.CIID,
+				bedrock.FieldError{Field: "image", Message: "image must be one of the vendor images"},
+				bedrock.FieldError{Field: "secondImage", Message: "secondImage must be one of the vendor images"})
+		}
+		desired := drone.StatefulSet(ci.ScmURL, ci.Host, ns, image, secondImage)
+		if updateContainerImages(k8Statefulset, desired) {
+			k8Statefulset, err = k8s.UpdateStatefulSet(kubeCli, ns, k8Statefulset)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	ci.ServerName = k8Statefulset.Name
+	ci.ServiceName = drone.ServiceName
+	ci.IngressName = k8Ingress.Name
+	ci.Image = image
+	ci.SecondImage = secondImage
+	return nil
 }
 func deleteCI(w http.ResponseWriter, r *http.Request) {
 	ci := bedrock.CI{}
@@ -130,24 +216,24 @@ func deleteCI(w http.ResponseWriter, r *http.Request) {
 	ci.CIID = chi.URLParam(r, "ciId")
 	err := checkClient(r, ns)
 	if err != nil {
-		jsonError(w, "invalid clientId", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	k8sclient := getK8Client(r)
 
 	err = k8s.DeleteStatefulSet(k8sclient, ns, drone.ServerName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	err = k8s.DeleteService(k8sclient, ns, drone.ServiceName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	err = k8s.DeleteIngress(k8sclient, ns, drone.IngressName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	encode(w, ci)
@@ -1,66 +1,67 @@
 package http
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 	certclient "github.com/jetstack/cert-manager/pkg/client/clientset/versioned"
 	"github.com/xumak-grid/bedrock"
 	"github.com/xumak-grid/bedrock/k8s"
+	"k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 )
 
 // createClientHandler creates a client that is represented by a namespace
-// the client can include a customConfig to create a full deployment
+// the client can include a customConfig to create a full deployment, in that case
+// the full deployment is created in background and an operation is returned
 // dryRun option helps to return the fullDeploy object without creating any resources
 func createClientHandler(w http.ResponseWriter, r *http.Request) {
 	c := bedrock.Client{}
 	err := decode(r, &c)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-	if c.ClientID == "" {
-		jsonError(w, "clienId is required", http.StatusBadRequest)
+	err = bedrock.Validate(c)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	if c.DryRun && !c.CustomConfig {
-		jsonError(w, "dryRun only available with customConfig", http.StatusBadRequest)
+	if !authorize(w, r, c.ClientID) {
 		return
 	}
-	if c.CustomConfig {
-		if c.Configuration == nil {
-			jsonError(w, "configuration not provided when the request requires cusotomConfig", http.StatusBadRequest)
-			return
-		}
-		if c.Configuration.AdminEmail == "" {
-			jsonError(w, "adminEmail is required", http.StatusBadRequest)
-			return
-		}
-		if c.Configuration.FullCompanyName == "" {
-			jsonError(w, "fullCompanyName is required", http.StatusBadRequest)
-			return
-		}
-		if len(c.Configuration.Environments) < 1 {
-			jsonError(w, "environments are required, minimun 1", http.StatusBadRequest)
-			return
-		}
-		if c.Configuration.AEMInstancesType == "" || c.Configuration.AEMInstancesVersion == "" {
-			jsonError(w, "aemInstancesVersion or aemInstancesType are empty", http.StatusBadRequest)
-			return
-		}
-		if c.Configuration.DispatcherInstancesType == "" || c.Configuration.DispatcherInstancesVersion == "" {
-			jsonError(w, "dispatcherInstancesVersion or dispatcherInstancesType are empty", http.StatusBadRequest)
+
+	kubecli := getK8Client(r)
+	ns, err := k8s.GetNamespace(kubecli, c.ClientID)
+	if err == nil {
+		err = suspendedError(ns)
+	}
+	if err != nil && !k8serrors.IsNotFound(err) {
+		writeError(w, err)
+		return
+	}
+	if c.CustomConfig && c.Configuration.InitialRepositoryType == bedrock.ContentSetupEPCommerce {
+		err = prepareEPCommerce(getPresigner(r), "configuration.epCommerce", c.Configuration.EPObjectType)
+		if err != nil {
+			writeError(w, err)
 			return
 		}
 	}
-
-	kubecli := getK8Client(r)
 	certMClient := getCertManagerClient(r)
+	tracker := &deployTracker{}
+	result := k8s.Unchanged
 	if !c.DryRun {
-		err = createClient(kubecli, certMClient, c)
+		result, err = createClient(kubecli, certMClient, c, tracker)
 		if err != nil {
-			jsonError(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 	}
@@ -72,50 +73,80 @@ func createClientHandler(w http.ResponseWriter, r *http.Request) {
 			encode(w, fullDeploy)
 			return
 		}
+		// the full deploy takes several minutes, it runs in background
+		// and the progress is available in the operation resource
 		aemcli := getAEMClient(r)
-		err := createFullDeploy(&fullDeploy, kubecli, aemcli)
-		if err != nil {
-			jsonError(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.WriteHeader(201)
-		encode(w, fullDeploy)
+		presigner := getPresigner(r)
+		op := operations.create(c.ClientID, fullDeployOperation, fullDeploySteps(fullDeploy))
+		operations.run(func() { runFullDeploy(op, fullDeploy, kubecli, aemcli, presigner, tracker) })
+		w.Header().Set("Location", "/api/v1/operations/"+op.OperationID)
+		w.WriteHeader(http.StatusAccepted)
+		encode(w, op)
 		return
 	}
 
-	w.WriteHeader(201)
+	w.WriteHeader(applyStatus(result))
 	encode(w, c)
 }
 
 // createClient creates a new client represented by a namespace in k8s
 // also a certManager Certificate is created to allow tls endpoints with the ingresses
-func createClient(kubecli kubernetes.Interface, certMClient certclient.Interface, c bedrock.Client) error {
-	ns, err := k8s.CreateNamespace(kubecli, c.ClientID, c.MetaData)
+// an existing client is reused, the certificate is recorded in tracker when is created
+// the namespace is never recorded to allow a new attempt
+func createClient(kubecli kubernetes.Interface, certMClient certclient.Interface, c bedrock.Client, tracker *deployTracker) (k8s.ApplyResult, error) {
+	ns, applied, err := k8s.ApplyNamespace(kubecli, c.ClientID, c.MetaData)
 	if err != nil {
-		return err
+		return applied, err
+	}
+	// the new clients are protected against the deletion until the protection is removed
+	if applied == k8s.Created {
+		ns, err = k8s.SetNamespaceAnnotation(kubecli, ns.Name, k8s.ProtectedAnnotation, "true")
+		if err != nil {
+			return applied, err
+		}
 	}
 
 	// create a new certManager certificate
-	_, err = k8s.CreateCertficate(certMClient, ns.Name)
+	cert, result, err := k8s.ApplyCertificate(certMClient, ns.Name)
 	if err != nil {
-		return err
+		return applied, err
 	}
-	return nil
+	tracker.track(result, "Certificate", ns.Name+"/"+cert.Name, func() error {
+		return k8s.DeleteCertificate(certMClient, ns.Name, cert.Name)
+	})
+	return applied.Merge(result), nil
 }
 
 // ListClients list the clients that are represented by the namespaces
+// only the clients that the principal can access are included, they are filtered, sorted
+// and paginated with the query params described in clientQuery. The X-Total-Count header
+// is the number of clients before the pagination
 func ListClients(w http.ResponseWriter, r *http.Request) {
+	q, err := parseClientQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	principal := getPrincipal(r)
 	kubecli := getK8Client(r)
 	namespaces, err := k8s.GetNamespaces(kubecli)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusNotFound)
+		writeError(w, err)
 		return
 	}
 	clients := []bedrock.Client{}
-	for _, ns := range namespaces {
-		clients = append(clients, bedrock.Client{ClientID: ns.Name, MetaData: ns.Annotations})
+	for i := range namespaces {
+		if !principal.CanAccess(namespaces[i].Name) {
+			continue
+		}
+		client := clientFromNamespace(&namespaces[i])
+		if q.matches(client) {
+			clients = append(clients, client)
+		}
 	}
-	encode(w, clients)
+	q.sort(clients)
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(clients)))
+	encode(w, q.page(clients))
 }
 
 // GetClient returns a client getting information from the namespace
@@ -124,34 +155,212 @@ func GetClient(w http.ResponseWriter, r *http.Request) {
 	kubecli := getK8Client(r)
 	ns, err := k8s.GetNamespace(kubecli, clientID)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusNotFound)
+		writeError(w, err)
 		return
 	}
-	client := &bedrock.Client{}
-	client.ClientID = ns.Name
-	client.MetaData = ns.Annotations
-	encode(w, client)
+	encode(w, clientFromNamespace(ns))
 }
 
-// DeleteClient deletes a client that is represented by a namespace
-func DeleteClient(w http.ResponseWriter, r *http.Request) {
+// updateClientHandler changes the metadata of a client, the keys with a null value are removed
+func updateClientHandler(w http.ResponseWriter, r *http.Request) {
 	clientID := chi.URLParam(r, "clientId")
-	kubecli := getK8Client(r)
-	err := k8s.DeleteNamespace(kubecli, clientID)
+	err := checkClient(r, clientID)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusNotFound)
+		writeError(w, err)
 		return
 	}
-	c := bedrock.Client{ClientID: clientID}
-	encode(w, c)
+	patch := bedrock.ClientPatch{}
+	err = decode(r, &patch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = bedrock.Validate(patch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ns, err := k8s.UpdateNamespaceAnnotations(getK8Client(r), clientID, patch.MetaData)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	encode(w, clientFromNamespace(ns))
 }
 
+// clientFromNamespace returns the client represented by the namespace, the annotations with the
+// bedrock.ReservedMetaDataPrefix are not metadata, they are reported in the status and protected fields
+func clientFromNamespace(ns *v1.Namespace) bedrock.Client {
+	client := bedrock.Client{
+		ClientID:  ns.Name,
+		MetaData:  map[string]string{},
+		Status:    bedrock.ClientActive,
+		Protected: ns.Annotations[k8s.ProtectedAnnotation] == "true",
+	}
+	for k, v := range ns.Annotations {
+		if !strings.HasPrefix(k, bedrock.ReservedMetaDataPrefix) {
+			client.MetaData[k] = v
+		}
+	}
+	switch {
+	case ns.Annotations[k8s.DeletionAnnotation] != "":
+		client.Status = bedrock.ClientDeleting
+	case ns.Annotations[k8s.SuspensionAnnotation] != "":
+		client.Status = bedrock.ClientSuspended
+	}
+	if !ns.CreationTimestamp.IsZero() {
+		createdAt := ns.CreationTimestamp.UTC()
+		client.CreatedAt = &createdAt
+	}
+	return client
+}
+
+// maxClientsLimit is the max number of clients returned by a page of ListClients
+const maxClientsLimit = 500
+
+// clientSortFields are the values of the sort query param of ListClients, a - prefix reverses the order
+var clientSortFields = []string{"clientId", "createdAt", "status"}
+
+// clientQuery filters, sorts and paginates the clients of ListClients, it is parsed from the query params:
+// selector is a kubernetes label selector that matches the metadata, for example team=web,tier in (gold,silver),
+// status is a list of statuses separated by commas, createdAfter and createdBefore are RFC3339 times,
+// sort is one of clientSortFields and limit and offset are the page, without limit all the clients are returned
+type clientQuery struct {
+	selector      labels.Selector
+	status        map[string]bool
+	createdAfter  time.Time
+	createdBefore time.Time
+	sortBy        string
+	descending    bool
+	limit         int
+	offset        int
+}
+
+func parseClientQuery(values url.Values) (*clientQuery, error) {
+	q := &clientQuery{selector: labels.Everything(), sortBy: "clientId"}
+	var err error
+	if v := values.Get("selector"); v != "" {
+		q.selector, err = labels.Parse(v)
+		if err != nil {
+			return nil, fieldError("selector", "selector must be a label selector of the metadata, for example team=web: "+err.Error())
+		}
+	}
+	if v := values.Get("status"); v != "" {
+		q.status = map[string]bool{}
+		for _, status := range strings.Split(v, ",") {
+			switch status {
+			case bedrock.ClientActive, bedrock.ClientSuspended, bedrock.ClientDeleting:
+				q.status[status] = true
+			default:
+				return nil, fieldError("status", fmt.Sprintf("status must be a list of %v, %v or %v", bedrock.ClientActive, bedrock.ClientSuspended, bedrock.ClientDeleting))
+			}
+		}
+	}
+	for name, t := range map[string]*time.Time{"createdAfter": &q.createdAfter, "createdBefore": &q.createdBefore} {
+		if v := values.Get(name); v != "" {
+			*t, err = time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fieldError(name, name+" must be a RFC3339 time, for example 2018-01-02T15:04:05Z")
+			}
+		}
+	}
+	if v := values.Get("sort"); v != "" {
+		q.sortBy, q.descending = strings.TrimPrefix(v, "-"), strings.HasPrefix(v, "-")
+		if !validSortField(q.sortBy) {
+			return nil, fieldError("sort", "sort must be one of "+strings.Join(clientSortFields, ", ")+", with a - prefix for the descending order")
+		}
+	}
+	if v := values.Get("limit"); v != "" {
+		q.limit, err = strconv.Atoi(v)
+		if err != nil || q.limit <= 0 || q.limit > maxClientsLimit {
+			return nil, fieldError("limit", fmt.Sprintf("limit must be a number between 1 and %v", maxClientsLimit))
+		}
+	}
+	if v := values.Get("offset"); v != "" {
+		q.offset, err = strconv.Atoi(v)
+		if err != nil || q.offset < 0 {
+			return nil, fieldError("offset", "offset must be a positive number")
+		}
+	}
+	return q, nil
+}
+
+func validSortField(field string) bool {
+	for _, f := range clientSortFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// matches returns true when the client satisfies all the filters of the query
+func (q *clientQuery) matches(c bedrock.Client) bool {
+	if !q.selector.Matches(labels.Set(c.MetaData)) {
+		return false
+	}
+	if q.status != nil && !q.status[c.Status] {
+		return false
+	}
+	if !q.createdAfter.IsZero() && (c.CreatedAt == nil || !c.CreatedAt.After(q.createdAfter)) {
+		return false
+	}
+	if !q.createdBefore.IsZero() && (c.CreatedAt == nil || !c.CreatedAt.Before(q.createdBefore)) {
+		return false
+	}
+	return true
+}
+
+// sort sorts the clients by the sort field, the ties are sorted by clientId
+func (q *clientQuery) sort(clients []bedrock.Client) {
+	less := func(a, b bedrock.Client) bool {
+		switch q.sortBy {
+		case "createdAt":
+			if !createdAt(a).Equal(createdAt(b)) {
+				return createdAt(a).Before(createdAt(b))
+			}
+		case "status":
+			if a.Status != b.Status {
+				return a.Status < b.Status
+			}
+		}
+		return a.ClientID < b.ClientID
+	}
+	sort.SliceStable(clients, func(i, j int) bool {
+		if q.descending {
+			return less(clients[j], clients[i])
+		}
+		return less(clients[i], clients[j])
+	})
+}
+
+// page returns the clients of the page defined by offset and limit
+func (q *clientQuery) page(clients []bedrock.Client) []bedrock.Client {
+	if q.offset >= len(clients) {
+		return []bedrock.Client{}
+	}
+	clients = clients[q.offset:]
+	if q.limit > 0 && q.limit < len(clients) {
+		clients = clients[:q.limit]
+	}
+	return clients
+}
+
+// createdAt returns the creation time of the client, the zero time when it is unknown
+func createdAt(c bedrock.Client) time.Time {
+	if c.CreatedAt == nil {
+		return time.Time{}
+	}
+	return *c.CreatedAt
+}
+
+// checkClient returns a not found error when the client does not exist
 func checkClient(r *http.Request, clientID string) error {
 	kubecli := getK8Client(r)
 	// check if namespace exists
 	_, err := k8s.GetNamespace(kubecli, clientID)
-	if err != nil {
-		return err
+	if k8serrors.IsNotFound(err) {
+		return notFoundError("client %v not found", clientID)
 	}
-	return nil
+	return err
 }
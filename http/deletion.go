@@ -0,0 +1,398 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/awscli"
+	"github.com/xumak-grid/bedrock/k8s"
+	"github.com/xumak-grid/bedrock/secrets"
+	"github.com/xumak-grid/bedrock/stack/gogs"
+	"github.com/xumak-grid/bedrock/stack/nexus"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultDeletionGracePeriod is the time before deleting a client when the request has no gracePeriod
+	DefaultDeletionGracePeriod = 24 * time.Hour
+	// maxDeletionGracePeriod is the max gracePeriod of a deletion
+	maxDeletionGracePeriod = 30 * 24 * time.Hour
+	// ExportBucketEnvVar is the S3 bucket of the exports of the deleted clients
+	ExportBucketEnvVar = "BEDROCK_EXPORT_BUCKET"
+	// exportUploadHours is the expiration of the pre-signed urls used by the export jobs
+	exportUploadHours = 24
+)
+
+// exportStack is a stack exported to S3 before deleting a client
+type exportStack struct {
+	name string
+	// server is the statefulSet of the stack, the stack is exported only when it exists
+	server  string
+	jobName string
+	job     func(namespace, destination, uploadURL string) *batchv1.Job
+}
+
+var exportStacks = []exportStack{
+	{name: "scm", server: gogs.ServerName, jobName: gogs.ExportJobName, job: gogs.ExportJob},
+	{name: "artifactory", server: nexus.ServerName, jobName: nexus.ExportJobName, job: nexus.ExportJob},
+}
+
+// DeleteClient schedules the deletion of a client after the grace period in ?gracePeriod=24h,
+// with ?export=true the scm repositories and the artifactory blobs are exported to S3 before,
+// the protected clients are not deleted and a client already scheduled keeps its deletion
+func DeleteClient(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "clientId")
+	err := checkClient(r, clientID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	kubecli := getK8Client(r)
+	ns, err := k8s.GetNamespace(kubecli, clientID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if ns.Annotations[k8s.ProtectedAnnotation] == "true" {
+		writeError(w, conflictError("client %v is protected, remove the protection before deleting it", clientID))
+		return
+	}
+	now := time.Now().UTC()
+	deletion, err := getDeletion(ns)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if deletion != nil {
+		err = deletionStatus(kubecli, deletion, now)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		encode(w, deletion)
+		return
+	}
+
+	gracePeriod := DefaultDeletionGracePeriod
+	if v := r.URL.Query().Get("gracePeriod"); v != "" {
+		gracePeriod, err = time.ParseDuration(v)
+		if err != nil || gracePeriod < 0 || gracePeriod > maxDeletionGracePeriod {
+			writeError(w, fieldError("gracePeriod", fmt.Sprintf("gracePeriod must be a duration up to %v", maxDeletionGracePeriod)))
+			return
+		}
+	}
+	export := false
+	if v := r.URL.Query().Get("export"); v != "" {
+		export, err = strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, fieldError("export", "export must be true or false"))
+			return
+		}
+	}
+	// the stack servers of a suspended client are scaled to zero, the export jobs can not read them
+	if export {
+		err = suspendedError(ns)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	deletion = &bedrock.ClientDeletion{
+		ClientID:    clientID,
+		RequestedAt: now,
+		RequestedBy: getPrincipal(r).Subject,
+		DeleteAt:    now.Add(gracePeriod),
+		Exports:     []bedrock.ClientExport{},
+	}
+	if export {
+		err = startExports(kubecli, getPresigner(r), deletion)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	err = saveDeletion(kubecli, deletion)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = deletionStatus(kubecli, deletion, now)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	encode(w, deletion)
+}
+
+// getDeletionHandler returns the deletion of a client scheduled for deletion
+func getDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "clientId")
+	kubecli := getK8Client(r)
+	deletion, err := loadDeletion(r, clientID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = deletionStatus(kubecli, deletion, time.Now().UTC())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	encode(w, deletion)
+}
+
+// cancelDeletionHandler cancels the deletion of a client, the export jobs are deleted
+// but the exports already uploaded are kept in S3
+func cancelDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "clientId")
+	kubecli := getK8Client(r)
+	deletion, err := loadDeletion(r, clientID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	for _, s := range exportStacks {
+		err = k8s.DeleteJob(kubecli, clientID, s.jobName)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			writeError(w, err)
+			return
+		}
+	}
+	_, err = k8s.SetNamespaceAnnotation(kubecli, clientID, k8s.DeletionAnnotation, "")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	deletion.Status = bedrock.DeletionCancelled
+	encode(w, deletion)
+}
+
+// protectClientHandler prevents the deletion of the client until the protection is removed
+func protectClientHandler(w http.ResponseWriter, r *http.Request) {
+	setProtection(w, r, "true")
+}
+
+// unprotectClientHandler removes the protection of the client, it can be deleted after
+func unprotectClientHandler(w http.ResponseWriter, r *http.Request) {
+	setProtection(w, r, "")
+}
+
+func setProtection(w http.ResponseWriter, r *http.Request, value string) {
+	clientID := chi.URLParam(r, "clientId")
+	err := checkClient(r, clientID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ns, err := k8s.SetNamespaceAnnotation(getK8Client(r), clientID, k8s.ProtectedAnnotation, value)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	encode(w, clientFromNamespace(ns))
+}
+
+// loadDeletion returns the deletion of the client, a not found error when it is not scheduled for deletion
+func loadDeletion(r *http.Request, clientID string) (*bedrock.ClientDeletion, error) {
+	err := checkClient(r, clientID)
+	if err != nil {
+		return nil, err
+	}
+	ns, err := k8s.GetNamespace(getK8Client(r), clientID)
+	if err != nil {
+		return nil, err
+	}
+	deletion, err := getDeletion(ns)
+	if err != nil {
+		return nil, err
+	}
+	if deletion == nil {
+		return nil, notFoundError("client %v is not scheduled for deletion", clientID)
+	}
+	return deletion, nil
+}
+
+// getDeletion returns the deletion stored in the namespace, nil when the client is not scheduled for deletion
+func getDeletion(ns *v1.Namespace) (*bedrock.ClientDeletion, error) {
+	v, ok := ns.Annotations[k8s.DeletionAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	deletion := &bedrock.ClientDeletion{}
+	err := json.Unmarshal([]byte(v), deletion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deletion of the client %v: %v", ns.Name, err)
+	}
+	return deletion, nil
+}
+
+// saveDeletion stores the deletion in the namespace of the client, the status is computed on every read
+func saveDeletion(kubecli kubernetes.Interface, deletion *bedrock.ClientDeletion) error {
+	stored := *deletion
+	stored.Status = ""
+	stored.Exports = make([]bedrock.ClientExport, len(deletion.Exports))
+	for i, e := range deletion.Exports {
+		stored.Exports[i] = bedrock.ClientExport{Stack: e.Stack, Location: e.Location}
+	}
+	value, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	_, err = k8s.SetNamespaceAnnotation(kubecli, deletion.ClientID, k8s.DeletionAnnotation, string(value))
+	return err
+}
+
+// startExports starts a job for every stack of the client that uploads its data to the export bucket,
+// the jobs receive a pre-signed url of their object instead of aws credentials
+func startExports(kubecli kubernetes.Interface, presigner awscli.Presigner, deletion *bedrock.ClientDeletion) error {
+	bucket := os.Getenv(ExportBucketEnvVar)
+	if bucket == "" {
+		return fieldError("export", ExportBucketEnvVar+" is not configured, the clients can not be exported")
+	}
+	ns := deletion.ClientID
+	prefix := fmt.Sprintf("%v/%v", ns, deletion.RequestedAt.Format("20060102T150405Z"))
+	for _, s := range exportStacks {
+		_, err := k8s.GetStatefulSet(kubecli, ns, s.server)
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// a job of a cancelled deletion is replaced to export to the new location
+		err = k8s.DeleteJob(kubecli, ns, s.jobName)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		key := prefix + "/" + s.name + ".tar.gz"
+		uploadURL, err := presigner.PreSignedUploadURL(awscli.NewS3Object(bucket, key), exportUploadHours)
+		if err != nil {
+			return upstreamError("aws", err)
+		}
+		location := "s3://" + bucket + "/" + key
+		_, err = k8s.CreateJob(kubecli, ns, s.job(ns, location, uploadURL))
+		if err != nil {
+			return err
+		}
+		deletion.Exports = append(deletion.Exports, bedrock.ClientExport{Stack: s.name, Location: location})
+	}
+	return nil
+}
+
+// deletionStatus sets the status of the exports from their jobs and the status of the deletion
+func deletionStatus(kubecli kubernetes.Interface, deletion *bedrock.ClientDeletion, now time.Time) error {
+	exported, failed := true, false
+	for i, e := range deletion.Exports {
+		status, err := exportStatus(kubecli, deletion.ClientID, e.Stack)
+		if err != nil {
+			return err
+		}
+		deletion.Exports[i].Status = status
+		exported = exported && status == bedrock.OperationSucceeded
+		failed = failed || status == bedrock.OperationFailed
+	}
+	switch {
+	case failed:
+		deletion.Status = bedrock.DeletionExportFailed
+	case exported && !now.Before(deletion.DeleteAt):
+		deletion.Status = bedrock.DeletionReady
+	default:
+		deletion.Status = bedrock.DeletionPending
+	}
+	return nil
+}
+
+// exportStatus returns the status of the export job of the stack, a deleted job is failed
+func exportStatus(kubecli kubernetes.Interface, ns, stack string) (string, error) {
+	jobName := ""
+	for _, s := range exportStacks {
+		if s.name == stack {
+			jobName = s.jobName
+		}
+	}
+	job, err := k8s.GetJob(kubecli, ns, jobName)
+	if k8serrors.IsNotFound(err) {
+		return bedrock.OperationFailed, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return jobStatus(job), nil
+}
+
+// finalizeDeletions deletes the secrets and the namespace of the clients whose deletion is ready,
+// the protected clients are skipped, it returns the deleted clients and the last error
+func finalizeDeletions(kubecli kubernetes.Interface, secretService secrets.SecretService, now time.Time) ([]string, error) {
+	namespaces, err := k8s.GetNamespaces(kubecli)
+	if err != nil {
+		return nil, err
+	}
+	deleted := []string{}
+	var lastErr error
+	for i := range namespaces {
+		ns := &namespaces[i]
+		deletion, err := getDeletion(ns)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if deletion == nil || ns.Annotations[k8s.ProtectedAnnotation] == "true" {
+			continue
+		}
+		err = deletionStatus(kubecli, deletion, now)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if deletion.Status != bedrock.DeletionReady {
+			continue
+		}
+		// the secrets are deleted first, the namespace keeps the deletion to retry a failed clean up
+		err = secretService.CleanUp(getClientSecretPath(ns.Name))
+		if err != nil {
+			lastErr = fmt.Errorf("error deleting the secrets of the client %v: %v", ns.Name, err)
+			continue
+		}
+		err = k8s.DeleteNamespace(kubecli, ns.Name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		deleted = append(deleted, ns.Name)
+	}
+	return deleted, lastErr
+}
+
+// FinalizeDeletions deletes every interval the clients whose grace period ended and whose exports
+// succeeded. It returns when the server is closed
+func (s *Server) FinalizeDeletions(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+		}
+		deps := s.Dependencies()
+		deleted, err := finalizeDeletions(deps.KubeClient, deps.Secrets, time.Now().UTC())
+		for _, clientID := range deleted {
+			s.log.WithField("client", clientID).Info("Client deleted")
+		}
+		if err != nil {
+			s.log.WithError(err).Error("Error deleting the clients scheduled for deletion")
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package http
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	certclient "github.com/jetstack/cert-manager/pkg/client/clientset/versioned"
+	aemclientset "github.com/xumak-grid/aem-operator/pkg/generated/clientset/versioned"
+	"github.com/xumak-grid/bedrock/awscli"
+	"github.com/xumak-grid/bedrock/k8s"
+	"github.com/xumak-grid/bedrock/secrets"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Dependencies are the clients shared by all the requests of the server
+type Dependencies struct {
+	KubeClient        kubernetes.Interface
+	AEMClient         aemclientset.Interface
+	CertManagerClient certclient.Interface
+	// Secrets stores the passwords of the instances, it is required
+	Secrets secrets.SecretService
+	// Presigner creates the download urls of the toolbelt box and the EP init packages
+	Presigner awscli.Presigner
+}
+
+// NewDependencies creates the clients from a kubernetes config and the presigner that uses
+// the aws credentials in the env vars, the calls made by the clients are observed in the metrics.
+// The secret service is selected by the caller, see Dependencies.Secrets
+func NewDependencies(cfg *rest.Config) (*Dependencies, error) {
+	k8s.Instrument(cfg)
+	kubecli, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the kubernetes client: %v", err)
+	}
+	aemcli, err := k8s.AEMClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the aem client: %v", err)
+	}
+	certMClient, err := k8s.CertManagerClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the certManager client: %v", err)
+	}
+	return &Dependencies{
+		KubeClient:        kubecli,
+		AEMClient:         aemcli,
+		CertManagerClient: certMClient,
+		Presigner:         awscli.EnvPresigner{},
+	}, nil
+}
+
+// LoadDependencies creates the clients from the KUBECONFIG file or the in cluster config
+func LoadDependencies() (*Dependencies, error) {
+	cfg, err := k8s.BuildKubeConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error building the kubernetes config: %v", err)
+	}
+	return NewDependencies(cfg)
+}
+
+// WatchKubeConfig checks the kubeconfig file every interval and replaces the
+// dependencies of the server when the file changes, the current clients are kept
+// when the new config is not valid and the secret service is never replaced.
+// It returns when the server is closed
+func (s *Server) WatchKubeConfig(path string, interval time.Duration) {
+	modTime := time.Time{}
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			s.log.WithError(err).Error("Error checking kubeconfig")
+			continue
+		}
+		if !info.ModTime().After(modTime) {
+			continue
+		}
+		modTime = info.ModTime()
+		deps, err := LoadDependencies()
+		if err != nil {
+			s.log.WithError(err).Error("Error reloading kubeconfig, keeping the current clients")
+			continue
+		}
+		deps.Secrets = s.Dependencies().Secrets
+		s.SetDependencies(deps)
+		s.log.WithField("kubeconfig", path).Info("Kubernetes clients reloaded")
+	}
+}
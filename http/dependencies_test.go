@@ -0,0 +1,167 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	certfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	aemfake "github.com/xumak-grid/aem-operator/pkg/generated/clientset/versioned/fake"
+	"github.com/xumak-grid/bedrock/auth"
+	"github.com/xumak-grid/bedrock/secrets/memory"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// testKubeConfig is a kubeconfig of a cluster that is never contacted
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+users:
+- name: test
+  user:
+    token: test
+`
+
+// fakeDependencies returns dependencies with fake clientsets, the kubernetes one seeded with objects
+func fakeDependencies(objects ...runtime.Object) *Dependencies {
+	return &Dependencies{
+		KubeClient:        fake.NewSimpleClientset(objects...),
+		AEMClient:         aemfake.NewSimpleClientset(),
+		CertManagerClient: certfake.NewSimpleClientset(),
+		Secrets:           memory.NewSecretService(),
+		Presigner:         &stubPresigner{},
+	}
+}
+
+func TestSetDependencies(t *testing.T) {
+	acme := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "acme", Labels: map[string]string{"grid": "true"}}}
+	s := NewServer(nil, fakeDependencies(acme))
+	s.Auth = auth.Anonymous()
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	get := func() int {
+		res, err := http.Get(server.URL + "/api/v1/clients/acme")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	if status := get(); status != http.StatusOK {
+		t.Fatalf("expected the client of the first clientset, got %v", status)
+	}
+	// the next requests use the new clients without restarting the server
+	s.SetDependencies(fakeDependencies())
+	if status := get(); status != http.StatusNotFound {
+		t.Errorf("expected the clients replaced, got %v", status)
+	}
+}
+
+func TestOpenRequiresDependencies(t *testing.T) {
+	withoutSecrets := fakeDependencies()
+	withoutSecrets.Secrets = nil
+	tests := []struct {
+		name string
+		auth auth.Authenticator
+		deps *Dependencies
+	}{
+		{name: "without authenticator", deps: fakeDependencies()},
+		{name: "without clients", auth: auth.Anonymous()},
+		{name: "without secret service", auth: auth.Anonymous(), deps: withoutSecrets},
+	}
+	for _, test := range tests {
+		s := NewServer(nil, test.deps)
+		s.Auth = test.auth
+		s.Addr = "127.0.0.1:0"
+		if err := s.Open(); err == nil {
+			s.Close()
+			t.Errorf("%v: expected an error", test.name)
+		}
+	}
+}
+
+func TestNewDependencies(t *testing.T) {
+	deps, err := NewDependencies(&rest.Config{Host: "https://127.0.0.1:6443"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deps.KubeClient == nil || deps.AEMClient == nil || deps.CertManagerClient == nil || deps.Presigner == nil {
+		t.Errorf("expected all the clients, got %+v", deps)
+	}
+	if deps.Secrets != nil {
+		t.Error("expected the secret service selected by the caller")
+	}
+
+	defer os.Setenv("KUBECONFIG", os.Getenv("KUBECONFIG"))
+	os.Setenv("KUBECONFIG", filepath.Join(os.TempDir(), "bedrock-missing-kubeconfig"))
+	if _, err := LoadDependencies(); err == nil {
+		t.Error("expected an error with a missing kubeconfig")
+	}
+}
+
+func TestWatchKubeConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config")
+	err = ioutil.WriteFile(path, []byte(testKubeConfig), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("KUBECONFIG", os.Getenv("KUBECONFIG"))
+	os.Setenv("KUBECONFIG", path)
+
+	initial := fakeDependencies()
+	s := NewServer(nil, initial)
+	done := make(chan struct{})
+	go func() {
+		s.WatchKubeConfig(path, 10*time.Millisecond)
+		close(done)
+	}()
+	// changes waits until the watcher notices a change of the file
+	changes := func(content string, at time.Time) {
+		ioutil.WriteFile(path, []byte(content), 0600)
+		os.Chtimes(path, at, at)
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	changes("not a kubeconfig", time.Now().Add(time.Minute))
+	if s.Dependencies() != initial {
+		t.Fatal("expected the current clients kept with an invalid kubeconfig")
+	}
+	changes(testKubeConfig, time.Now().Add(2*time.Minute))
+	reloaded := s.Dependencies()
+	if reloaded == initial {
+		t.Fatal("expected the clients reloaded")
+	}
+	if reloaded.Secrets != initial.Secrets {
+		t.Error("expected the secret service kept")
+	}
+
+	s.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("expected the watcher stopped with the server")
+	}
+}
@@ -1,10 +1,14 @@
 package http
 
 import (
+	"fmt"
 	"log"
+	"time"
 
 	aemclientset "github.com/xumak-grid/aem-operator/pkg/generated/clientset/versioned"
 	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/awscli"
+	"github.com/xumak-grid/bedrock/metrics"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -109,6 +113,8 @@ func prepareFullDeploy(c bedrock.Client) bedrock.FullDeploy {
 					Name:             repoName,
 					Owner:            orgName,
 					ContentSetupType: c.Configuration.InitialRepositoryType,
+					EPObjectType:     c.Configuration.EPObjectType,
+					BRObjectType:     c.Configuration.BRObjectType,
 				},
 			},
 		},
@@ -128,36 +134,116 @@ func prepareFullDeploy(c bedrock.Client) bedrock.FullDeploy {
 	return fullDeployment
 }
 
+// deployProgress receives the progress of each step in a full deploy,
+// start returns an error when the step must not run because the deploy was interrupted
+type deployProgress interface {
+	start(step string) error
+	finish(step string, err error)
+}
+
+const (
+	artifactoryStep = "artifactory"
+	scmStep         = "scm"
+	ciStep          = "ci"
+	toolbeltStep    = "toolbelt"
+)
+
+// aemDeploymentStep returns the name of the step that creates the AEMDeployment of an environment
+func aemDeploymentStep(environmentID string) string {
+	return "aem/" + environmentID
+}
+
+// fullDeploySteps returns the name of the steps executed by createFullDeploy in order
+func fullDeploySteps(fullDeploy bedrock.FullDeploy) []string {
+	steps := []string{}
+	for _, i := range fullDeploy.AEMDeployments {
+		steps = append(steps, aemDeploymentStep(i.EnvironmentID))
+	}
+	return append(steps, artifactoryStep, scmStep, ciStep, toolbeltStep)
+}
+
 // createFullDeploy creates a full deployment this action includes AEMDeployments, an Artifactory, a SCM and a CI resources
-func createFullDeploy(fullDeploy *bedrock.FullDeploy, kubecli kubernetes.Interface, aemcli aemclientset.Interface) error {
+// resources that already exist are updated to the desired state so a failed full deploy can be executed again
+// the progress of every step is reported to progress and the created resources are recorded in tracker,
+// it stops before the next step when progress is interrupted
+func createFullDeploy(fullDeploy *bedrock.FullDeploy, kubecli kubernetes.Interface, aemcli aemclientset.Interface, presigner awscli.Presigner, progress deployProgress, tracker *deployTracker) error {
 
 	for _, i := range fullDeploy.AEMDeployments {
-		err := createAEMDeployment(aemcli, i)
+		step := aemDeploymentStep(i.EnvironmentID)
+		err := progress.start(step)
+		if err != nil {
+			return err
+		}
+		_, err = createAEMDeployment(aemcli, i, tracker)
+		progress.finish(step, err)
 		if err != nil {
 			return err
 		}
 	}
 
-	err := createArtifactory(kubecli, fullDeploy.Client.ClientID, &fullDeploy.Artifactory)
+	err := progress.start(artifactoryStep)
+	if err != nil {
+		return err
+	}
+	_, err = createArtifactory(kubecli, fullDeploy.Client.ClientID, &fullDeploy.Artifactory, tracker)
+	progress.finish(artifactoryStep, err)
 	if err != nil {
 		return err
 	}
 
-	err = createSCM(kubecli, fullDeploy.Client.ClientID, &fullDeploy.SCM)
+	err = progress.start(scmStep)
+	if err != nil {
+		return err
+	}
+	_, err = createSCM(kubecli, fullDeploy.Client.ClientID, &fullDeploy.SCM, tracker)
+	progress.finish(scmStep, err)
 	if err != nil {
 		return err
 	}
 
+	err = progress.start(ciStep)
+	if err != nil {
+		return err
+	}
 	fullDeploy.CI.ScmURL = fullDeploy.SCM.Host
-	err = createCI(kubecli, fullDeploy.Client.ClientID, &fullDeploy.CI)
+	_, err = createCI(kubecli, fullDeploy.Client.ClientID, &fullDeploy.CI, tracker)
+	progress.finish(ciStep, err)
 	if err != nil {
 		return err
 	}
 
-	err = createToolbelt(kubecli, &fullDeploy.Toolbelt)
+	err = progress.start(toolbeltStep)
+	if err != nil {
+		return err
+	}
+	_, err = createToolbelt(kubecli, presigner, &fullDeploy.Toolbelt, tracker)
+	progress.finish(toolbeltStep, err)
 	if err != nil {
 		log.Printf("error: toolbelt not created reason: %v", err.Error())
 	}
 
 	return nil
 }
+
+// runFullDeploy executes createFullDeploy in background reporting the progress to the operation
+// when the full deploy fails the resources recorded in tracker are removed unless the client requires to keep them
+func runFullDeploy(op bedrock.Operation, fullDeploy bedrock.FullDeploy, kubecli kubernetes.Interface, aemcli aemclientset.Interface, presigner awscli.Presigner, tracker *deployTracker) {
+	progress := operationProgress{store: operations, id: op.OperationID}
+	start := time.Now()
+	err := createFullDeploy(&fullDeploy, kubecli, aemcli, presigner, progress, tracker)
+	metrics.ObserveFullDeploy(start, err)
+	if err != nil {
+		log.Printf("error: full deploy for %v failed, operation %v: %v", op.ClientID, op.OperationID, err.Error())
+		if !fullDeploy.Client.KeepOnFailure {
+			rbErr := tracker.rollback()
+			if rbErr != nil {
+				err = fmt.Errorf("%v; %v", err.Error(), rbErr.Error())
+			} else {
+				operations.rolledBack(op.OperationID)
+			}
+		}
+		operations.finish(op.OperationID, nil, err)
+		return
+	}
+	operations.finish(op.OperationID, &fullDeploy, nil)
+}
@@ -14,7 +14,7 @@ func getDispatcherConfigHandler(w http.ResponseWriter, r *http.Request) {
 	ns := chi.URLParam(r, "clientId")
 	err := checkClient(r, ns)
 	if err != nil {
-		jsonError(w, "invalid clientId", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	env := chi.URLParam(r, "environmentId")
@@ -22,7 +22,7 @@ func getDispatcherConfigHandler(w http.ResponseWriter, r *http.Request) {
 	kubecli := getK8Client(r)
 	k8scm, err := k8s.GetConfigMap(kubecli, ns, env+"-dispatcher")
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	cmap := bedrock.ConfigMap{
@@ -40,20 +40,24 @@ func updateDispatcherConfigHandler(w http.ResponseWriter, r *http.Request) {
 	ns := chi.URLParam(r, "clientId")
 	err := checkClient(r, ns)
 	if err != nil {
-		jsonError(w, "invalid clientId", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	cmap := bedrock.ConfigMap{}
-	decode(r, &cmap)
+	err = decode(r, &cmap)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 	if cmap.Name == "" {
-		jsonError(w, "name is required", http.StatusBadRequest)
+		writeError(w, fieldError("name", "name is required"))
 		return
 	}
 
 	kubecli := getK8Client(r)
 	err = k8s.UpdateConfigMap(kubecli, ns, cmap.Name, cmap.Data)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	cmap.ClientID = ns
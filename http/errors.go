@@ -0,0 +1,133 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/xumak-grid/bedrock"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Error is an api error with a machine-readable reason,
+// writeError uses it to build the response with the right status code
+type Error struct {
+	Status  int
+	Reason  string
+	Message string
+	Details []bedrock.FieldError
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// validationError returns a 400 error, details are the invalid fields
+func validationError(message string, details ...bedrock.FieldError) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Reason:  bedrock.ReasonValidation,
+		Message: message,
+		Details: details,
+	}
+}
+
+// fieldError returns a validation error of a single field
+func fieldError(field, message string) *Error {
+	return validationError(message, bedrock.FieldError{Field: field, Message: message})
+}
+
+// notFoundError returns a 404 error
+func notFoundError(format string, args ...interface{}) *Error {
+	return &Error{
+		Status:  http.StatusNotFound,
+		Reason:  bedrock.ReasonNotFound,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// conflictError returns a 409 error
+func conflictError(format string, args ...interface{}) *Error {
+	return &Error{
+		Status:  http.StatusConflict,
+		Reason:  bedrock.ReasonConflict,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// upstreamError returns a 502 error for a failure of a dependency, for example the secrets backend or aws
+func upstreamError(dependency string, err error) *Error {
+	return &Error{
+		Status:  http.StatusBadGateway,
+		Reason:  bedrock.ReasonUpstreamFailure,
+		Message: fmt.Sprintf("%v: %v", dependency, err),
+	}
+}
+
+// toError converts err to an *Error, the kubernetes status errors are mapped by reason,
+// the network errors are upstream failures and any other error is internal
+func toError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	if errs, ok := err.(bedrock.ValidationErrors); ok {
+		return validationError("invalid request", errs...)
+	}
+	if status, ok := err.(k8serrors.APIStatus); ok {
+		return kubernetesError(err, status.Status())
+	}
+	if isNetworkError(err) {
+		return &Error{
+			Status:  http.StatusServiceUnavailable,
+			Reason:  bedrock.ReasonUpstreamFailure,
+			Message: err.Error(),
+		}
+	}
+	return &Error{
+		Status:  http.StatusInternalServerError,
+		Reason:  bedrock.ReasonInternal,
+		Message: err.Error(),
+	}
+}
+
+// kubernetesError maps a kubernetes status error to an api error,
+// the errors that are not caused by the request are upstream failures
+func kubernetesError(err error, status metav1.Status) *Error {
+	switch k8serrors.ReasonForError(err) {
+	case metav1.StatusReasonNotFound:
+		return notFoundError("%v", err.Error())
+	case metav1.StatusReasonAlreadyExists, metav1.StatusReasonConflict:
+		return conflictError("%v", err.Error())
+	case metav1.StatusReasonInvalid, metav1.StatusReasonBadRequest:
+		e := validationError(err.Error())
+		if status.Details != nil {
+			for _, c := range status.Details.Causes {
+				e.Details = append(e.Details, bedrock.FieldError{Field: c.Field, Message: c.Message})
+			}
+		}
+		return e
+	case metav1.StatusReasonTimeout, metav1.StatusReasonServerTimeout, metav1.StatusReasonServiceUnavailable:
+		return &Error{
+			Status:  http.StatusServiceUnavailable,
+			Reason:  bedrock.ReasonUpstreamFailure,
+			Message: err.Error(),
+		}
+	}
+	return upstreamError("kubernetes", err)
+}
+
+func isNetworkError(err error) bool {
+	switch err.(type) {
+	case *url.Error, net.Error:
+		return true
+	}
+	return false
+}
+
+// writeError writes err as a JSON error, see toError
+func writeError(w http.ResponseWriter, err error) {
+	e := toError(err)
+	writeJSONError(w, &JSONError{Code: e.Status, Reason: e.Reason, Msg: e.Message, Details: e.Details})
+}
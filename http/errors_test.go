@@ -0,0 +1,47 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/xumak-grid/bedrock"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestToError(t *testing.T) {
+	resource := schema.GroupResource{Resource: "statefulsets"}
+	invalid := k8serrors.NewInvalid(schema.GroupKind{Kind: "StatefulSet"}, "gogs-server", field.ErrorList{
+		field.Required(field.NewPath("spec", "replicas"), "replicas is required"),
+	})
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		reason string
+	}{
+		{"api error", fieldError("image", "image is required"), http.StatusBadRequest, bedrock.ReasonValidation},
+		{"validation errors", bedrock.Validate(bedrock.CI{}), http.StatusBadRequest, bedrock.ReasonValidation},
+		{"not found", k8serrors.NewNotFound(resource, "gogs-server"), http.StatusNotFound, bedrock.ReasonNotFound},
+		{"already exists", k8serrors.NewAlreadyExists(resource, "gogs-server"), http.StatusConflict, bedrock.ReasonConflict},
+		{"invalid", invalid, http.StatusBadRequest, bedrock.ReasonValidation},
+		{"forbidden", k8serrors.NewForbidden(resource, "gogs-server", errors.New("rbac")), http.StatusBadGateway, bedrock.ReasonUpstreamFailure},
+		{"unavailable", k8serrors.NewServiceUnavailable("etcd"), http.StatusServiceUnavailable, bedrock.ReasonUpstreamFailure},
+		{"unreachable", &url.Error{Op: "Get", URL: "https://10.0.0.1", Err: errors.New("connection refused")}, http.StatusServiceUnavailable, bedrock.ReasonUpstreamFailure},
+		{"unexpected", errors.New("boom"), http.StatusInternalServerError, bedrock.ReasonInternal},
+	}
+	for _, tt := range tests {
+		e := toError(tt.err)
+		if e.Status != tt.status || e.Reason != tt.reason {
+			t.Errorf("%v: expected %v %v, got %v %v", tt.name, tt.status, tt.reason, e.Status, e.Reason)
+		}
+	}
+
+	e := toError(invalid)
+	if len(e.Details) != 1 || e.Details[0].Field != "spec.replicas" {
+		t.Errorf("expected the invalid field in details, got %+v", e.Details)
+	}
+}
@@ -0,0 +1,899 @@
+package http
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/go-chi/chi"
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/audit"
+	"github.com/xumak-grid/bedrock/auth"
+	"github.com/xumak-grid/bedrock/k8s"
+	"github.com/xumak-grid/bedrock/secrets"
+	"github.com/xumak-grid/bedrock/secrets/kube"
+	"github.com/xumak-grid/bedrock/secrets/memory"
+	"github.com/xumak-grid/bedrock/stack/nexus"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestClientHandlers(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+
+	a.run([]handlerTest{
+		{name: "create", method: "POST", path: "/clients", body: bedrock.Client{ClientID: "acme", MetaData: map[string]string{"team": "web"}}, status: http.StatusCreated},
+		{name: "create again", method: "POST", path: "/clients", body: bedrock.Client{ClientID: "acme"}, status: http.StatusOK},
+		{name: "create invalid", method: "POST", path: "/clients", body: bedrock.Client{ClientID: "Acme Inc"}, status: http.StatusBadRequest, check: hasReason(bedrock.ReasonValidation)},
+		{name: "list", method: "GET", path: "/clients", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			clients := []bedrock.Client{}
+			decodeBody(t, body, &clients)
+			if len(clients) != 1 || clients[0].ClientID != "acme" {
+				t.Errorf("expected the client acme, got %+v", clients)
+			}
+		}},
+		{name: "get", method: "GET", path: "/clients/acme", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			client := bedrock.Client{}
+			decodeBody(t, body, &client)
+			if client.MetaData["team"] != "web" || len(client.MetaData) != 1 || !client.Protected || client.Status != bedrock.ClientActive {
+				t.Errorf("expected the metadata of the client and the protection, got %+v", client)
+			}
+		}},
+		{name: "get unknown", method: "GET", path: "/clients/unknown", status: http.StatusNotFound, check: hasReason(bedrock.ReasonNotFound)},
+		{name: "update metadata", method: "PATCH", path: "/clients/acme", body: map[string]interface{}{"meta": map[string]interface{}{"team": nil, "tier": "gold"}}, status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			client := bedrock.Client{}
+			decodeBody(t, body, &client)
+			if len(client.MetaData) != 1 || client.MetaData["tier"] != "gold" || !client.Protected {
+				t.Errorf("expected team removed and tier added, got %+v", client)
+			}
+		}},
+		{name: "update reserved metadata", method: "PATCH", path: "/clients/acme", body: map[string]interface{}{"meta": map[string]interface{}{k8s.ProtectedAnnotation: nil}}, status: http.StatusBadRequest, check: hasReason(bedrock.ReasonValidation)},
+		{name: "update unknown", method: "PATCH", path: "/clients/unknown", body: bedrock.ClientPatch{}, status: http.StatusNotFound},
+		{name: "delete protected", method: "DELETE", path: "/clients/acme", status: http.StatusConflict, check: hasReason(bedrock.ReasonConflict)},
+		{name: "unprotect", method: "POST", path: "/clients/acme/unprotect", status: http.StatusOK},
+		{name: "delete invalid grace period", method: "DELETE", path: "/clients/acme?gracePeriod=tomorrow", status: http.StatusBadRequest},
+		{name: "delete", method: "DELETE", path: "/clients/acme?gracePeriod=0s", status: http.StatusAccepted, check: func(t *testing.T, body []byte) {
+			deletion := bedrock.ClientDeletion{}
+			decodeBody(t, body, &deletion)
+			if deletion.Status != bedrock.DeletionReady || deletion.RequestedBy != "anonymous" {
+				t.Errorf("expected a deletion ready without grace period, got %+v", deletion)
+			}
+		}},
+		{name: "get scheduled for deletion", method: "GET", path: "/clients/acme", status: http.StatusOK},
+	})
+	a.finalizeDeletions()
+	a.run([]handlerTest{
+		{name: "get deleted", method: "GET", path: "/clients/acme", status: http.StatusNotFound},
+	})
+}
+
+func TestClientList(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	created := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, c := range []bedrock.Client{
+		{ClientID: "acme", MetaData: map[string]string{"team": "web", "tier": "gold"}},
+		{ClientID: "beta", MetaData: map[string]string{"team": "mobile"}},
+		{ClientID: "gamma", MetaData: map[string]string{"team": "web", "tier": "silver"}},
+	} {
+		a.run([]handlerTest{{name: "create " + c.ClientID, method: "POST", path: "/clients", body: c, status: http.StatusCreated}})
+		// the fake clientset does not set the creation time
+		ns, err := a.deps.KubeClient.CoreV1().Namespaces().Get(c.ClientID, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ns.CreationTimestamp = metav1.NewTime(created.AddDate(0, 0, i))
+		_, err = a.deps.KubeClient.CoreV1().Namespaces().Update(ns)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := k8s.SetNamespaceAnnotation(a.deps.KubeClient, "beta", k8s.SuspensionAnnotation, `{"clientId":"beta","suspended":true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientIDs := func(expected ...string) func(t *testing.T, body []byte) {
+		return func(t *testing.T, body []byte) {
+			clients := []bedrock.Client{}
+			decodeBody(t, body, &clients)
+			ids := []string{}
+			for _, c := range clients {
+				ids = append(ids, c.ClientID)
+			}
+			if strings.Join(ids, ",") != strings.Join(expected, ",") {
+				t.Errorf("expected the clients %v, got %v", expected, ids)
+			}
+		}
+	}
+	a.run([]handlerTest{
+		{name: "all", method: "GET", path: "/clients", status: http.StatusOK, check: clientIDs("acme", "beta", "gamma")},
+		{name: "selector", method: "GET", path: "/clients?selector=team%3Dweb", status: http.StatusOK, check: clientIDs("acme", "gamma")},
+		{name: "selector in", method: "GET", path: "/clients?selector=tier+in+(gold,bronze)", status: http.StatusOK, check: clientIDs("acme")},
+		{name: "selector without key", method: "GET", path: "/clients?selector=!tier", status: http.StatusOK, check: clientIDs("beta")},
+		{name: "invalid selector", method: "GET", path: "/clients?selector=team%3D%3D%3D", status: http.StatusBadRequest, check: hasReason(bedrock.ReasonValidation)},
+		{name: "status", method: "GET", path: "/clients?status=suspended", status: http.StatusOK, check: clientIDs("beta")},
+		{name: "statuses", method: "GET", path: "/clients?status=active,deleting", status: http.StatusOK, check: clientIDs("acme", "gamma")},
+		{name: "invalid status", method: "GET", path: "/clients?status=paused", status: http.StatusBadRequest},
+		{name: "created after", method: "GET", path: "/clients?createdAfter=2018-01-01T12:00:00Z", status: http.StatusOK, check: clientIDs("beta", "gamma")},
+		{name: "created before", method: "GET", path: "/clients?createdBefore=2018-01-02T12:00:00Z", status: http.StatusOK, check: clientIDs("acme", "beta")},
+		{name: "invalid time", method: "GET", path: "/clients?createdAfter=yesterday", status: http.StatusBadRequest},
+		{name: "sort descending", method: "GET", path: "/clients?sort=-createdAt", status: http.StatusOK, check: clientIDs("gamma", "beta", "acme")},
+		{name: "sort status", method: "GET", path: "/clients?sort=status", status: http.StatusOK, check: clientIDs("acme", "gamma", "beta")},
+		{name: "invalid sort", method: "GET", path: "/clients?sort=name", status: http.StatusBadRequest},
+		{name: "page", method: "GET", path: "/clients?limit=2", status: http.StatusOK, check: clientIDs("acme", "beta")},
+		{name: "next page", method: "GET", path: "/clients?limit=2&offset=2", status: http.StatusOK, check: clientIDs("gamma")},
+		{name: "after the last page", method: "GET", path: "/clients?limit=2&offset=4", status: http.StatusOK, check: clientIDs()},
+		{name: "invalid limit", method: "GET", path: "/clients?limit=1000", status: http.StatusBadRequest},
+	})
+
+	resp, err := http.Get(a.server.URL + "/api/v1/clients?limit=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Total-Count") != "3" {
+		t.Errorf("expected the total of the clients in X-Total-Count, got %q", resp.Header.Get("X-Total-Count"))
+	}
+}
+
+func TestClientSummary(t *testing.T) {
+	ready := v1.PodStatus{Phase: v1.PodRunning, Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}}
+	author := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-author-0", Namespace: "acme", Labels: map[string]string{"app": "aem", "deployment": "dev", "runmode": "author"}},
+		Status:     ready,
+	}
+	publish := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-publish-0", Namespace: "acme", Labels: map[string]string{"app": "aem", "deployment": "dev", "runmode": "publish"}},
+		Status:     v1.PodStatus{Phase: v1.PodPending},
+	}
+	a := newTestAPI(t, author, publish)
+	defer a.close()
+	a.createClient("acme")
+
+	deploy := bedrock.AEMDeployment{Spec: bedrock.AEMDeploymentSpec{
+		Authors:           bedrock.Config{Type: "small", Replicas: 1},
+		Publishers:        bedrock.Config{Type: "small", Replicas: 1},
+		Dispatchers:       bedrock.Config{Type: "small", Replicas: 1},
+		Version:           "grid/aem-danta:6.3-1.0.5-jdk8",
+		DispatcherVersion: "grid/dispatcher:4.2.2",
+	}}
+	a.run([]handlerTest{
+		{name: "empty", method: "GET", path: "/clients/acme/summary", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			summary := bedrock.ClientSummary{}
+			decodeBody(t, body, &summary)
+			if summary.Client.ClientID != "acme" || len(summary.Environments) != 0 || summary.Artifactory != nil || summary.Toolbelt != nil || len(summary.Errors) != 0 {
+				t.Errorf("expected the client without stacks, got %+v", summary)
+			}
+			if summary.Certificate == nil || summary.Certificate.Ready {
+				t.Errorf("expected the certificate not issued yet, got %+v", summary.Certificate)
+			}
+		}},
+		{name: "unknown", method: "GET", path: "/clients/unknown/summary", status: http.StatusNotFound},
+		{name: "create aem", method: "POST", path: "/clients/acme/environments/dev/aem", body: deploy, status: http.StatusCreated},
+		{name: "create artifactory", method: "POST", path: "/clients/acme/artifactory", body: bedrock.Artifactory{ArtifactoryID: "nexus", Image: "grid/nexus:3.8.0"}, status: http.StatusCreated},
+		{name: "create toolbelt", method: "POST", path: "/clients/acme/tools/toolbelt", status: http.StatusCreated},
+	})
+
+	sfs, err := a.deps.KubeClient.AppsV1beta2().StatefulSets("acme").Get(nexus.ServerName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfs.Status.ReadyReplicas = 1
+	_, err = a.deps.KubeClient.AppsV1beta2().StatefulSets("acme").Update(sfs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.run([]handlerTest{
+		{name: "provisioned", method: "GET", path: "/clients/acme/summary", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			summary := bedrock.ClientSummary{}
+			decodeBody(t, body, &summary)
+			if len(summary.Environments) != 1 {
+				t.Fatalf("expected the environment dev, got %+v", summary.Environments)
+			}
+			env := summary.Environments[0]
+			if env.EnvironmentID != "dev" || env.Replicas.Publishers != 1 || env.ReadyReplicas.Authors != 1 || env.ReadyReplicas.Publishers != 0 {
+				t.Errorf("expected the ready author and the publisher not ready, got %+v", env)
+			}
+			if summary.Artifactory == nil || summary.Artifactory.Vendor != "nexus" || !summary.Artifactory.Ready || summary.Artifactory.Image != "grid/nexus:3.8.0" || summary.Artifactory.Host == "" {
+				t.Errorf("expected the ready nexus, got %+v", summary.Artifactory)
+			}
+			if summary.SCM != nil || summary.CI != nil {
+				t.Errorf("expected no scm and ci, got %+v %+v", summary.SCM, summary.CI)
+			}
+			if summary.Toolbelt == nil || summary.Toolbelt.ExpiresAt == nil || summary.Toolbelt.Expired {
+				t.Errorf("expected the toolbelt not expired, got %+v", summary.Toolbelt)
+			}
+		}},
+	})
+
+	expiresAt := presignedURLExpiration("https://s3.test/box?X-Amz-Date=20180102T150405Z&X-Amz-Expires=3600&X-Amz-Signature=abc")
+	if expiresAt == nil || !expiresAt.Equal(time.Date(2018, 1, 2, 16, 4, 5, 0, time.UTC)) {
+		t.Errorf("expected the expiration of the signature, got %v", expiresAt)
+	}
+	if presignedURLExpiration("https://s3.test/box") != nil {
+		t.Error("expected no expiration for an unsigned url")
+	}
+}
+
+func TestClientDeletion(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	a.createClient("acme")
+	a.createClient("other")
+	a.secrets.Put(getPodSecretKey("acme", "dev", "dev-author-0"), map[string]interface{}{"password": "s3cret"})
+	a.secrets.Put(getPodSecretKey("other", "dev", "dev-author-0"), map[string]interface{}{"password": "s3cret"})
+	os.Setenv(ExportBucketEnvVar, "exports")
+	defer os.Unsetenv(ExportBucketEnvVar)
+
+	a.run([]handlerTest{
+		{name: "unprotect", method: "POST", path: "/clients/acme/unprotect", status: http.StatusOK},
+		{name: "create artifactory", method: "POST", path: "/clients/acme/artifactory", body: bedrock.Artifactory{ArtifactoryID: "nexus", Image: "grid/nexus:3.8.0"}, status: http.StatusCreated},
+		{name: "not scheduled", method: "GET", path: "/clients/acme/deletion", status: http.StatusNotFound},
+		{name: "delete", method: "DELETE", path: "/clients/acme?gracePeriod=0s&export=true", status: http.StatusAccepted, check: func(t *testing.T, body []byte) {
+			deletion := bedrock.ClientDeletion{}
+			decodeBody(t, body, &deletion)
+			if deletion.Status != bedrock.DeletionPending || len(deletion.Exports) != 1 || deletion.Exports[0].Stack != "artifactory" || deletion.Exports[0].Status != bedrock.OperationRunning {
+				t.Errorf("expected the deletion waiting the export of the artifactory, got %+v", deletion)
+			}
+		}},
+	})
+	a.finalizeDeletions()
+	a.run([]handlerTest{
+		{name: "waiting export", method: "GET", path: "/clients/acme", status: http.StatusOK},
+	})
+
+	job, err := a.deps.KubeClient.BatchV1().Jobs("acme").Get(nexus.ExportJobName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := job.Spec.Template.Spec.Containers[0]
+	if len(container.EnvFrom) != 0 || !strings.HasPrefix(container.Env[len(container.Env)-1].Value, "https://s3.test/exports/acme/") {
+		t.Errorf("expected the export job with a pre-signed upload url and without aws credentials, got %+v", container)
+	}
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: v1.ConditionTrue}}
+	_, err = a.deps.KubeClient.BatchV1().Jobs("acme").Update(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.run([]handlerTest{
+		{name: "exported", method: "GET", path: "/clients/acme/deletion", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			deletion := bedrock.ClientDeletion{}
+			decodeBody(t, body, &deletion)
+			if deletion.Status != bedrock.DeletionReady || deletion.Exports[0].Location == "" {
+				t.Errorf("expected the deletion ready after the export, got %+v", deletion)
+			}
+		}},
+	})
+	a.finalizeDeletions()
+	a.run([]handlerTest{
+		{name: "deleted", method: "GET", path: "/clients/acme", status: http.StatusNotFound},
+		{name: "other kept", method: "GET", path: "/clients/other", status: http.StatusOK},
+	})
+	if secret, _ := a.secrets.Get(getPodSecretKey("acme", "dev", "dev-author-0")); len(secret) > 0 {
+		t.Errorf("expected the secrets of the client deleted, got %v", secret)
+	}
+	if secret, _ := a.secrets.Get(getPodSecretKey("other", "dev", "dev-author-0")); len(secret) == 0 {
+		t.Error("expected the secrets of the other client kept")
+	}
+
+	a.run([]handlerTest{
+		{name: "unprotect other", method: "POST", path: "/clients/other/unprotect", status: http.StatusOK},
+		{name: "delete other", method: "DELETE", path: "/clients/other", status: http.StatusAccepted},
+		{name: "cancel", method: "DELETE", path: "/clients/other/deletion", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			deletion := bedrock.ClientDeletion{}
+			decodeBody(t, body, &deletion)
+			if deletion.Status != bedrock.DeletionCancelled {
+				t.Errorf("expected the deletion cancelled, got %+v", deletion)
+			}
+		}},
+		{name: "cancelled", method: "GET", path: "/clients/other/deletion", status: http.StatusNotFound},
+	})
+}
+
+// fullDeployClient returns a client with the custom configuration of a full deploy
+func fullDeployClient(clientID string, dryRun bool) bedrock.Client {
+	return bedrock.Client{
+		ClientID:     clientID,
+		CustomConfig: true,
+		DryRun:       dryRun,
+		Configuration: &bedrock.ClientCustomConfig{
+			FullCompanyName:            "Acme Inc",
+			AdminEmail:                 "admin@acme.com",
+			Environments:               []string{"dev", "prod"},
+			AEMInstancesVersion:        "grid/aem-danta:6.3-1.0.5-jdk8",
+			AEMInstancesType:           "small",
+			DispatcherInstancesVersion: "grid/dispatcher:4.2.2",
+			DispatcherInstancesType:    "small",
+			InitialRepositoryType:      bedrock.ContentSetupDantaDemo,
+		},
+	}
+}
+
+// epCommerceClient returns the dry run of a full deploy with an ep-commerce repository
+func epCommerceClient(version string) bedrock.Client {
+	c := fullDeployClient("acme", true)
+	c.Configuration.InitialRepositoryType = bedrock.ContentSetupEPCommerce
+	c.Configuration.EPObjectType = &bedrock.EPObjectType{Version: version}
+	return c
+}
+
+func TestFullDeploy(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+
+	op := bedrock.Operation{}
+	a.run([]handlerTest{
+		{name: "dry run", method: "POST", path: "/clients", body: fullDeployClient("acme", true), status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			fullDeploy := bedrock.FullDeploy{}
+			decodeBody(t, body, &fullDeploy)
+			if len(fullDeploy.AEMDeployments) != 2 || fullDeploy.SCM.SCMID != "gogs" {
+				t.Errorf("expected the full deploy of 2 environments, got %+v", fullDeploy)
+			}
+		}},
+		{name: "dry run creates nothing", method: "GET", path: "/clients/acme", status: http.StatusNotFound},
+		{name: "dry run ep-commerce", method: "POST", path: "/clients", body: epCommerceClient("7.1"), status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			fullDeploy := bedrock.FullDeploy{}
+			decodeBody(t, body, &fullDeploy)
+			repo := fullDeploy.SCM.Configuration.Repositories[0]
+			if repo.ContentSetupType != bedrock.ContentSetupEPCommerce || repo.EPObjectType == nil || repo.EPObjectType.SourceCodeURL == "" {
+				t.Errorf("expected the ep-commerce repository with the init package, got %+v", repo)
+			}
+		}},
+		{name: "dry run unknown ep version", method: "POST", path: "/clients", body: epCommerceClient("6.0"), status: http.StatusBadRequest, check: hasReason(bedrock.ReasonValidation)},
+		{name: "full deploy", method: "POST", path: "/clients", body: fullDeployClient("acme", false), status: http.StatusAccepted, check: func(t *testing.T, body []byte) {
+			decodeBody(t, body, &op)
+			if len(op.Steps) != 6 {
+				t.Errorf("expected 6 steps, got %+v", op.Steps)
+			}
+		}},
+	})
+	a.waitOperations()
+
+	a.run([]handlerTest{
+		{name: "operation", method: "GET", path: "/operations/" + op.OperationID, status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			finished := bedrock.Operation{}
+			decodeBody(t, body, &finished)
+			if finished.Status != bedrock.OperationSucceeded || finished.Result == nil {
+				t.Fatalf("expected a succeeded operation, got %+v", finished)
+			}
+			for _, step := range finished.Steps {
+				if step.Status != bedrock.OperationSucceeded {
+					t.Errorf("expected the step %v succeeded, got %+v", step.Name, step)
+				}
+			}
+			if finished.Result.Toolbelt.URL != "https://s3.test/xumak-grid-boxes/demo/boot2docker_virtualbox2.box" {
+				t.Errorf("expected the presigned url of the toolbelt, got %v", finished.Result.Toolbelt.URL)
+			}
+			if finished.Result.SCM.Configuration != nil || finished.Result.Artifactory.Configuration != nil {
+				t.Errorf("expected the result without the passwords of the configurations, got %+v", finished.Result)
+			}
+		}},
+		{name: "environments", method: "GET", path: "/clients/acme/environments", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			environments := []bedrock.Environment{}
+			decodeBody(t, body, &environments)
+			if len(environments) != 2 {
+				t.Errorf("expected 2 environments, got %+v", environments)
+			}
+		}},
+		{name: "artifactory", method: "GET", path: "/clients/acme/artifactory/nexus", status: http.StatusOK},
+		{name: "scm", method: "GET", path: "/clients/acme/scm/gogs", status: http.StatusOK},
+		{name: "ci", method: "GET", path: "/clients/acme/ci/drone", status: http.StatusOK},
+		{name: "toolbelt", method: "GET", path: "/clients/acme/tools/toolbelt", status: http.StatusOK},
+		{name: "unknown operation", method: "GET", path: "/operations/unknown", status: http.StatusNotFound},
+	})
+}
+
+func TestFullDeployInterrupted(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	defer func(store *operationStore) { operations = store }(operations)
+	operations = newOperationStore()
+	operations.interrupt()
+
+	op := bedrock.Operation{}
+	a.run([]handlerTest{
+		{name: "full deploy", method: "POST", path: "/clients", body: fullDeployClient("acme", false), status: http.StatusAccepted, check: func(t *testing.T, body []byte) {
+			decodeBody(t, body, &op)
+		}},
+	})
+	a.waitOperations()
+
+	a.run([]handlerTest{
+		{name: "operation", method: "GET", path: "/operations/" + op.OperationID, status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			finished := bedrock.Operation{}
+			decodeBody(t, body, &finished)
+			if finished.Status != bedrock.OperationFailed || !finished.RolledBack || finished.Error != errInterrupted.Error() {
+				t.Errorf("expected a failed and rolled back operation, got %+v", finished)
+			}
+		}},
+		{name: "environments", method: "GET", path: "/clients/acme/environments", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			environments := []bedrock.Environment{}
+			decodeBody(t, body, &environments)
+			if len(environments) != 0 {
+				t.Errorf("expected no environments, got %+v", environments)
+			}
+		}},
+	})
+}
+
+func TestStackHandlers(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	a.createClient("acme")
+
+	tests := []handlerTest{
+		{name: "artifactory without client", method: "POST", path: "/clients/unknown/artifactory", body: bedrock.Artifactory{ArtifactoryID: "nexus", Image: "grid/nexus:3.8.0"}, status: http.StatusNotFound},
+		{name: "artifactory unknown vendor", method: "POST", path: "/clients/acme/artifactory", body: bedrock.Artifactory{ArtifactoryID: "jfrog", Image: "jfrog:1"}, status: http.StatusBadRequest},
+		{name: "artifactory create", method: "POST", path: "/clients/acme/artifactory", body: bedrock.Artifactory{ArtifactoryID: "nexus", Image: "grid/nexus:3.8.0"}, status: http.StatusCreated},
+		{name: "artifactory apply again", method: "POST", path: "/clients/acme/artifactory", body: bedrock.Artifactory{ArtifactoryID: "nexus", Image: "grid/nexus:3.8.0"}, status: http.StatusOK},
+		{name: "artifactory get", method: "GET", path: "/clients/acme/artifactory/nexus", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			artifactory := bedrock.Artifactory{}
+			decodeBody(t, body, &artifactory)
+			if artifactory.Image != "grid/nexus:3.8.0" || artifactory.ServerName == "" {
+				t.Errorf("unexpected artifactory %+v", artifactory)
+			}
+		}},
+		{name: "artifactory get unknown vendor", method: "GET", path: "/clients/acme/artifactory/jfrog", status: http.StatusNotFound},
+		{name: "artifactory delete", method: "DELETE", path: "/clients/acme/artifactory/nexus", status: http.StatusOK},
+		{name: "artifactory get deleted", method: "GET", path: "/clients/acme/artifactory/nexus", status: http.StatusNotFound},
+
+		{name: "scm create", method: "POST", path: "/clients/acme/scm", body: bedrock.SCM{SCMID: "gogs", Image: "grid/gogs:0.11.34"}, status: http.StatusCreated},
+		{name: "scm get", method: "GET", path: "/clients/acme/scm/gogs", status: http.StatusOK},
+		{name: "scm delete", method: "DELETE", path: "/clients/acme/scm/gogs", status: http.StatusOK},
+		{name: "scm get deleted", method: "GET", path: "/clients/acme/scm/gogs", status: http.StatusNotFound},
+
+		{name: "ci invalid", method: "POST", path: "/clients/acme/ci", body: bedrock.CI{CIID: "drone", Image: "grid/drone:0.8-alpine"}, status: http.StatusBadRequest, check: hasReason(bedrock.ReasonValidation)},
+		{name: "ci create", method: "POST", path: "/clients/acme/ci", body: bedrock.CI{CIID: "drone", Image: "grid/drone:0.8-alpine", SecondImage: "grid/drone-agent:0.8", ScmURL: "https://gogs.acme.test"}, status: http.StatusCreated},
+		{name: "ci get", method: "GET", path: "/clients/acme/ci/drone", status: http.StatusOK},
+		{name: "ci delete", method: "DELETE", path: "/clients/acme/ci/drone", status: http.StatusOK},
+		{name: "ci get deleted", method: "GET", path: "/clients/acme/ci/drone", status: http.StatusNotFound},
+
+		{name: "toolbelt create", method: "POST", path: "/clients/acme/tools/toolbelt", status: http.StatusCreated},
+		{name: "toolbelt get", method: "GET", path: "/clients/acme/tools/toolbelt", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			tb := bedrock.Toolbelt{}
+			decodeBody(t, body, &tb)
+			if tb.URL != "https://s3.test/xumak-grid-boxes/demo/boot2docker_virtualbox2.box" || tb.ExpiresAt == nil {
+				t.Errorf("expected the presigned url and its expiration, got %+v", tb)
+			}
+		}},
+		{name: "toolbelt delete", method: "DELETE", path: "/clients/acme/tools/toolbelt", status: http.StatusOK},
+		{name: "toolbelt get deleted", method: "GET", path: "/clients/acme/tools/toolbelt", status: http.StatusNotFound},
+	}
+	a.run(tests)
+}
+
+func TestAudit(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	a.createClient("acme")
+	scm := bedrock.SCM{SCMID: "gogs", Image: "grid/gogs:0.11.34", Configuration: &bedrock.SCMConfig{
+		InitData: &bedrock.SCMInitData{AdminName: "gridadmin", AdminEmail: "admin@acme.test", AdminPass: "s3cret", AdminConfirmPass: "s3cret"},
+	}}
+
+	a.run([]handlerTest{
+		{name: "scm create", method: "POST", path: "/clients/acme/scm", body: scm, status: http.StatusCreated},
+		{name: "scm get", method: "GET", path: "/clients/acme/scm/gogs", status: http.StatusOK},
+		{name: "artifactory unknown vendor", method: "POST", path: "/clients/acme/artifactory", body: bedrock.Artifactory{ArtifactoryID: "jfrog", Image: "jfrog:1"}, status: http.StatusBadRequest},
+		{name: "invalid since", method: "GET", path: "/clients/acme/audit?since=yesterday", status: http.StatusBadRequest, check: hasReason(bedrock.ReasonValidation)},
+		{name: "audit", method: "GET", path: "/clients/acme/audit", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			if bytes.Contains(body, []byte("s3cret")) {
+				t.Errorf("expected the passwords redacted, got %s", body)
+			}
+			records := []bedrock.AuditRecord{}
+			decodeBody(t, body, &records)
+			if len(records) != 3 {
+				t.Fatalf("expected the records of the client creation and the scm requests, got %+v", records)
+			}
+			create, scm, invalid := records[0], records[1], records[2]
+			if create.Route != "/api/v1/clients" || create.ClientID != "acme" || create.Status != http.StatusCreated {
+				t.Errorf("unexpected record of the client creation %+v", create)
+			}
+			if scm.Actor != "anonymous" || scm.Route != "/api/v1/clients/{clientId}/scm" || scm.Outcome != "success" || scm.Body == nil {
+				t.Errorf("unexpected record of the scm creation %+v", scm)
+			}
+			if invalid.Route != "/api/v1/clients/{clientId}/artifactory" || invalid.Status != http.StatusBadRequest || invalid.Outcome != "failure" {
+				t.Errorf("unexpected record of the invalid artifactory %+v", invalid)
+			}
+		}},
+		{name: "audit limit", method: "GET", path: "/clients/acme/audit?limit=1", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			records := []bedrock.AuditRecord{}
+			decodeBody(t, body, &records)
+			if len(records) != 1 || records[0].Status != http.StatusBadRequest {
+				t.Errorf("expected the last record, got %+v", records)
+			}
+		}},
+	})
+}
+
+func TestAuditLargeBody(t *testing.T) {
+	log := audit.New(logrus.New())
+	var read int
+	h := chi.NewRouter()
+	h.Use(Audit(log))
+	h.Post("/clients", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		read = len(body)
+	})
+	for _, size := range []int{maxAuditBody, maxAuditBody + 1} {
+		body := `{"password":"s3cret","padding":"` + strings.Repeat("x", size-34) + `"}`
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/clients", strings.NewReader(body)))
+		if read != size {
+			t.Errorf("expected the handler to read the body of %v bytes, got %v", size, read)
+		}
+	}
+	records, err := log.Query(audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected the records of both requests, got %v", len(records))
+	}
+	recorded, ok := records[0].Body.(map[string]interface{})
+	if !ok || recorded["password"] != audit.Redacted {
+		t.Errorf("expected the body redacted, got %.50v", records[0].Body)
+	}
+	if records[1].Body != auditBodyTooLarge {
+		t.Errorf("expected the large body not recorded, got %.50v", records[1].Body)
+	}
+}
+
+func TestSCMInitPackage(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	a.createClient("acme")
+
+	scm := bedrock.SCM{
+		SCMID:        "gogs",
+		Image:        "grid/gogs:0.11.34",
+		CustomConfig: true,
+		Configuration: &bedrock.SCMConfig{
+			InitData: &bedrock.SCMInitData{
+				AdminName:        "xumak",
+				AdminPass:        "secret",
+				AdminConfirmPass: "secret",
+				AdminEmail:       "admin@acme.com",
+			},
+			Repositories: []bedrock.SCMRepository{{
+				Name:             "acme-app",
+				ContentSetupType: bedrock.ContentSetupEPCommerce,
+				EPObjectType:     &bedrock.EPObjectType{Version: "7.1"},
+			}},
+		},
+	}
+	a.run([]handlerTest{
+		{name: "ep commerce", method: "POST", path: "/clients/acme/scm", body: scm, status: http.StatusCreated, check: func(t *testing.T, body []byte) {
+			created := bedrock.SCM{}
+			decodeBody(t, body, &created)
+			ep := created.Configuration.Repositories[0].EPObjectType
+			if ep.SourceCodeURL != "https://s3.test/grid-ep-packages/construction/EP-Commerce-7.1.0.zip" || ep.PlatformVersion == "" {
+				t.Errorf("expected the init package of 7.1, got %+v", ep)
+			}
+		}},
+	})
+
+	a.presigner.err = errPresign
+	a.run([]handlerTest{
+		{name: "ep commerce without aws", method: "POST", path: "/clients/acme/scm", body: scm, status: http.StatusBadGateway, check: hasReason(bedrock.ReasonUpstreamFailure)},
+		{name: "toolbelt without aws", method: "POST", path: "/clients/acme/tools/toolbelt", status: http.StatusBadGateway, check: hasReason(bedrock.ReasonUpstreamFailure)},
+	})
+}
+
+func TestEnvironmentHandlers(t *testing.T) {
+	labels := map[string]string{"app": "aem", "deployment": "dev", "runmode": "author"}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-author-0", Namespace: "acme", Labels: labels},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+	dispatcher := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-dispatcher", Namespace: "acme"},
+		Data:       map[string]string{"dispatcher.any": "/farms {}"},
+	}
+	a := newTestAPI(t, pod, dispatcher)
+	defer a.close()
+	a.createClient("acme")
+	a.secrets.Put(getPodSecretKey("acme", "dev", "dev-author-0"), map[string]interface{}{"password": "s3cret"})
+
+	deploy := bedrock.AEMDeployment{Spec: bedrock.AEMDeploymentSpec{
+		Authors:           bedrock.Config{Type: "small", Replicas: 1},
+		Publishers:        bedrock.Config{Type: "small", Replicas: 1},
+		Dispatchers:       bedrock.Config{Type: "small", Replicas: 1},
+		Version:           "grid/aem-danta:6.3-1.0.5-jdk8",
+		DispatcherVersion: "grid/dispatcher:4.2.2",
+	}}
+	scaled := deploy
+	scaled.Spec.Publishers.Replicas = 2
+
+	a.run([]handlerTest{
+		{name: "create", method: "POST", path: "/clients/acme/environments/dev/aem", body: deploy, status: http.StatusCreated},
+		{name: "create invalid", method: "POST", path: "/clients/acme/environments/Dev/aem", body: deploy, status: http.StatusBadRequest},
+		{name: "list", method: "GET", path: "/clients/acme/environments", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			environments := []bedrock.Environment{}
+			decodeBody(t, body, &environments)
+			if len(environments) != 1 || environments[0].EnvironmentID != "dev" {
+				t.Errorf("expected the environment dev, got %+v", environments)
+			}
+		}},
+		{name: "scale", method: "PATCH", path: "/clients/acme/environments/dev/aem", body: scaled, status: http.StatusOK},
+		{name: "get", method: "GET", path: "/clients/acme/environments/dev/aem", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			d := bedrock.AEMDeployment{}
+			decodeBody(t, body, &d)
+			if d.Spec.Publishers.Replicas != 2 || d.Spec.Version != deploy.Spec.Version {
+				t.Errorf("expected the scaled spec, got %+v", d.Spec)
+			}
+		}},
+		{name: "instances", method: "GET", path: "/clients/acme/environments/dev/aem/instances", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			instances := []bedrock.Instance{}
+			decodeBody(t, body, &instances)
+			if len(instances) != 1 || !instances[0].Running {
+				t.Errorf("expected the running author, got %+v", instances)
+			}
+			if bytes.Contains(body, []byte("s3cret")) {
+				t.Errorf("expected the instances without passwords, got %s", body)
+			}
+		}},
+		{name: "dispatcher get", method: "GET", path: "/clients/acme/environments/dev/aem/dispatcherconfig", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			cm := bedrock.ConfigMap{}
+			decodeBody(t, body, &cm)
+			if cm.Name != "dev-dispatcher" || cm.Data["dispatcher.any"] != "/farms {}" {
+				t.Errorf("unexpected dispatcher config %+v", cm)
+			}
+		}},
+		{name: "dispatcher update", method: "PATCH", path: "/clients/acme/environments/dev/aem/dispatcherconfig", body: bedrock.ConfigMap{Name: "dev-dispatcher", Data: map[string]string{"dispatcher.any": "/farms { /acme {} }"}}, status: http.StatusOK},
+		{name: "dispatcher update without name", method: "PATCH", path: "/clients/acme/environments/dev/aem/dispatcherconfig", body: bedrock.ConfigMap{}, status: http.StatusBadRequest},
+		{name: "dispatcher get updated", method: "GET", path: "/clients/acme/environments/dev/aem/dispatcherconfig", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			cm := bedrock.ConfigMap{}
+			decodeBody(t, body, &cm)
+			if cm.Data["dispatcher.any"] != "/farms { /acme {} }" {
+				t.Errorf("expected the updated dispatcher config, got %+v", cm)
+			}
+		}},
+		{name: "delete", method: "DELETE", path: "/clients/acme/environments/dev/aem", status: http.StatusOK},
+		{name: "get deleted", method: "GET", path: "/clients/acme/environments/dev/aem", status: http.StatusNotFound},
+	})
+}
+
+func TestClientSuspension(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	a.createClient("acme")
+	deploy := bedrock.AEMDeployment{Spec: bedrock.AEMDeploymentSpec{
+		Authors:           bedrock.Config{Type: "small", Replicas: 1},
+		Publishers:        bedrock.Config{Type: "small", Replicas: 2},
+		Dispatchers:       bedrock.Config{Type: "small", Replicas: 1},
+		Version:           "grid/aem-danta:6.3-1.0.5-jdk8",
+		DispatcherVersion: "grid/dispatcher:4.2.2",
+	}}
+	checkSuspension := func(t *testing.T, body []byte) {
+		suspension := bedrock.ClientSuspension{}
+		decodeBody(t, body, &suspension)
+		if suspension.AEMDeployments["dev"].Publishers != 2 || suspension.StatefulSets["nexus-server"] != 1 {
+			t.Errorf("expected the replicas before the suspension, got %+v", suspension)
+		}
+	}
+
+	a.run([]handlerTest{
+		{name: "resume not suspended", method: "POST", path: "/clients/acme/resume", status: http.StatusConflict},
+		{name: "create aem", method: "POST", path: "/clients/acme/environments/dev/aem", body: deploy, status: http.StatusCreated},
+		{name: "create artifactory", method: "POST", path: "/clients/acme/artifactory", body: bedrock.Artifactory{ArtifactoryID: "nexus", Image: "grid/nexus:3.8.0"}, status: http.StatusCreated},
+		{name: "suspend", method: "POST", path: "/clients/acme/suspend", status: http.StatusOK, check: checkSuspension},
+		{name: "suspend again", method: "POST", path: "/clients/acme/suspend", status: http.StatusOK, check: checkSuspension},
+	})
+	d, err := a.deps.AEMClient.AemV1beta1().AEMDeployments("acme").Get("dev", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Spec.Authors.Replicas != 0 || d.Spec.Publishers.Replicas != 0 || d.Spec.Dispatchers.Replicas != 0 {
+		t.Errorf("expected the aem deployment scaled to zero, got %+v", d.Spec)
+	}
+	sfs, err := a.deps.KubeClient.AppsV1beta2().StatefulSets("acme").Get("nexus-server", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *sfs.Spec.Replicas != 0 {
+		t.Errorf("expected the nexus scaled to zero, got %v", *sfs.Spec.Replicas)
+	}
+
+	a.run([]handlerTest{
+		{name: "create client suspended", method: "POST", path: "/clients", body: bedrock.Client{ClientID: "acme"}, status: http.StatusConflict, check: hasReason(bedrock.ReasonConflict)},
+		{name: "create aem suspended", method: "POST", path: "/clients/acme/environments/prod/aem", body: deploy, status: http.StatusConflict},
+		{name: "scale aem suspended", method: "PATCH", path: "/clients/acme/environments/dev/aem", body: deploy, status: http.StatusConflict},
+		{name: "create scm suspended", method: "POST", path: "/clients/acme/scm", body: bedrock.SCM{SCMID: "gogs", Image: "grid/gogs:0.11.34"}, status: http.StatusConflict},
+		{name: "create ci suspended", method: "POST", path: "/clients/acme/ci", body: bedrock.CI{CIID: "drone", Image: "grid/drone:0.8-alpine", SecondImage: "grid/drone-agent:0.8", ScmURL: "https://gogs.acme.test"}, status: http.StatusConflict},
+		{name: "update artifactory suspended", method: "PATCH", path: "/clients/acme/artifactory/nexus", body: bedrock.ArtifactoryPatch{}, status: http.StatusConflict},
+		{name: "rotate suspended", method: "POST", path: "/clients/acme/environments/dev/aem/passwords/rotate", status: http.StatusConflict},
+		{name: "get aem suspended", method: "GET", path: "/clients/acme/environments/dev/aem", status: http.StatusOK},
+		{name: "unprotect", method: "POST", path: "/clients/acme/unprotect", status: http.StatusOK},
+		{name: "export suspended", method: "DELETE", path: "/clients/acme?export=true", status: http.StatusConflict, check: func(t *testing.T, body []byte) {
+			if !bytes.Contains(body, []byte("suspended")) {
+				t.Errorf("expected the export rejected because the client is suspended, got %s", body)
+			}
+		}},
+		{name: "not scheduled", method: "GET", path: "/clients/acme/deletion", status: http.StatusNotFound},
+	})
+	d, _ = a.deps.AEMClient.AemV1beta1().AEMDeployments("acme").Get("dev", metav1.GetOptions{})
+	if d.Spec.Publishers.Replicas != 0 {
+		t.Errorf("expected the aem deployment at zero while suspended, got %+v", d.Spec)
+	}
+
+	a.run([]handlerTest{
+		{name: "resume", method: "POST", path: "/clients/acme/resume", status: http.StatusOK, check: checkSuspension},
+		{name: "resume again", method: "POST", path: "/clients/acme/resume", status: http.StatusConflict},
+	})
+	d, _ = a.deps.AEMClient.AemV1beta1().AEMDeployments("acme").Get("dev", metav1.GetOptions{})
+	if d.Spec.Authors.Replicas != 1 || d.Spec.Publishers.Replicas != 2 || d.Spec.Version != deploy.Spec.Version {
+		t.Errorf("expected the replicas restored, got %+v", d.Spec)
+	}
+	sfs, _ = a.deps.KubeClient.AppsV1beta2().StatefulSets("acme").Get("nexus-server", metav1.GetOptions{})
+	if *sfs.Spec.Replicas != 1 {
+		t.Errorf("expected the nexus restored, got %v", *sfs.Spec.Replicas)
+	}
+	ns, _ := a.deps.KubeClient.CoreV1().Namespaces().Get("acme", metav1.GetOptions{})
+	if _, ok := ns.Annotations[k8s.SuspensionAnnotation]; ok {
+		t.Errorf("expected the suspension removed from the namespace, got %v", ns.Annotations)
+	}
+}
+
+func TestCatalogHandlers(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+
+	tests := []handlerTest{}
+	for _, path := range []string{"/vendors/artifactory/list", "/vendors/scm/list", "/vendors/ci/list", "/images/aem/list", "/images/dispatcher/list", "/instances/type/list"} {
+		tests = append(tests, handlerTest{name: path, method: "GET", path: path, status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			list := []map[string]interface{}{}
+			decodeBody(t, body, &list)
+			if len(list) == 0 {
+				t.Errorf("expected a non empty list")
+			}
+		}})
+	}
+	a.run(tests)
+}
+
+func TestInstancePasswords(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-author-0", Namespace: "acme", Labels: map[string]string{"app": "aem", "deployment": "dev"}},
+	}
+	backends := map[string]func(a *testAPI) secrets.SecretService{
+		"memory": func(a *testAPI) secrets.SecretService { return memory.NewSecretService() },
+		"kubernetes": func(a *testAPI) secrets.SecretService {
+			return kube.NewSecretService(func() kubernetes.Interface { return a.deps.KubeClient })
+		},
+	}
+	for name, backend := range backends {
+		a := newTestAPI(t, pod)
+		a.secrets = backend(a)
+		a.deps.Secrets = a.secrets
+		a.createClient("acme")
+		err := a.secrets.Put(getPodSecretKey("acme", "dev", "dev-author-0"), map[string]interface{}{"password": "s3cret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.run([]handlerTest{
+			{name: name, method: "GET", path: "/clients/acme/environments/dev/aem/instances/dev-author-0/password", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+				creds := bedrock.InstanceCredentials{}
+				decodeBody(t, body, &creds)
+				if creds.Password != "s3cret" || creds.Instance != "dev-author-0" {
+					t.Errorf("%v: expected the stored password, got %+v", name, creds)
+				}
+			}},
+			{name: name + " unknown instance", method: "GET", path: "/clients/acme/environments/dev/aem/instances/dev-author-9/password", status: http.StatusNotFound},
+			{name: name + " wrapped", method: "GET", path: "/clients/acme/environments/dev/aem/instances/dev-author-0/password?wrapTTL=5m", status: http.StatusBadRequest, check: hasReason(bedrock.ReasonValidation)},
+		})
+		a.close()
+	}
+}
+
+func TestRevealOperatorPasswordWithKubeBackend(t *testing.T) {
+	labels := map[string]string{"app": "aem", "deployment": "dev"}
+	author := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "dev-author-0", Namespace: "acme", Labels: labels}}
+	publish := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "dev-publish-0", Namespace: "acme", Labels: labels}}
+	// the Secret written by the aem-operator, without the labels of the secret service
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret.dev.dev-author-0", Namespace: "acme"},
+		Data:       map[string][]byte{"password": []byte("s3cret")},
+	}
+	a := newTestAPI(t, author, publish, secret)
+	defer a.close()
+	a.secrets = kube.NewSecretService(func() kubernetes.Interface { return a.deps.KubeClient })
+	a.deps.Secrets = a.secrets
+	a.createClient("acme")
+
+	a.run([]handlerTest{
+		{name: "reveal", method: "GET", path: "/clients/acme/environments/dev/aem/instances/dev-author-0/password", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			creds := bedrock.InstanceCredentials{}
+			decodeBody(t, body, &creds)
+			if creds.Password != "s3cret" {
+				t.Errorf("expected the password of the operator Secret, got %+v", creds)
+			}
+		}},
+		{name: "without secret", method: "GET", path: "/clients/acme/environments/dev/aem/instances/dev-publish-0/password", status: http.StatusNotFound},
+	})
+}
+
+func TestRevealPasswordRequiresSecretsAdmin(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-author-0", Namespace: "acme", Labels: map[string]string{"app": "aem", "deployment": "dev"}},
+	}
+	a := newTestAPI(t, pod)
+	defer a.close()
+	a.createClient("acme")
+	a.secrets.Put(getPodSecretKey("acme", "dev", "dev-author-0"), map[string]interface{}{"password": "s3cret"})
+	a.authenticate(auth.NewStaticTokens([]auth.StaticToken{
+		{Token: "admin", Subject: "ops", Grant: auth.Grant{ClientIDs: []string{auth.AllClients}, Role: auth.Admin}},
+	}), "admin")
+
+	a.run([]handlerTest{
+		{name: "list", method: "GET", path: "/clients/acme/environments/dev/aem/instances", status: http.StatusOK},
+		{name: "reveal", method: "GET", path: "/clients/acme/environments/dev/aem/instances/dev-author-0/password", status: http.StatusForbidden},
+	})
+}
+
+func TestPasswordRotation(t *testing.T) {
+	labels := map[string]string{"app": "aem", "deployment": "dev"}
+	author := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "dev-author-0", Namespace: "acme", Labels: labels}}
+	publish := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "dev-publish-0", Namespace: "acme", Labels: labels}}
+	a := newTestAPI(t, author, publish)
+	defer a.close()
+	a.createClient("acme")
+	authorKey := getPodSecretKey("acme", "dev", "dev-author-0")
+	a.secrets.Put(authorKey, map[string]interface{}{"password": "s3cret", "user": "admin"})
+
+	deploy := bedrock.AEMDeployment{Spec: bedrock.AEMDeploymentSpec{
+		Authors:           bedrock.Config{Type: "small", Replicas: 1},
+		Publishers:        bedrock.Config{Type: "small", Replicas: 1},
+		Dispatchers:       bedrock.Config{Type: "small", Replicas: 1},
+		Version:           "grid/aem-danta:6.3-1.0.5-jdk8",
+		DispatcherVersion: "grid/dispatcher:4.2.2",
+	}}
+	a.run([]handlerTest{
+		{name: "rotate without deployment", method: "POST", path: "/clients/acme/environments/dev/aem/passwords/rotate", status: http.StatusNotFound},
+		{name: "create", method: "POST", path: "/clients/acme/environments/dev/aem", body: deploy, status: http.StatusCreated},
+		{name: "rotate unknown instance", method: "POST", path: "/clients/acme/environments/dev/aem/instances/dev-author-9/password/rotate", status: http.StatusNotFound},
+		{name: "rotate instance", method: "POST", path: "/clients/acme/environments/dev/aem/instances/dev-author-0/password/rotate", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			rotation := bedrock.PasswordRotation{}
+			decodeBody(t, body, &rotation)
+			if len(rotation.Instances) != 1 || rotation.Instances[0] != "dev-author-0" || rotation.RotatedBy != "anonymous" {
+				t.Errorf("expected the rotation of the author, got %+v", rotation)
+			}
+		}},
+	})
+	secret, _ := a.secrets.Get(authorKey)
+	if secret["password"] == "s3cret" || len(secret["password"].(string)) != passwordLength {
+		t.Errorf("expected a new password, got %v", secret["password"])
+	}
+	if secret["previousPassword"] != "s3cret" || secret["user"] != "admin" {
+		t.Errorf("expected the previous password and the other fields, got %v", secret)
+	}
+
+	a.run([]handlerTest{
+		{name: "rotate environment", method: "POST", path: "/clients/acme/environments/dev/aem/passwords/rotate", status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			rotation := bedrock.PasswordRotation{}
+			decodeBody(t, body, &rotation)
+			if len(rotation.Instances) != 2 {
+				t.Errorf("expected the rotation of both instances, got %+v", rotation)
+			}
+		}},
+	})
+	d, err := a.deps.AEMClient.AemV1beta1().AEMDeployments("acme").Get("dev", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Annotations[k8s.PasswordRotationAnnotation] == "" || d.Annotations[k8s.PasswordRotationInstancesAnnotation] != "dev-author-0,dev-publish-0" {
+		t.Errorf("expected the rotation annotations for the aem-operator, got %v", d.Annotations)
+	}
+	publishSecret, _ := a.secrets.Get(getPodSecretKey("acme", "dev", "dev-publish-0"))
+	if publishSecret["password"] == nil || publishSecret["previousPassword"] != nil {
+		t.Errorf("expected a first password for the publish, got %v", publishSecret)
+	}
+}
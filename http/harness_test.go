@@ -0,0 +1,189 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	certfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	aemfake "github.com/xumak-grid/aem-operator/pkg/generated/clientset/versioned/fake"
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/auth"
+	"github.com/xumak-grid/bedrock/awscli"
+	"github.com/xumak-grid/bedrock/secrets"
+	"github.com/xumak-grid/bedrock/secrets/memory"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// stubPresigner returns a fake url of the object or err when is set
+type stubPresigner struct {
+	err error
+}
+
+func (p *stubPresigner) PreSignedURL(s3o *awscli.S3Object, hours int) (string, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+	return "https://s3.test/" + s3o.BucketName + "/" + s3o.Key, nil
+}
+
+func (p *stubPresigner) PreSignedUploadURL(s3o *awscli.S3Object, hours int) (string, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+	return "https://s3.test/" + s3o.BucketName + "/" + s3o.Key + "?upload", nil
+}
+
+// testAPI is an api server that uses fake clientsets, in-memory secrets and a stub presigner,
+// the handlers run without a cluster, vault or aws
+type testAPI struct {
+	t         *testing.T
+	server    *httptest.Server
+	deps      *Dependencies
+	secrets   secrets.SecretService
+	presigner *stubPresigner
+	// token is sent as bearer token when is not empty
+	token string
+}
+
+// newTestAPI starts a testAPI, the kubernetes clientset is seeded with objects
+func newTestAPI(t *testing.T, objects ...runtime.Object) *testAPI {
+	a := &testAPI{
+		t:         t,
+		secrets:   memory.NewSecretService(),
+		presigner: &stubPresigner{},
+	}
+	a.deps = &Dependencies{
+		KubeClient:        fake.NewSimpleClientset(objects...),
+		AEMClient:         aemfake.NewSimpleClientset(),
+		CertManagerClient: certfake.NewSimpleClientset(),
+		Secrets:           a.secrets,
+		Presigner:         a.presigner,
+	}
+	s := NewServer(nil, a.deps)
+	s.Auth = auth.Anonymous()
+	a.server = httptest.NewServer(s.Handler())
+	return a
+}
+
+func (a *testAPI) close() {
+	a.server.Close()
+}
+
+// authenticate restarts the server with the authenticator instead of the anonymous one,
+// token is sent in the next requests
+func (a *testAPI) authenticate(authenticator auth.Authenticator, token string) {
+	a.server.Close()
+	s := NewServer(nil, a.deps)
+	s.Auth = authenticator
+	a.server = httptest.NewServer(s.Handler())
+	a.token = token
+}
+
+// do sends a request to the api with body encoded as json, it returns the status and the response body
+func (a *testAPI) do(method, path string, body interface{}) (int, []byte) {
+	var in []byte
+	if body != nil {
+		var err error
+		in, err = json.Marshal(body)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, a.server.URL+"/api/v1"+path, bytes.NewReader(in))
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	out, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return resp.StatusCode, out
+}
+
+// createClient creates a client without custom configuration, the tests of the stacks require it
+func (a *testAPI) createClient(clientID string) {
+	status, body := a.do(http.MethodPost, "/clients", bedrock.Client{ClientID: clientID})
+	if status != http.StatusCreated {
+		a.t.Fatalf("error creating the client %v: %v %s", clientID, status, body)
+	}
+}
+
+// finalizeDeletions deletes the clients whose deletion is ready as the server does every interval
+func (a *testAPI) finalizeDeletions() {
+	_, err := finalizeDeletions(a.deps.KubeClient, a.secrets, time.Now().UTC())
+	if err != nil {
+		a.t.Fatal(err)
+	}
+}
+
+// waitOperations blocks until the operations running in background are finished
+func (a *testAPI) waitOperations() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := operations.wait(ctx)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+}
+
+// handlerTest is a request to the api and the expected response
+type handlerTest struct {
+	name   string
+	method string
+	path   string
+	body   interface{}
+	status int
+	// check verifies the response body when the status is the expected
+	check func(t *testing.T, body []byte)
+}
+
+// run sends the requests of tests in order, the state of the fake clientsets is shared
+func (a *testAPI) run(tests []handlerTest) {
+	for _, tt := range tests {
+		status, body := a.do(tt.method, tt.path, tt.body)
+		if status != tt.status {
+			a.t.Errorf("%v: %v %v expected %v, got %v %s", tt.name, tt.method, tt.path, tt.status, status, body)
+			continue
+		}
+		if tt.check != nil {
+			tt.check(a.t, body)
+		}
+	}
+}
+
+// decodeBody decodes the json response of a handlerTest
+func decodeBody(t *testing.T, body []byte, v interface{}) {
+	err := json.Unmarshal(body, v)
+	if err != nil {
+		t.Fatalf("invalid response %s: %v", body, err)
+	}
+}
+
+// hasReason returns a check of the reason of a JSONError
+func hasReason(reason string) func(t *testing.T, body []byte) {
+	return func(t *testing.T, body []byte) {
+		jsonErr := JSONError{}
+		decodeBody(t, body, &jsonErr)
+		if jsonErr.Reason != reason {
+			t.Errorf("expected the reason %v, got %+v", reason, jsonErr)
+		}
+	}
+}
+
+var errPresign = errors.New("presign failed")
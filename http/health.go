@@ -0,0 +1,145 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	certmanager "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha1"
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/awscli"
+	"github.com/xumak-grid/bedrock/k8s"
+	"github.com/xumak-grid/bedrock/secrets"
+)
+
+const (
+	// checkTimeout is the maximum time that a dependency check can take
+	checkTimeout = 5 * time.Second
+	// healthCacheTTL is the time that the result of the checks is reused,
+	// it avoids calling every dependency on each probe
+	healthCacheTTL = 10 * time.Second
+)
+
+// dependencyCheck verifies that a dependency of the api is working
+type dependencyCheck struct {
+	name  string
+	check func(deps *Dependencies) error
+}
+
+// defaultChecks are the dependencies required to provision clients
+func defaultChecks() []dependencyCheck {
+	return []dependencyCheck{
+		{name: "kubernetes", check: func(deps *Dependencies) error {
+			return k8s.CheckAPIServer(deps.KubeClient)
+		}},
+		{name: "aem-operator", check: func(deps *Dependencies) error {
+			return k8s.CheckResource(deps.KubeClient, aemv1beta1.SchemeGroupVersion.String(), "aemdeployments")
+		}},
+		{name: "cert-manager", check: func(deps *Dependencies) error {
+			return k8s.CheckResource(deps.KubeClient, certmanager.SchemeGroupVersion.String(), "certificates")
+		}},
+		{name: "secrets", check: func(deps *Dependencies) error {
+			if deps.Secrets == nil {
+				return errors.New("the secret service is not configured")
+			}
+			// the local secret services are always available
+			if checker, ok := deps.Secrets.(secrets.Checker); ok {
+				return checker.Check()
+			}
+			return nil
+		}},
+		{name: "aws", check: func(deps *Dependencies) error {
+			sess, err := awscli.Session()
+			if err != nil {
+				return err
+			}
+			return awscli.CheckCredentials(sess)
+		}},
+	}
+}
+
+// healthCache keeps the last result of the dependency checks
+type healthCache struct {
+	mu      sync.Mutex
+	health  bedrock.Health
+	checked time.Time
+}
+
+// healthzHandler is the liveness endpoint, it only verifies that the process serves requests
+func (s *Server) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	encode(w, bedrock.Health{Status: bedrock.HealthOK})
+}
+
+// readyHandler is the readiness endpoint, it returns 503 when the server is
+// shutting down or when a dependency check fails, with the result of each check
+func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		encode(w, bedrock.Health{Status: bedrock.HealthError})
+		return
+	}
+	health := s.checkDependencies()
+	if health.Status != bedrock.HealthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	encode(w, health)
+}
+
+// checkDependencies runs the checks concurrently, the result is cached for healthCacheTTL
+func (s *Server) checkDependencies() bedrock.Health {
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+	if time.Since(s.health.checked) < healthCacheTTL {
+		return s.health.health
+	}
+
+	deps := s.Dependencies()
+	results := make([]bedrock.DependencyHealth, len(s.checks))
+	wg := sync.WaitGroup{}
+	for i, c := range s.checks {
+		wg.Add(1)
+		go func(i int, c dependencyCheck) {
+			defer wg.Done()
+			results[i] = runCheck(c, deps)
+		}(i, c)
+	}
+	wg.Wait()
+
+	health := bedrock.Health{Status: bedrock.HealthOK, Dependencies: results}
+	for _, r := range results {
+		if r.Status != bedrock.HealthOK {
+			health.Status = bedrock.HealthError
+		}
+	}
+	s.health.health = health
+	s.health.checked = time.Now()
+	return health
+}
+
+// runCheck runs a check and reports an error when it takes more than checkTimeout
+func runCheck(c dependencyCheck, deps *Dependencies) bedrock.DependencyHealth {
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.check(deps)
+	}()
+	var err error
+	select {
+	case err = <-errc:
+	case <-time.After(checkTimeout):
+		err = fmt.Errorf("timeout after %v", checkTimeout)
+	}
+	result := bedrock.DependencyHealth{
+		Name:     c.name,
+		Status:   bedrock.HealthOK,
+		Duration: time.Since(start).Round(time.Millisecond).String(),
+	}
+	if err != nil {
+		result.Status = bedrock.HealthError
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	certmanager "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha1"
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/bedrock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// probe sends a GET to the path of the server handler and returns the status and the health
+func probe(t *testing.T, s *Server, path string) (int, bedrock.Health) {
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	health := bedrock.Health{}
+	err := json.Unmarshal(w.Body.Bytes(), &health)
+	if err != nil {
+		t.Fatalf("invalid health %s: %v", w.Body.Bytes(), err)
+	}
+	return w.Code, health
+}
+
+func TestHealthz(t *testing.T) {
+	s := NewServer(nil, fakeDependencies())
+	s.checks = []dependencyCheck{{name: "kubernetes", check: func(deps *Dependencies) error { return errors.New("unreachable") }}}
+	// the liveness does not depend on the readiness or the dependencies
+	status, health := probe(t, s, "/healthz")
+	if status != http.StatusOK || health.Status != bedrock.HealthOK {
+		t.Errorf("expected a live server, got %v %+v", status, health)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	s := NewServer(nil, fakeDependencies())
+	var calls int32
+	failing := errors.New("unreachable")
+	s.checks = []dependencyCheck{
+		{name: "kubernetes", check: func(deps *Dependencies) error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		}},
+		{name: "secrets", check: func(deps *Dependencies) error { return failing }},
+	}
+
+	status, health := probe(t, s, "/readyz")
+	if status != http.StatusServiceUnavailable || health.Status != bedrock.HealthError || len(health.Dependencies) != 0 {
+		t.Errorf("expected a server that is not open not ready, got %v %+v", status, health)
+	}
+
+	atomic.StoreInt32(&s.ready, 1)
+	status, health = probe(t, s, "/readyz")
+	if status != http.StatusServiceUnavailable || health.Status != bedrock.HealthError || len(health.Dependencies) != 2 {
+		t.Fatalf("expected the breakdown of the failed checks, got %v %+v", status, health)
+	}
+	if d := health.Dependencies[0]; d.Name != "kubernetes" || d.Status != bedrock.HealthOK || d.Duration == "" {
+		t.Errorf("expected the kubernetes check ok, got %+v", d)
+	}
+	if d := health.Dependencies[1]; d.Name != "secrets" || d.Status != bedrock.HealthError || d.Error != "unreachable" {
+		t.Errorf("expected the secrets check failed, got %+v", d)
+	}
+
+	// the result is cached, the checks are not called on every probe
+	failing = nil
+	probe(t, s, "/readyz")
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected the cached result, the checks were called %v times", n)
+	}
+	s.health.checked = s.health.checked.Add(-healthCacheTTL)
+	status, health = probe(t, s, "/readyz")
+	if status != http.StatusOK || health.Status != bedrock.HealthOK {
+		t.Errorf("expected a ready server after the cache expires, got %v %+v", status, health)
+	}
+}
+
+func TestDefaultChecks(t *testing.T) {
+	deps := fakeDependencies()
+	deps.KubeClient.(*fake.Clientset).Resources = []*metav1.APIResourceList{
+		{GroupVersion: aemv1beta1.SchemeGroupVersion.String(), APIResources: []metav1.APIResource{{Name: "aemdeployments"}}},
+		{GroupVersion: certmanager.SchemeGroupVersion.String(), APIResources: []metav1.APIResource{{Name: "issuers"}}},
+	}
+	withoutSecrets := *deps
+	withoutSecrets.Secrets = nil
+
+	checks := map[string]dependencyCheck{}
+	for _, c := range defaultChecks() {
+		checks[c.name] = c
+	}
+	tests := []struct {
+		check string
+		deps  *Dependencies
+		ok    bool
+	}{
+		{check: "kubernetes", deps: deps, ok: true},
+		{check: "aem-operator", deps: deps, ok: true},
+		{check: "cert-manager", deps: deps, ok: false},
+		{check: "secrets", deps: deps, ok: true},
+		{check: "secrets", deps: &withoutSecrets, ok: false},
+	}
+	for _, test := range tests {
+		result := runCheck(checks[test.check], test.deps)
+		if ok := result.Status == bedrock.HealthOK; ok != test.ok {
+			t.Errorf("%v: expected ok %v, got %+v", test.check, test.ok, result)
+		}
+	}
+}
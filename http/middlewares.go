@@ -1,10 +1,23 @@
 package http
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/xumak-grid/bedrock/k8s"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/audit"
+	"github.com/xumak-grid/bedrock/auth"
+	"github.com/xumak-grid/bedrock/metrics"
+	"github.com/xumak-grid/bedrock/openapi"
 )
 
 // ContextKey represents a string key for request context.
@@ -19,18 +32,247 @@ const K8sAEMClient = ContextKey("aemk8scli")
 // CertManagerClientKey context key
 const CertManagerClientKey = ContextKey("certManagerClient")
 
-// WithKubeClient adapts a handler with a KubeClient.
-func WithKubeClient() func(http.Handler) http.Handler {
+// SecretServiceKey context key
+const SecretServiceKey = ContextKey("secretService")
+
+// PresignerKey context key
+const PresignerKey = ContextKey("presigner")
+
+// PrincipalKey context key
+const PrincipalKey = ContextKey("principal")
+
+// AuditKey context key
+const AuditKey = ContextKey("audit")
+
+// WithDependencies adapts a handler with the clients and services returned by deps,
+// the clients are created once by the server and shared by all the requests.
+func WithDependencies(deps func() *Dependencies) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d := deps()
+			ctx := context.WithValue(r.Context(), K8sClient, d.KubeClient)
+			ctx = context.WithValue(ctx, K8sAEMClient, d.AEMClient)
+			ctx = context.WithValue(ctx, CertManagerClientKey, d.CertManagerClient)
+			ctx = context.WithValue(ctx, SecretServiceKey, d.Secrets)
+			ctx = context.WithValue(ctx, PresignerKey, d.Presigner)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Authenticate adapts a handler with the principal obtained by the authenticator,
+// requests without valid credentials are rejected
+func Authenticate(a auth.Authenticator) func(http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			cfg, _ := k8s.BuildKubeConfig()
-			client := k8s.NewKubeClient(cfg)
-			aem, _ := k8s.AEMClient(cfg)
-			certManagerClient, _ := k8s.CertManagerClient(cfg)
-			ctx := context.WithValue(r.Context(), K8sClient, client)
-			ctx = context.WithValue(ctx, K8sAEMClient, aem)
-			ctx = context.WithValue(ctx, CertManagerClientKey, certManagerClient)
+			principal, err := a.Authenticate(r)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				jsonError(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			ctx := context.WithValue(r.Context(), PrincipalKey, principal)
 			h.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// AuthorizeClient rejects the requests when the principal is not allowed
+// to use the method on the client in the clientId url param
+func AuthorizeClient() func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !authorize(w, r, chi.URLParam(r, "clientId")) {
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AuthorizeGlobal rejects the requests when the principal is not allowed
+// to use the method on resources that do not belong to a client
+func AuthorizeGlobal() func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !authorize(w, r, "") {
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authorize writes a forbidden error and returns false when the principal
+// of the request is not allowed to use the method on the client
+func authorize(w http.ResponseWriter, r *http.Request, clientID string) bool {
+	err := getPrincipal(r).Authorize(clientID, r.Method)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// maxAuditBody is the largest request body recorded by Audit, a larger body is passed complete to
+// the handler but it is not recorded, its prefix is not valid json and the secrets can't be redacted
+const maxAuditBody = 64 << 10
+
+// auditBodyTooLarge replaces in the record a body larger than maxAuditBody
+const auditBodyTooLarge = "[body larger than 64KB not recorded]"
+
+// auditEntry is the audit state of a request
+type auditEntry struct {
+	log *audit.Log
+	// force records a request that is not recorded by default, for example a GET that reveals a secret
+	force bool
+}
+
+// Audit records in log the requests that are not GET, HEAD or OPTIONS with the principal, the route,
+// the body with the secrets redacted, the status and the duration, the handlers use auditRequest
+// to record other requests
+func Audit(log *audit.Log) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entry := &auditEntry{log: log}
+			var recordedBody interface{}
+			if isMutating(r.Method) && r.Body != nil {
+				body, _ := ioutil.ReadAll(io.LimitReader(r.Body, maxAuditBody+1))
+				r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+				if len(body) > maxAuditBody {
+					recordedBody = auditBodyTooLarge
+				} else {
+					recordedBody = audit.RedactBody(body)
+				}
+			}
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			h.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), AuditKey, entry)))
+			if !isMutating(r.Method) && !entry.force {
+				return
+			}
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			record := bedrock.AuditRecord{
+				Time:       start.UTC(),
+				Method:     r.Method,
+				Route:      routePattern(r),
+				Path:       r.URL.RequestURI(),
+				Body:       recordedBody,
+				Status:     status,
+				DurationMs: int64(time.Since(start) / time.Millisecond),
+				RequestID:  middleware.GetReqID(r.Context()),
+			}
+			if principal := getPrincipal(r); principal != nil {
+				record.Actor = principal.Subject
+			}
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				record.ClientID = rctx.URLParam("clientId")
+				record.EnvironmentID = rctx.URLParam("environmentId")
+			}
+			// the clients are created with the id in the body
+			if b, ok := record.Body.(map[string]interface{}); ok && record.ClientID == "" {
+				record.ClientID, _ = b["clientId"].(string)
+			}
+			log.Record(record)
+		})
+	}
+}
+
+// readCloser reads the body already read by Audit and the rest of the request body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// isMutating returns true for the methods that are recorded by Audit
+func isMutating(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions
+}
+
+// auditRequest records the request in the audit log even when the method is not recorded by default
+func auditRequest(r *http.Request) {
+	if entry, ok := r.Context().Value(AuditKey).(*auditEntry); ok {
+		entry.force = true
+	}
+}
+
+// Instrument observes the latency of the requests by chi route pattern
+func Instrument() func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			h.ServeHTTP(ww, r)
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			metrics.RequestDuration.
+				WithLabelValues(r.Method, routePattern(r), strconv.Itoa(status)).
+				Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// routePattern joins the patterns of the routers that matched the request,
+// for example /api/v1/clients/{clientId}/scm/{scmId}
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || len(rctx.RoutePatterns) == 0 {
+		return "unmatched"
+	}
+	pattern := strings.Join(rctx.RoutePatterns, "")
+	for strings.Contains(pattern, "/*/") {
+		pattern = strings.Replace(pattern, "/*/", "/", -1)
+	}
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	return pattern
+}
+
+// ValidateRequests rejects with 400 the request bodies that do not match the schema of
+// the operation in spec, the body is restored for the handler when it is valid
+func ValidateRequests(spec *openapi.Document) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op, ok := spec.Find(r.Method, routePath(r))
+			if !ok || op.RequestBody == nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+			data, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				writeError(w, validationError("invalid body: "+err.Error()))
+				return
+			}
+			var body interface{}
+			err = json.Unmarshal(data, &body)
+			if err != nil {
+				writeError(w, validationError("invalid body: "+err.Error()))
+				return
+			}
+			errs := spec.ValidateBody(op.RequestBody.Content[openapi.ContentType].Schema, body)
+			if len(errs) > 0 {
+				writeError(w, validationError("the body does not match the schema", errs...))
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(data))
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// routePath returns the path of the request relative to the router that is serving it,
+// for example /clients/acme when the api router is mounted in /api/v1
+func routePath(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx != nil && rctx.RoutePath != "" {
+		return rctx.RoutePath
+	}
+	return r.URL.Path
+}
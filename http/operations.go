@@ -0,0 +1,265 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/xumak-grid/bedrock"
+)
+
+const (
+	// fullDeployOperation is the operation type used when a client is created with customConfig
+	fullDeployOperation = "fullDeploy"
+	// operationRetention is the time that a finished operation is kept in memory
+	operationRetention = 24 * time.Hour
+	// operationRollbackTimeout is the time given to the interrupted operations to roll back
+	operationRollbackTimeout = 20 * time.Second
+)
+
+// errInterrupted is the error of the operations interrupted by the shutdown of the api
+var errInterrupted = errors.New("interrupted by the shutdown of the api")
+
+// operations stores the operations created by the handlers
+var operations = newOperationStore()
+
+// operationStore keeps in memory the state of the operations
+type operationStore struct {
+	mu  sync.RWMutex
+	ops map[string]*bedrock.Operation
+	// running tracks the goroutines started with run
+	running sync.WaitGroup
+	// interrupted is closed when the running operations must stop, see wait
+	interrupted   chan struct{}
+	interruptOnce sync.Once
+}
+
+func newOperationStore() *operationStore {
+	return &operationStore{
+		ops:         map[string]*bedrock.Operation{},
+		interrupted: make(chan struct{}),
+	}
+}
+
+// create registers a new pending operation with the given steps
+func (s *operationStore) create(clientID, opType string, steps []string) bedrock.Operation {
+	op := &bedrock.Operation{
+		OperationID: newOperationID(),
+		ClientID:    clientID,
+		Type:        opType,
+		Status:      bedrock.OperationPending,
+		Steps:       []bedrock.OperationStep{},
+		CreatedAt:   time.Now().UTC(),
+	}
+	for _, step := range steps {
+		op.Steps = append(op.Steps, bedrock.OperationStep{Name: step, Status: bedrock.OperationPending})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	s.ops[op.OperationID] = op
+	return copyOperation(op)
+}
+
+// run executes fn in background, wait blocks until every fn is finished
+func (s *operationStore) run(fn func()) {
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		fn()
+	}()
+}
+
+// wait blocks until the operations started with run are finished or ctx is done,
+// when ctx is done the operations are interrupted before their next step and wait
+// blocks up to operationRollbackTimeout while they roll back the created resources
+func (s *operationStore) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	s.interrupt()
+	select {
+	case <-done:
+	case <-time.After(operationRollbackTimeout):
+	}
+	return ctx.Err()
+}
+
+// interrupt stops the running operations before their next step
+func (s *operationStore) interrupt() {
+	s.interruptOnce.Do(func() { close(s.interrupted) })
+}
+
+// isInterrupted returns true after interrupt
+func (s *operationStore) isInterrupted() bool {
+	select {
+	case <-s.interrupted:
+		return true
+	default:
+		return false
+	}
+}
+
+// get returns a copy of the operation with the given id
+func (s *operationStore) get(id string) (bedrock.Operation, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	op, ok := s.ops[id]
+	if !ok {
+		return bedrock.Operation{}, false
+	}
+	return copyOperation(op), true
+}
+
+// startStep marks the operation and the given step as running
+func (s *operationStore) startStep(id, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, ok := s.ops[id]
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	op.Status = bedrock.OperationRunning
+	for i := range op.Steps {
+		if op.Steps[i].Name == name {
+			op.Steps[i].Status = bedrock.OperationRunning
+			op.Steps[i].StartedAt = &now
+		}
+	}
+}
+
+// finishStep marks the given step as succeeded or failed depending on err
+func (s *operationStore) finishStep(id, name string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, ok := s.ops[id]
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	for i := range op.Steps {
+		if op.Steps[i].Name == name {
+			op.Steps[i].Status = bedrock.OperationSucceeded
+			op.Steps[i].FinishedAt = &now
+			if err != nil {
+				op.Steps[i].Status = bedrock.OperationFailed
+				op.Steps[i].Error = err.Error()
+			}
+		}
+	}
+}
+
+// rolledBack marks the operation as rolled back
+func (s *operationStore) rolledBack(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, ok := s.ops[id]
+	if !ok {
+		return
+	}
+	op.RolledBack = true
+}
+
+// finish marks the operation as succeeded or failed depending on err,
+// the result is kept without the custom configurations, see operationResult
+func (s *operationStore) finish(id string, result *bedrock.FullDeploy, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, ok := s.ops[id]
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	op.FinishedAt = &now
+	op.Status = bedrock.OperationSucceeded
+	op.Result = operationResult(result)
+	if err != nil {
+		op.Status = bedrock.OperationFailed
+		op.Error = err.Error()
+	}
+}
+
+// operationResult returns a copy of the full deploy without the custom configurations of the
+// artifactory and the scm, they include the admin and the proxy passwords
+func operationResult(fullDeploy *bedrock.FullDeploy) *bedrock.FullDeploy {
+	if fullDeploy == nil {
+		return nil
+	}
+	result := *fullDeploy
+	result.Artifactory.Configuration = nil
+	result.SCM.Configuration = nil
+	return &result
+}
+
+// prune removes the finished operations older than operationRetention
+// the caller must hold the lock
+func (s *operationStore) prune() {
+	limit := time.Now().UTC().Add(-operationRetention)
+	for id, op := range s.ops {
+		if op.FinishedAt != nil && op.FinishedAt.Before(limit) {
+			delete(s.ops, id)
+		}
+	}
+}
+
+// copyOperation returns a copy of op that can be used outside the lock
+func copyOperation(op *bedrock.Operation) bedrock.Operation {
+	c := *op
+	c.Steps = make([]bedrock.OperationStep, len(op.Steps))
+	copy(c.Steps, op.Steps)
+	return c
+}
+
+// newOperationID returns a random hex id
+func newOperationID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// operationProgress reports the progress of the full deploy steps to an operation
+type operationProgress struct {
+	store *operationStore
+	id    string
+}
+
+func (p operationProgress) start(step string) error {
+	if p.store.isInterrupted() {
+		return errInterrupted
+	}
+	p.store.startStep(p.id, step)
+	return nil
+}
+
+func (p operationProgress) finish(step string, err error) {
+	p.store.finishStep(p.id, step, err)
+}
+
+// getOperationHandler returns the state of an operation
+// the operations of clients that the principal can not access are not found
+func getOperationHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "operationId")
+	op, ok := operations.get(id)
+	if !ok || !getPrincipal(r).CanAccess(op.ClientID) {
+		jsonError(w, "operation not found", http.StatusNotFound)
+		return
+	}
+	encode(w, op)
+}
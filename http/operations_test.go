@@ -0,0 +1,99 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/auth"
+)
+
+func TestOperationSteps(t *testing.T) {
+	store := newOperationStore()
+	op := store.create("acme", fullDeployOperation, []string{"dev", artifactoryStep, scmStep})
+	if op.Status != bedrock.OperationPending || len(op.Steps) != 3 {
+		t.Fatalf("expected a pending operation with 3 steps, got %+v", op)
+	}
+	for _, step := range op.Steps {
+		if step.Status != bedrock.OperationPending {
+			t.Errorf("expected the step %v pending, got %+v", step.Name, step)
+		}
+	}
+
+	progress := operationProgress{store: store, id: op.OperationID}
+	progress.start("dev")
+	progress.finish("dev", nil)
+	progress.start(artifactoryStep)
+	running, _ := store.get(op.OperationID)
+	if running.Status != bedrock.OperationRunning {
+		t.Errorf("expected a running operation, got %v", running.Status)
+	}
+	if step := running.Steps[0]; step.Status != bedrock.OperationSucceeded || step.StartedAt == nil || step.FinishedAt == nil {
+		t.Errorf("expected the first step succeeded, got %+v", step)
+	}
+	if step := running.Steps[1]; step.Status != bedrock.OperationRunning || step.FinishedAt != nil {
+		t.Errorf("expected the second step running, got %+v", step)
+	}
+	// the copies are not changed by the next steps
+	running.Steps[2].Status = bedrock.OperationFailed
+
+	err := errors.New("nexus unavailable")
+	progress.finish(artifactoryStep, err)
+	store.finish(op.OperationID, nil, err)
+	finished, _ := store.get(op.OperationID)
+	if finished.Status != bedrock.OperationFailed || finished.Error != err.Error() || finished.FinishedAt == nil {
+		t.Errorf("expected a failed operation, got %+v", finished)
+	}
+	if step := finished.Steps[1]; step.Status != bedrock.OperationFailed || step.Error != err.Error() {
+		t.Errorf("expected the second step failed with the error, got %+v", step)
+	}
+	if step := finished.Steps[2]; step.Status != bedrock.OperationPending {
+		t.Errorf("expected the last step pending, got %+v", step)
+	}
+}
+
+func TestOperationRetention(t *testing.T) {
+	store := newOperationStore()
+	old := store.create("acme", fullDeployOperation, nil)
+	store.finish(old.OperationID, nil, nil)
+	running := store.create("acme", fullDeployOperation, nil)
+
+	expired := time.Now().UTC().Add(-operationRetention - time.Minute)
+	store.mu.Lock()
+	store.ops[old.OperationID].FinishedAt = &expired
+	store.ops[running.OperationID].CreatedAt = expired
+	store.mu.Unlock()
+
+	store.create("acme", fullDeployOperation, nil)
+	if _, ok := store.get(old.OperationID); ok {
+		t.Error("expected the old finished operation removed")
+	}
+	if _, ok := store.get(running.OperationID); !ok {
+		t.Error("expected the unfinished operation kept")
+	}
+}
+
+func TestOperationHandler(t *testing.T) {
+	defer func(store *operationStore) { operations = store }(operations)
+	operations = newOperationStore()
+	a := newTestAPI(t)
+	defer a.close()
+	op := operations.create("acme", fullDeployOperation, []string{"dev"})
+	other := operations.create("globex", fullDeployOperation, []string{"dev"})
+	a.authenticate(auth.NewStaticTokens([]auth.StaticToken{
+		{Token: "acme", Subject: "portal", Grant: auth.Grant{ClientIDs: []string{"acme"}, Role: auth.ReadOnly}},
+	}), "acme")
+
+	a.run([]handlerTest{
+		{name: "operation", method: "GET", path: "/operations/" + op.OperationID, status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			pending := bedrock.Operation{}
+			decodeBody(t, body, &pending)
+			if pending.OperationID != op.OperationID || pending.Status != bedrock.OperationPending || len(pending.Steps) != 1 {
+				t.Errorf("expected the pending operation, got %+v", pending)
+			}
+		}},
+		{name: "operation of another client", method: "GET", path: "/operations/" + other.OperationID, status: http.StatusNotFound},
+	})
+}
@@ -0,0 +1,225 @@
+package http
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	aemclientset "github.com/xumak-grid/aem-operator/pkg/generated/clientset/versioned"
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/k8s"
+	"github.com/xumak-grid/bedrock/secrets"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// passwordLength is the length of the generated admin passwords
+	passwordLength = 24
+	// passwordChars are the characters of the generated passwords, without symbols
+	// to avoid escaping them in the AEM configuration
+	passwordChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// maxWrapTTL is the longest life of a wrapping token
+	maxWrapTTL = time.Hour
+)
+
+// revealInstancePassword returns the admin password of the instance in the url, it requires
+// the secrets-admin role, with the wrapTTL query param the password is returned wrapped in a
+// single use token that expires after the duration, for example ?wrapTTL=5m
+func revealInstancePassword(w http.ResponseWriter, r *http.Request) {
+	creds := bedrock.InstanceCredentials{
+		ClientID:      chi.URLParam(r, "clientId"),
+		EnvironmentID: chi.URLParam(r, "environmentId"),
+		Instance:      chi.URLParam(r, "instanceId"),
+	}
+	// every attempt to reveal a password is recorded, also the denied ones
+	auditRequest(r)
+	err := getPrincipal(r).AuthorizeSecrets(creds.ClientID)
+	if err != nil {
+		jsonError(w, "the secrets-admin role is required to reveal the passwords", http.StatusForbidden)
+		return
+	}
+	var wrapTTL time.Duration
+	if v := r.URL.Query().Get("wrapTTL"); v != "" {
+		wrapTTL, err = time.ParseDuration(v)
+		if err != nil || wrapTTL <= 0 || wrapTTL > maxWrapTTL {
+			writeError(w, fieldError("wrapTTL", fmt.Sprintf("wrapTTL must be a duration up to %v", maxWrapTTL)))
+			return
+		}
+	}
+	err = checkInstance(r, creds.ClientID, creds.EnvironmentID, creds.Instance)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	secretService := getSecretService(r)
+	key := getPodSecretKey(creds.ClientID, creds.EnvironmentID, creds.Instance)
+	if wrapTTL > 0 {
+		wrapper, ok := secretService.(secrets.Wrapper)
+		if !ok {
+			writeError(w, fieldError("wrapTTL", "the secret service does not support response wrapping"))
+			return
+		}
+		wrapped, err := wrapper.Wrap(key, wrapTTL)
+		if err != nil {
+			writeError(w, upstreamError("secrets", err))
+			return
+		}
+		creds.WrappingToken = wrapped.Token
+		creds.WrappingExpiresAt = &wrapped.ExpiresAt
+	} else {
+		creds.Password, err = getPodPassword(secretService, creds.ClientID, creds.EnvironmentID, creds.Instance)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if creds.Password == "" {
+			writeError(w, notFoundError("the instance %v has no password", creds.Instance))
+			return
+		}
+	}
+	encode(w, creds)
+}
+
+// checkInstance returns a not found error when the instance is not a pod of the environment
+func checkInstance(r *http.Request, clientID, environmentID, instance string) error {
+	pods, err := k8s.ListAEMDeploymentPods(getK8Client(r), &bedrock.AEMDeployment{ClientID: clientID, EnvironmentID: environmentID})
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		if pod.Name == instance {
+			return nil
+		}
+	}
+	return notFoundError("instance %v not found in the environment %v", instance, environmentID)
+}
+
+// rotateEnvironmentPasswords rotates the admin password of all the instances of the environment
+func rotateEnvironmentPasswords(w http.ResponseWriter, r *http.Request) {
+	rotatePasswordsHandler(w, r, "")
+}
+
+// rotateInstancePassword rotates the admin password of the instance in the url
+func rotateInstancePassword(w http.ResponseWriter, r *http.Request) {
+	rotatePasswordsHandler(w, r, chi.URLParam(r, "instanceId"))
+}
+
+// rotatePasswordsHandler rotates the passwords of the instances of the environment,
+// only the instance with the given name when is not empty
+func rotatePasswordsHandler(w http.ResponseWriter, r *http.Request, instance string) {
+	aemDeploy := bedrock.AEMDeployment{}
+	aemDeploy.ClientID = chi.URLParam(r, "clientId")
+	aemDeploy.EnvironmentID = chi.URLParam(r, "environmentId")
+	err := checkActiveClient(r, aemDeploy.ClientID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	rotation, err := rotatePasswords(getK8Client(r), getAEMClient(r), getSecretService(r), aemDeploy, instance, getPrincipal(r).Subject)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	encode(w, rotation)
+}
+
+// rotatePasswords stores a new password for the instances in the secret service and requests
+// the change to the aem-operator, the previous passwords are restored when the request fails
+func rotatePasswords(kubecli kubernetes.Interface, aemcli aemclientset.Interface, secretService secrets.SecretService, aemDeploy bedrock.AEMDeployment, instance, subject string) (bedrock.PasswordRotation, error) {
+	rotation := bedrock.PasswordRotation{
+		ClientID:      aemDeploy.ClientID,
+		EnvironmentID: aemDeploy.EnvironmentID,
+		Instances:     []string{},
+		RotatedAt:     time.Now().UTC(),
+		RotatedBy:     subject,
+	}
+	_, err := k8s.GetAEMDeployment(aemcli, &aemDeploy)
+	if err != nil {
+		return rotation, err
+	}
+	pods, err := k8s.ListAEMDeploymentPods(kubecli, &aemDeploy)
+	if err != nil {
+		return rotation, err
+	}
+	for _, pod := range pods {
+		if instance == "" || pod.Name == instance {
+			rotation.Instances = append(rotation.Instances, pod.Name)
+		}
+	}
+	if instance != "" && len(rotation.Instances) == 0 {
+		return rotation, notFoundError("instance %v not found in the environment %v", instance, aemDeploy.EnvironmentID)
+	}
+
+	previous := map[string]map[string]interface{}{}
+	for _, name := range rotation.Instances {
+		key := getPodSecretKey(aemDeploy.ClientID, aemDeploy.EnvironmentID, name)
+		current, err := secretService.Get(key)
+		if err != nil {
+			restorePasswords(secretService, previous)
+			return rotation, upstreamError("secrets", err)
+		}
+		password, err := newPassword()
+		if err != nil {
+			restorePasswords(secretService, previous)
+			return rotation, err
+		}
+		value := map[string]interface{}{}
+		for k, v := range current {
+			value[k] = v
+		}
+		value["password"] = password
+		if current["password"] != nil {
+			value["previousPassword"] = current["password"]
+		}
+		value["rotatedAt"] = rotation.RotatedAt.Format(time.RFC3339)
+		value["rotatedBy"] = subject
+		err = secretService.Put(key, value)
+		if err != nil {
+			restorePasswords(secretService, previous)
+			return rotation, upstreamError("secrets", err)
+		}
+		previous[key] = current
+	}
+
+	err = k8s.RequestPasswordRotation(aemcli, &aemDeploy, rotation.Instances, rotation.RotatedAt)
+	if err != nil {
+		restorePasswords(secretService, previous)
+		return rotation, err
+	}
+	return rotation, nil
+}
+
+// restorePasswords puts back the secrets of a failed rotation, the secrets that did not exist are deleted
+func restorePasswords(secretService secrets.SecretService, previous map[string]map[string]interface{}) {
+	for key, value := range previous {
+		var err error
+		if len(value) == 0 {
+			err = secretService.Delete(key)
+		} else {
+			err = secretService.Put(key, value)
+		}
+		if err != nil {
+			log.Printf("error restoring the password of %v: %v", key, err)
+		}
+	}
+}
+
+// newPassword generates a random password of passwordLength characters
+func newPassword() (string, error) {
+	password := make([]byte, passwordLength)
+	max := big.NewInt(int64(len(passwordChars)))
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordChars[n.Int64()]
+	}
+	return string(password), nil
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/stack/gogs"
+	"github.com/xumak-grid/bedrock/stack/nexus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// hasJob checks that the init job exists or not in the namespace acme
+func hasJob(a *testAPI, name string, exists bool) func(t *testing.T, body []byte) {
+	return func(t *testing.T, body []byte) {
+		_, err := a.deps.KubeClient.BatchV1().Jobs("acme").Get(name, metav1.GetOptions{})
+		if exists && err != nil {
+			t.Errorf("expected the job %v, got %v", name, err)
+		}
+		if !exists && !k8serrors.IsNotFound(err) {
+			t.Errorf("expected the job %v removed, got %v", name, err)
+		}
+	}
+}
+
+// hasImage checks the image of the stack in the response body
+func hasImage(image string) func(t *testing.T, body []byte) {
+	return func(t *testing.T, body []byte) {
+		stack := struct {
+			Image string `json:"image"`
+		}{}
+		decodeBody(t, body, &stack)
+		if stack.Image != image {
+			t.Errorf("expected the image %v, got %v", image, stack.Image)
+		}
+	}
+}
+
+func TestPatchArtifactory(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	a.createClient("acme")
+	config := bedrock.ArtifactoryConfig{Groups: []bedrock.ArtifactoryGroup{{Name: "developers", Members: []string{"jane"}}}}
+
+	a.run([]handlerTest{
+		{name: "patch without artifactory", method: "PATCH", path: "/clients/acme/artifactory/nexus", body: map[string]interface{}{"image": "grid/nexus:3.9.0"}, status: http.StatusNotFound},
+		{name: "create", method: "POST", path: "/clients/acme/artifactory", body: bedrock.Artifactory{ArtifactoryID: "nexus", Image: "grid/nexus:3.8.0"}, status: http.StatusCreated},
+		{name: "unknown vendor", method: "PATCH", path: "/clients/acme/artifactory/jfrog", body: map[string]interface{}{}, status: http.StatusNotFound},
+		{name: "unknown image", method: "PATCH", path: "/clients/acme/artifactory/nexus", body: map[string]interface{}{"image": "grid/nexus:2.0.0"}, status: http.StatusBadRequest, check: hasReason(bedrock.ReasonValidation)},
+		{name: "custom config without configuration", method: "PATCH", path: "/clients/acme/artifactory/nexus", body: map[string]interface{}{"customConfig": true}, status: http.StatusBadRequest, check: hasReason(bedrock.ReasonValidation)},
+		{name: "image", method: "PATCH", path: "/clients/acme/artifactory/nexus", body: map[string]interface{}{"image": "grid/nexus:3.9.0"}, status: http.StatusOK, check: hasImage("grid/nexus:3.9.0")},
+		{name: "get upgraded", method: "GET", path: "/clients/acme/artifactory/nexus", status: http.StatusOK, check: hasImage("grid/nexus:3.9.0")},
+		{name: "custom config", method: "PATCH", path: "/clients/acme/artifactory/nexus", body: map[string]interface{}{"customConfig": true, "configuration": config}, status: http.StatusOK, check: hasJob(a, nexus.InitJobName, true)},
+		{name: "without custom config", method: "PATCH", path: "/clients/acme/artifactory/nexus", body: map[string]interface{}{"customConfig": false}, status: http.StatusOK, check: hasJob(a, nexus.InitJobName, false)},
+	})
+}
+
+func TestPatchSCM(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	a.createClient("acme")
+	config := bedrock.SCMConfig{
+		InitData: &bedrock.SCMInitData{AdminName: "gridadmin", AdminEmail: "admin@acme.test", AdminPass: "s3cret", AdminConfirmPass: "s3cret"},
+	}
+
+	a.run([]handlerTest{
+		{name: "patch without scm", method: "PATCH", path: "/clients/acme/scm/gogs", body: map[string]interface{}{}, status: http.StatusNotFound},
+		{name: "create", method: "POST", path: "/clients/acme/scm", body: bedrock.SCM{SCMID: "gogs", Image: "grid/gogs:0.11.34"}, status: http.StatusCreated},
+		{name: "unknown vendor", method: "PATCH", path: "/clients/acme/scm/github", body: map[string]interface{}{}, status: http.StatusNotFound},
+		{name: "unknown image", method: "PATCH", path: "/clients/acme/scm/gogs", body: map[string]interface{}{"image": "grid/gogs:0.9"}, status: http.StatusBadRequest, check: hasReason(bedrock.ReasonValidation)},
+		{name: "same image", method: "PATCH", path: "/clients/acme/scm/gogs", body: map[string]interface{}{"image": "grid/gogs:0.11.34"}, status: http.StatusOK, check: hasImage("grid/gogs:0.11.34")},
+		{name: "custom config", method: "PATCH", path: "/clients/acme/scm/gogs", body: map[string]interface{}{"customConfig": true, "configuration": config}, status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			scm := bedrock.SCM{}
+			decodeBody(t, body, &scm)
+			if !scm.CustomConfig || scm.Configuration.InitData.Domain == "" {
+				t.Errorf("expected the init defaults of the ingress, got %+v", scm.Configuration.InitData)
+			}
+			hasJob(a, gogs.InitJobName, true)(t, body)
+		}},
+		{name: "without custom config", method: "PATCH", path: "/clients/acme/scm/gogs", body: map[string]interface{}{"customConfig": false}, status: http.StatusOK, check: hasJob(a, gogs.InitJobName, false)},
+	})
+}
+
+func TestPatchCI(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	a.createClient("acme")
+
+	a.run([]handlerTest{
+		{name: "patch without ci", method: "PATCH", path: "/clients/acme/ci/drone", body: map[string]interface{}{}, status: http.StatusNotFound},
+		{name: "create", method: "POST", path: "/clients/acme/ci", body: bedrock.CI{CIID: "drone", Image: "grid/drone:0.8-alpine", SecondImage: "grid/drone-agent:0.8", ScmURL: "https://gogs.acme.test"}, status: http.StatusCreated},
+		{name: "unknown vendor", method: "PATCH", path: "/clients/acme/ci/jenkins", body: map[string]interface{}{}, status: http.StatusNotFound},
+		{name: "unknown agent image", method: "PATCH", path: "/clients/acme/ci/drone", body: map[string]interface{}{"secondImage": "grid/drone-agent:0.5"}, status: http.StatusBadRequest, check: hasReason(bedrock.ReasonValidation)},
+		{name: "images kept", method: "PATCH", path: "/clients/acme/ci/drone", body: map[string]interface{}{}, status: http.StatusOK, check: func(t *testing.T, body []byte) {
+			ci := bedrock.CI{}
+			decodeBody(t, body, &ci)
+			if ci.Image != "grid/drone:0.8-alpine" || ci.SecondImage != "grid/drone-agent:0.8" || ci.Host == "" {
+				t.Errorf("expected the current images, got %+v", ci)
+			}
+		}},
+	})
+}
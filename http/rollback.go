@@ -0,0 +1,88 @@
+package http
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/xumak-grid/bedrock/k8s"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// deployTracker records the resources created during a full deploy
+// so they can be removed in reverse order when a later step fails
+// a nil *deployTracker is valid and records nothing
+type deployTracker struct {
+	resources []trackedResource
+}
+
+// trackedResource is a created resource and the function that removes it
+type trackedResource struct {
+	kind   string
+	name   string
+	remove func() error
+}
+
+// track records a resource when result is k8s.Created, remove is called on rollback
+// resources that already existed are never recorded
+func (t *deployTracker) track(result k8s.ApplyResult, kind, name string, remove func() error) {
+	if t == nil || result != k8s.Created {
+		return
+	}
+	t.resources = append(t.resources, trackedResource{kind: kind, name: name, remove: remove})
+}
+
+// rollback removes the tracked resources in reverse order of creation
+// resources already deleted are ignored, the rest of the errors are returned together
+func (t *deployTracker) rollback() error {
+	if t == nil {
+		return nil
+	}
+	errs := []string{}
+	for i := len(t.resources) - 1; i >= 0; i-- {
+		res := t.resources[i]
+		err := res.remove()
+		if err != nil && !k8serrors.IsNotFound(err) {
+			log.Printf("error: rollback of %v %v failed: %v", res.kind, res.name, err.Error())
+			errs = append(errs, fmt.Sprintf("%v %v: %v", res.kind, res.name, err.Error()))
+			continue
+		}
+		log.Printf("rollback: %v %v removed", res.kind, res.name)
+	}
+	t.resources = nil
+	if len(errs) > 0 {
+		return fmt.Errorf("rollback incomplete: %v", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func trackService(t *deployTracker, result k8s.ApplyResult, kubeCli kubernetes.Interface, ns, name string) {
+	t.track(result, "Service", ns+"/"+name, func() error {
+		return k8s.DeleteService(kubeCli, ns, name)
+	})
+}
+
+func trackIngress(t *deployTracker, result k8s.ApplyResult, kubeCli kubernetes.Interface, ns, name string) {
+	t.track(result, "Ingress", ns+"/"+name, func() error {
+		return k8s.DeleteIngress(kubeCli, ns, name)
+	})
+}
+
+func trackStatefulSet(t *deployTracker, result k8s.ApplyResult, kubeCli kubernetes.Interface, ns, name string) {
+	t.track(result, "StatefulSet", ns+"/"+name, func() error {
+		return k8s.DeleteStatefulSet(kubeCli, ns, name)
+	})
+}
+
+func trackSecret(t *deployTracker, result k8s.ApplyResult, kubeCli kubernetes.Interface, ns, name string) {
+	t.track(result, "Secret", ns+"/"+name, func() error {
+		return k8s.DeleteSecret(kubeCli, ns, name)
+	})
+}
+
+func trackJob(t *deployTracker, result k8s.ApplyResult, kubeCli kubernetes.Interface, ns, name string) {
+	t.track(result, "Job", ns+"/"+name, func() error {
+		return k8s.DeleteJob(kubeCli, ns, name)
+	})
+}
@@ -0,0 +1,137 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/k8s"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDeployTrackerRollback(t *testing.T) {
+	var nilTracker *deployTracker
+	nilTracker.track(k8s.Created, "Service", "acme/web", func() error { return nil })
+	if err := nilTracker.rollback(); err != nil {
+		t.Errorf("expected a nil tracker to do nothing, got %v", err)
+	}
+
+	removed := []string{}
+	remove := func(name string, err error) func() error {
+		return func() error {
+			removed = append(removed, name)
+			return err
+		}
+	}
+	tracker := &deployTracker{}
+	tracker.track(k8s.Created, "Service", "acme/first", remove("first", nil))
+	tracker.track(k8s.Unchanged, "Service", "acme/existing", remove("existing", nil))
+	tracker.track(k8s.Updated, "Service", "acme/updated", remove("updated", nil))
+	tracker.track(k8s.Created, "Secret", "acme/gone", remove("gone", k8serrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "gone")))
+	tracker.track(k8s.Created, "Job", "acme/failing", remove("failing", errors.New("forbidden")))
+	tracker.track(k8s.Created, "Ingress", "acme/last", remove("last", nil))
+
+	err := tracker.rollback()
+	if expected := []string{"last", "failing", "gone", "first"}; !reflect.DeepEqual(removed, expected) {
+		t.Errorf("expected the created resources removed in reverse order %v, got %v", expected, removed)
+	}
+	if err == nil || !strings.Contains(err.Error(), "Job acme/failing: forbidden") || strings.Contains(err.Error(), "gone") {
+		t.Errorf("expected only the error of the failing removal, got %v", err)
+	}
+	if err := tracker.rollback(); err != nil || len(removed) != 4 {
+		t.Errorf("expected a second rollback to do nothing, got %v", err)
+	}
+}
+
+// failSCM makes the creation of the gogs StatefulSet fail, after the AEM deployments and the artifactory
+func failSCM(a *testAPI) {
+	a.deps.KubeClient.(*fake.Clientset).PrependReactor("create", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		set := action.(k8stesting.CreateAction).GetObject().(*appsv1beta2.StatefulSet)
+		if strings.HasPrefix(set.Name, "gogs") {
+			return true, nil, errors.New("quota exceeded")
+		}
+		return false, nil, nil
+	})
+}
+
+// runFailedFullDeploy creates the full deploy of c and returns its finished operation
+func runFailedFullDeploy(t *testing.T, a *testAPI, c bedrock.Client) bedrock.Operation {
+	op := bedrock.Operation{}
+	a.run([]handlerTest{
+		{name: "full deploy", method: "POST", path: "/clients", body: c, status: http.StatusAccepted, check: func(t *testing.T, body []byte) {
+			decodeBody(t, body, &op)
+		}},
+	})
+	a.waitOperations()
+	finished, _ := operations.get(op.OperationID)
+	if finished.Status != bedrock.OperationFailed || !strings.Contains(finished.Error, "quota exceeded") {
+		t.Fatalf("expected the full deploy failed in the scm, got %+v", finished)
+	}
+	for _, step := range finished.Steps {
+		if step.Name == scmStep && step.Status != bedrock.OperationFailed {
+			t.Errorf("expected the scm step failed, got %+v", step)
+		}
+	}
+	return finished
+}
+
+func TestFullDeployRollback(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	failSCM(a)
+
+	op := runFailedFullDeploy(t, a, fullDeployClient("acme", false))
+	if !op.RolledBack {
+		t.Errorf("expected the operation rolled back, got %+v", op)
+	}
+	deployments, _ := a.deps.AEMClient.AemV1beta1().AEMDeployments("acme").List(metav1.ListOptions{})
+	if len(deployments.Items) != 0 {
+		t.Errorf("expected the AEM deployments removed, got %v", len(deployments.Items))
+	}
+	sets, _ := a.deps.KubeClient.AppsV1beta2().StatefulSets("acme").List(metav1.ListOptions{})
+	if len(sets.Items) != 0 {
+		t.Errorf("expected the artifactory StatefulSet removed, got %v", len(sets.Items))
+	}
+	services, _ := a.deps.KubeClient.CoreV1().Services("acme").List(metav1.ListOptions{})
+	if len(services.Items) != 0 {
+		t.Errorf("expected the Services removed, got %v", len(services.Items))
+	}
+	_, err := a.deps.CertManagerClient.Certmanager().Certificates("acme").Get(k8s.CertificateName("acme"), metav1.GetOptions{})
+	if !k8serrors.IsNotFound(err) {
+		t.Errorf("expected the Certificate removed, got %v", err)
+	}
+	// the namespace is kept to allow a new attempt
+	a.run([]handlerTest{
+		{name: "client", method: "GET", path: "/clients/acme", status: http.StatusOK},
+	})
+}
+
+func TestFullDeployKeepOnFailure(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	failSCM(a)
+
+	c := fullDeployClient("acme", false)
+	c.KeepOnFailure = true
+	op := runFailedFullDeploy(t, a, c)
+	if op.RolledBack {
+		t.Errorf("expected the operation not rolled back, got %+v", op)
+	}
+	deployments, _ := a.deps.AEMClient.AemV1beta1().AEMDeployments("acme").List(metav1.ListOptions{})
+	if len(deployments.Items) != 2 {
+		t.Errorf("expected the AEM deployments kept, got %v", len(deployments.Items))
+	}
+	sets, _ := a.deps.KubeClient.AppsV1beta2().StatefulSets("acme").List(metav1.ListOptions{})
+	if len(sets.Items) != 1 {
+		t.Errorf("expected the artifactory StatefulSet kept, got %v", len(sets.Items))
+	}
+}
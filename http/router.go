@@ -9,13 +9,27 @@ import (
 func clientsRouter(r chi.Router) {
 	r.Get("/", ListClients)
 	r.Post("/", createClientHandler)
-	r.Get("/{clientId}", GetClient)
-	r.Delete("/{clientId}", DeleteClient)
-	r.Route("/{clientId}/environments", environmentsRouter)
-	r.Route("/{clientId}/tools", toolsRouter)
-	r.Route("/{clientId}/artifactory", artifactoryRouter)
-	r.Route("/{clientId}/scm", scmRouter)
-	r.Route("/{clientId}/ci", ciRouter)
+	r.Route("/{clientId}", clientRouter)
+}
+
+func clientRouter(r chi.Router) {
+	r.Use(AuthorizeClient())
+	r.Get("/", GetClient)
+	r.Patch("/", updateClientHandler)
+	r.Get("/summary", getClientSummaryHandler)
+	r.Delete("/", DeleteClient)
+	r.Get("/audit", getAuditHandler)
+	r.Post("/suspend", suspendClientHandler)
+	r.Post("/resume", resumeClientHandler)
+	r.Post("/protect", protectClientHandler)
+	r.Post("/unprotect", unprotectClientHandler)
+	r.Get("/deletion", getDeletionHandler)
+	r.Delete("/deletion", cancelDeletionHandler)
+	r.Route("/environments", environmentsRouter)
+	r.Route("/tools", toolsRouter)
+	r.Route("/artifactory", artifactoryRouter)
+	r.Route("/scm", scmRouter)
+	r.Route("/ci", ciRouter)
 }
 
 func artifactoryRouter(r chi.Router) {
@@ -57,6 +71,9 @@ func environmentsRouter(r chi.Router) {
 	r.Patch("/{environmentId}/aem", updateAEMDeployment)
 	r.Delete("/{environmentId}/aem", deleteAEMDeployment)
 	r.Get("/{environmentId}/aem/instances", ListAEMPods)
+	r.Get("/{environmentId}/aem/instances/{instanceId}/password", revealInstancePassword)
+	r.Post("/{environmentId}/aem/instances/{instanceId}/password/rotate", rotateInstancePassword)
+	r.Post("/{environmentId}/aem/passwords/rotate", rotateEnvironmentPasswords)
 	r.Route("/{environmentId}/aem/dispatcherconfig", dispatcherRouter)
 }
 
@@ -75,16 +92,29 @@ func instancesRouter(r chi.Router) {
 	r.Get("/type/list", instanceTypeList)
 }
 
-func globalToolsRouter(r chi.Router) {
+func operationsRouter(r chi.Router) {
+	r.Get("/{operationId}", getOperationHandler)
+}
 
+// apiRoutes registers the routes of the api, every route must be documented in endpoints
+func apiRoutes(r chi.Router) {
+	r.Route("/clients", clientsRouter)
+	r.Route("/operations", operationsRouter)
+	r.Group(func(r chi.Router) {
+		r.Use(AuthorizeGlobal())
+		r.Route("/vendors", vendorsRouter)
+		r.Route("/images", imagesRouter)
+		r.Route("/instances", instancesRouter)
+	})
 }
 
 func (s *Server) getAPIRouter() http.Handler {
 	r := chi.NewRouter()
-	r.Route("/clients", clientsRouter)
-	r.Route("/tools", globalToolsRouter)
-	r.Route("/vendors", vendorsRouter)
-	r.Route("/images", imagesRouter)
-	r.Route("/instances", instancesRouter)
+	r.Use(Authenticate(s.Auth))
+	r.Use(Audit(s.Audit))
+	if s.spec != nil {
+		r.Use(ValidateRequests(s.spec))
+	}
+	apiRoutes(r)
 	return r
 }
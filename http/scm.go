@@ -2,13 +2,16 @@ package http
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/go-chi/chi"
 	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/awscli"
 	"github.com/xumak-grid/bedrock/commerce/ep"
 	"github.com/xumak-grid/bedrock/k8s"
+	"github.com/xumak-grid/bedrock/metrics"
 	"github.com/xumak-grid/bedrock/stack/gogs"
 	"k8s.io/client-go/kubernetes"
 )
@@ -26,106 +29,115 @@ func scmVendors() []bedrock.Vendor {
 
 func createSCMHandler(w http.ResponseWriter, r *http.Request) {
 	scm := bedrock.SCM{}
-	decode(r, &scm)
-	if scm.Image == "" {
-		jsonError(w, "image is required", http.StatusBadRequest)
+	err := decode(r, &scm)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = bedrock.Validate(scm)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 	ns := chi.URLParam(r, "clientId")
-	err := checkClient(r, ns)
+	err = checkActiveClient(r, ns)
 	if err != nil {
-		jsonError(w, "invalid clientId", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	if !validVendor(scm.SCMID, scmVendors()) {
-		jsonError(w, "unknown scmId", http.StatusBadRequest)
+		writeError(w, fieldError("scmId", "unknown scmId "+scm.SCMID))
 		return
 	}
 
 	if scm.CustomConfig {
-		if scm.Configuration == nil {
-			jsonError(w, "configuration not provided when the request requires customConfig", http.StatusBadRequest)
-			return
-		}
-		if scm.Configuration.InitData == nil {
-			jsonError(w, "init_data not provided when the request requires customConfig", http.StatusBadRequest)
-			return
-		}
-		d := scm.Configuration.InitData
-		if d.AdminEmail == "" || d.AdminConfirmPass == "" || d.AdminName == "" || d.AdminPass == "" {
-			jsonError(w, "admin account setting is invalid: one or more empty values", http.StatusBadRequest)
-			return
-		}
-		if d.AdminName == "admin" {
-			jsonError(w, "admin account setting is invalid: admin name is reserved", http.StatusBadRequest)
+		err = prepareSCMConfig(getPresigner(r), scm.Configuration)
+		if err != nil {
+			writeError(w, err)
 			return
 		}
-		if len(scm.Configuration.Repositories) > 0 {
-			for _, repo := range scm.Configuration.Repositories {
-				if repo.ContentSetupType == "ep-commerce" {
-					if repo.EPObjectType == nil {
-						jsonError(w, "ep_commerce not provided when the content_setup_type is set to ep-commerce", http.StatusBadRequest)
-						return
-					}
-					initPack, err := ep.FindInitPackage(repo.EPObjectType.Version)
-					if err != nil {
-						jsonError(w, err.Error(), http.StatusBadRequest)
-						return
-					}
-					if repo.EPObjectType.ExtensionVersion == "" {
-						repo.EPObjectType.ExtensionVersion = ep.DefaultExtensionVersion
-					}
-					repo.EPObjectType.PlatformVersion = initPack.PlatformVersion
-
-					// pre-signed url for the ep init package
-					url, err := initPack.PreSignedURL()
-					if err != nil {
-						jsonError(w, err.Error(), http.StatusBadRequest)
-						return
-					}
-					repo.EPObjectType.SourceCodeURL = url
-				}
-				if repo.ContentSetupType == "bloomreach-archetype" {
-					if repo.BRObjectType == nil {
-						jsonError(w, "bloomreach_archetype not provided when the content_setup_type is set to bloomreach-archetype", http.StatusBadRequest)
-						return
-					}
-					b := repo.BRObjectType
-					if b.ArchetypeVersion == "" || b.ArtifactID == "" || b.GroupID == "" || b.Package == "" || b.ProjectName == "" || b.Version == "" {
-						jsonError(w, "required fields for bloomreach_archetype: archetype_version, group_id, artifact_id, version,package, project_name", http.StatusBadRequest)
-						return
-					}
-				}
-			}
-		}
 	}
 
 	k8scli := getK8Client(r)
-	err = createSCM(k8scli, ns, &scm)
+	result, err := createSCM(k8scli, ns, &scm, nil)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(applyStatus(result))
 	encode(w, scm)
 }
 
-// createSCM create a new SCM server and populates scm pointer with more data
-// also creates k8s resources that are part of the SCM server
-func createSCM(kubeCli kubernetes.Interface, ns string, scm *bedrock.SCM) error {
-	k8Service, err := k8s.CreateService(kubeCli, ns, gogs.Service(ns))
+// prepareSCMConfig populates the ep-commerce repositories of a validated configuration
+// with the init package data
+func prepareSCMConfig(presigner awscli.Presigner, config *bedrock.SCMConfig) error {
+	for i, repo := range config.Repositories {
+		field := fmt.Sprintf("configuration.repositories[%d].ep_commerce", i)
+		if repo.ContentSetupType == bedrock.ContentSetupEPCommerce {
+			err := prepareEPCommerce(presigner, field, repo.EPObjectType)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// prepareEPCommerce populates the ep-commerce config with the init package data,
+// field is the path of the config in the errors
+func prepareEPCommerce(presigner awscli.Presigner, field string, config *bedrock.EPObjectType) error {
+	initPack, err := ep.FindInitPackage(config.Version)
 	if err != nil {
-		return err
+		return fieldError(field+".version", err.Error())
 	}
-	k8Ingress, err := k8s.CreateIngress(kubeCli, ns, gogs.Ingress(ns))
+	if config.ExtensionVersion == "" {
+		config.ExtensionVersion = ep.DefaultExtensionVersion
+	}
+	config.PlatformVersion = initPack.PlatformVersion
+
+	// pre-signed url for the ep init package
+	url, err := initPack.PreSignedURL(presigner)
 	if err != nil {
-		return err
+		return upstreamError("aws", err)
 	}
-	k8Statefulset, err := k8s.CreateStatefulSet(kubeCli, ns, gogs.StatefulSet(scm.Image, ns))
+	config.SourceCodeURL = url
+	return nil
+}
+
+// setSCMInitDefaults sets the default values of the init config based on the ingress host
+func setSCMInitDefaults(config *bedrock.SCMConfig, host string) {
+	config.InitData.Domain = host
+	config.InitData.APPURL = "https://" + host
+	config.InitData.HTTPPort = "3000"
+	config.InitData.RepoRootPath = "/data/git/gogs-repositories"
+	config.InitData.LogRootPath = "/app/gogs/log"
+}
+
+// createSCM creates or updates a SCM server and populates scm pointer with more data
+// also applies the k8s resources that are part of the SCM server, the created resources are recorded in tracker
+// the returned result is k8s.Created when at least one resource was created
+func createSCM(kubeCli kubernetes.Interface, ns string, scm *bedrock.SCM, tracker *deployTracker) (_ k8s.ApplyResult, err error) {
+	defer func() { metrics.ObserveProvisioning("scm", gogs.Vendor().Name, err) }()
+	applied := k8s.Unchanged
+	k8Service, result, err := k8s.ApplyService(kubeCli, ns, gogs.Service(ns))
 	if err != nil {
-		return err
+		return applied, err
 	}
+	trackService(tracker, result, kubeCli, ns, k8Service.Name)
+	applied = applied.Merge(result)
+	k8Ingress, result, err := k8s.ApplyIngress(kubeCli, ns, gogs.Ingress(ns))
+	if err != nil {
+		return applied, err
+	}
+	trackIngress(tracker, result, kubeCli, ns, k8Ingress.Name)
+	applied = applied.Merge(result)
+	k8Statefulset, result, err := k8s.ApplyStatefulSet(kubeCli, ns, gogs.StatefulSet(scm.Image, ns))
+	if err != nil {
+		return applied, err
+	}
+	trackStatefulSet(tracker, result, kubeCli, ns, k8Statefulset.Name)
+	applied = applied.Merge(result)
 
 	// populating SCM
 	scm.ServerName = k8Statefulset.Name
@@ -140,61 +152,55 @@ func createSCM(kubeCli kubernetes.Interface, ns string, scm *bedrock.SCM) error
 	// the request requires custom configuration
 	if scm.CustomConfig {
 		//default values for the init config
-		scm.Configuration.InitData.Domain = ingress
-		scm.Configuration.InitData.APPURL = scm.Host
-		scm.Configuration.InitData.HTTPPort = "3000"
-		scm.Configuration.InitData.RepoRootPath = "/data/git/gogs-repositories"
-		scm.Configuration.InitData.LogRootPath = "/app/gogs/log"
+		setSCMInitDefaults(scm.Configuration, ingress)
 
 		secretData, err := json.Marshal(scm.Configuration)
 		if err != nil {
-			return err
-		}
-		_, err = k8s.CreateSecret(kubeCli, ns, gogs.Secret(ns, secretData))
-		if err != nil {
-			return err
+			return applied, err
 		}
-		_, err = k8s.CreateJob(kubeCli, ns, gogs.InitJob(scm.Host, ns))
+		result, err := applyInitJob(kubeCli, ns, gogs.Secret(ns, secretData), gogs.InitJob(scm.Host, ns), tracker)
 		if err != nil {
-			return err
+			return applied, err
 		}
+		applied = applied.Merge(result)
 	}
-	return nil
+	return applied, nil
 }
 
 func getSCM(w http.ResponseWriter, r *http.Request) {
 	ns := chi.URLParam(r, "clientId")
 	err := checkClient(r, ns)
 	if err != nil {
-		jsonError(w, "invalid clientId", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	scm := bedrock.SCM{
 		SCMID: chi.URLParam(r, "scmId"),
 	}
 	if !validVendor(scm.SCMID, scmVendors()) {
-		jsonError(w, "unknown scmId", http.StatusBadRequest)
+		writeError(w, notFoundError("unknown scmId %v", scm.SCMID))
 		return
 	}
 	k8sclient := getK8Client(r)
 	k8StatfulSet, err := k8s.GetStatefulSet(k8sclient, ns, gogs.ServerName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	k8Service, err := k8s.GetService(k8sclient, ns, gogs.ServiceName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	k8Ingress, err := k8s.GetIngress(k8sclient, ns, gogs.IngressName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	scm.ServerName = k8StatfulSet.Name
 	scm.ServiceName = k8Service.Name
 	scm.IngressName = k8Ingress.Name
+	scm.Image = containerImage(k8StatfulSet, gogs.ContainerName)
 
 	if len(k8Ingress.Spec.Rules) > 0 {
 		scm.Host = "https://" + k8Ingress.Spec.Rules[0].Host
@@ -203,8 +209,97 @@ func getSCM(w http.ResponseWriter, r *http.Request) {
 	encode(w, &scm)
 
 }
+
+// updateSCM updates the image or the custom configuration of an existing SCM server
 func updateSCM(w http.ResponseWriter, r *http.Request) {
-	jsonError(w, "operation not available", http.StatusInternalServerError)
+	ns := chi.URLParam(r, "clientId")
+	err := checkActiveClient(r, ns)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	scm := bedrock.SCM{
+		SCMID: chi.URLParam(r, "scmId"),
+	}
+	if !validVendor(scm.SCMID, scmVendors()) {
+		writeError(w, notFoundError("unknown scmId %v", scm.SCMID))
+		return
+	}
+	patch := bedrock.SCMPatch{}
+	err = decode(r, &patch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if patch.Image != nil && !validImage(*patch.Image, "", gogs.Vendor()) {
+		writeError(w, fieldError("image", "image is not available for "+scm.SCMID))
+		return
+	}
+	err = bedrock.Validate(patch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if patch.CustomConfig != nil && *patch.CustomConfig {
+		err = prepareSCMConfig(getPresigner(r), patch.Configuration)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	k8sclient := getK8Client(r)
+	err = patchSCM(k8sclient, ns, &scm, patch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	encode(w, scm)
+}
+
+// patchSCM applies the patch to the k8s resources of an existing SCM server
+// and populates the scm pointer with the updated data
+func patchSCM(kubeCli kubernetes.Interface, ns string, scm *bedrock.SCM, patch bedrock.SCMPatch) error {
+	k8Statefulset, err := k8s.GetStatefulSet(kubeCli, ns, gogs.ServerName)
+	if err != nil {
+		return err
+	}
+	if patch.Image != nil && updateContainerImages(k8Statefulset, gogs.StatefulSet(*patch.Image, ns)) {
+		k8Statefulset, err = k8s.UpdateStatefulSet(kubeCli, ns, k8Statefulset)
+		if err != nil {
+			return err
+		}
+	}
+	k8Ingress, _, err := k8s.ApplyIngress(kubeCli, ns, gogs.Ingress(ns))
+	if err != nil {
+		return err
+	}
+
+	scm.ServerName = k8Statefulset.Name
+	scm.IngressName = k8Ingress.Name
+	scm.ServiceName = gogs.ServiceName
+	scm.Image = containerImage(k8Statefulset, gogs.ContainerName)
+	ingress := ""
+	if len(k8Ingress.Spec.Rules) > 0 {
+		ingress = k8Ingress.Spec.Rules[0].Host
+	}
+	scm.Host = "https://" + ingress
+
+	if patch.CustomConfig == nil {
+		return nil
+	}
+	scm.CustomConfig = *patch.CustomConfig
+	if !scm.CustomConfig {
+		return deleteInitJob(kubeCli, ns, gogs.InitJobName, gogs.InitSecretName)
+	}
+	scm.Configuration = patch.Configuration
+	setSCMInitDefaults(scm.Configuration, ingress)
+	secretData, err := json.Marshal(scm.Configuration)
+	if err != nil {
+		return err
+	}
+	_, err = applyInitJob(kubeCli, ns, gogs.Secret(ns, secretData), gogs.InitJob(scm.Host, ns), nil)
+	return err
 }
 func deleteSCM(w http.ResponseWriter, r *http.Request) {
 	scm := bedrock.SCM{}
@@ -212,24 +307,24 @@ func deleteSCM(w http.ResponseWriter, r *http.Request) {
 	scm.SCMID = chi.URLParam(r, "scmId")
 	err := checkClient(r, ns)
 	if err != nil {
-		jsonError(w, "invalid clientId", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	k8sclient := getK8Client(r)
 
 	err = k8s.DeleteStatefulSet(k8sclient, ns, gogs.ServerName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	err = k8s.DeleteService(k8sclient, ns, gogs.ServiceName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	err = k8s.DeleteIngress(k8sclient, ns, gogs.IngressName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	// delete if exist, ignoring errors
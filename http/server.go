@@ -1,64 +1,227 @@
 package http
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
+	"github.com/xumak-grid/bedrock/audit"
+	"github.com/xumak-grid/bedrock/auth"
+	"github.com/xumak-grid/bedrock/metrics"
+	"github.com/xumak-grid/bedrock/openapi"
 )
 
 // DefaultAddr is the default bind address.
 const DefaultAddr = ":8000"
 
+const (
+	// DefaultReadTimeout is the default maximum duration for reading a request.
+	DefaultReadTimeout = 15 * time.Second
+	// DefaultWriteTimeout is the default maximum duration before timing out writes of a response.
+	DefaultWriteTimeout = 2 * time.Minute
+	// DefaultIdleTimeout is the default maximum time to wait for the next request with keep-alives.
+	DefaultIdleTimeout = 2 * time.Minute
+	// DefaultShutdownDelay is the default time between the readiness switching off and the listener closing.
+	DefaultShutdownDelay = 5 * time.Second
+)
+
 // Server represents an HTTP server.
 type Server struct {
 	ln   net.Listener
+	srv  *http.Server
 	Addr string
 	log  *logrus.Logger
+
+	// Auth authenticates every request of the api, it is required
+	Auth auth.Authenticator
+	// Audit records the requests that change resources, it keeps the records in memory by default
+	Audit *audit.Log
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// ShutdownDelay gives time to the load balancers to notice that the server is not ready
+	ShutdownDelay time.Duration
+	// ValidateRequests rejects the request bodies that do not match the OpenAPI spec of the api
+	ValidateRequests bool
+
+	mu        sync.RWMutex
+	deps      *Dependencies
+	ready     int32
+	checks    []dependencyCheck
+	health    healthCache
+	spec      *openapi.Document
+	closing   chan struct{}
+	closeOnce sync.Once
 }
 
-// NewServer returns a new instance of Server.
-func NewServer(log *logrus.Logger) *Server {
+// NewServer returns a new instance of Server that uses the clients in deps.
+func NewServer(log *logrus.Logger, deps *Dependencies) *Server {
 	if log == nil {
 		log = logrus.New()
 	}
 
 	return &Server{
-		Addr: DefaultAddr,
-		log:  log,
+		Addr:          DefaultAddr,
+		log:           log,
+		Audit:         audit.New(log),
+		ReadTimeout:   DefaultReadTimeout,
+		WriteTimeout:  DefaultWriteTimeout,
+		IdleTimeout:   DefaultIdleTimeout,
+		ShutdownDelay: DefaultShutdownDelay,
+		deps:          deps,
+		checks:        defaultChecks(),
+		closing:       make(chan struct{}),
 	}
 }
 
-// Open opens a socket and serves.
+// Dependencies returns the clients currently used by the server.
+func (s *Server) Dependencies() *Dependencies {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.deps
+}
+
+// SetDependencies replaces the clients used by the new requests.
+func (s *Server) SetDependencies(deps *Dependencies) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deps = deps
+}
+
+// Ready returns true while the server accepts new requests.
+func (s *Server) Ready() bool {
+	return atomic.LoadInt32(&s.ready) == 1
+}
+
+// Handler returns the handler of the probes, the metrics and the api mounted in /api/v1.
+func (s *Server) Handler() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(Instrument())
+	r.Handle("/metrics", metrics.Handler())
+	r.Get("/healthz", s.healthzHandler)
+	r.Get("/readyz", s.readyHandler)
+	r.Group(func(r chi.Router) {
+		r.Use(WithDependencies(s.Dependencies))
+		r.Mount("/api/v1", s.getAPIRouter())
+	})
+	return r
+}
+
+// Open opens a socket and serves, it returns nil after Shutdown or Close.
 func (s *Server) Open() error {
+	if s.Auth == nil {
+		return errors.New("an authenticator is required")
+	}
+	if s.Dependencies() == nil {
+		return errors.New("the kubernetes clients are required")
+	}
+	if s.Dependencies().Secrets == nil {
+		return errors.New("a secret service is required")
+	}
+	if s.ValidateRequests {
+		spec, err := Spec()
+		if err != nil {
+			return err
+		}
+		s.spec = spec
+	}
+	r := s.Handler()
+
 	s.log.WithField("Bind address", s.Addr).Info("Binding address")
 	ln, err := net.Listen("tcp", s.Addr)
-	s.log.WithField("Bind address", s.Addr).Info("Listening at")
 	if err != nil {
 		return err
 	}
+	s.log.WithField("Bind address", s.Addr).Info("Listening at")
+	srv := &http.Server{
+		Handler:      r,
+		ReadTimeout:  s.ReadTimeout,
+		WriteTimeout: s.WriteTimeout,
+		IdleTimeout:  s.IdleTimeout,
+	}
+	s.mu.Lock()
+	select {
+	case <-s.closing:
+		s.mu.Unlock()
+		ln.Close()
+		return nil
+	default:
+	}
 	s.ln = ln
-	r := chi.NewRouter()
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(WithKubeClient())
-	r.Mount("/api/v1", s.getAPIRouter())
-	err = http.Serve(s.ln, r)
+	s.srv = srv
+	s.mu.Unlock()
+	atomic.StoreInt32(&s.ready, 1)
+
+	err = srv.Serve(ln)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops the server gracefully: the readiness switches off, after the
+// ShutdownDelay the listener is closed and the in-flight requests and background
+// operations are drained until ctx is done, then the operations still running are
+// interrupted and rolled back.
+func (s *Server) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.ready, 0)
+	s.log.Info("Shutting down, readiness switched off")
+	select {
+	case <-time.After(s.ShutdownDelay):
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	s.closeOnce.Do(func() { close(s.closing) })
+	srv := s.srv
+	s.mu.Unlock()
+	var drainErr error
+	if srv != nil {
+		drainErr = srv.Shutdown(ctx)
+		if drainErr != nil {
+			s.log.WithError(drainErr).Error("Error draining connections")
+		}
+	}
+	// the operations are interrupted and rolled back even when the connections were not drained
+	s.log.Info("Waiting for background operations")
+	err := operations.wait(ctx)
 	if err != nil {
+		s.log.WithError(err).Error("Background operations did not finish, the running ones were interrupted")
+	}
+	switch {
+	case drainErr != nil && err != nil:
+		return fmt.Errorf("error draining connections: %v; background operations: %v", drainErr, err)
+	case drainErr != nil:
+		return drainErr
+	case err != nil:
 		return err
 	}
+	s.log.Info("Server closed")
 	return nil
 }
 
-// Close closes all services and database.
+// Close closes the listener and all the connections immediately.
 func (s *Server) Close() error {
-	// Close listener.
-	if s.ln != nil {
-		s.ln.Close()
+	atomic.StoreInt32(&s.ready, 0)
+	s.mu.Lock()
+	s.closeOnce.Do(func() { close(s.closing) })
+	srv := s.srv
+	s.mu.Unlock()
+	if srv != nil {
+		srv.Close()
 	}
 	s.log.Info("Server closed")
 	return nil
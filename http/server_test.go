@@ -0,0 +1,192 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/auth"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// openTestServer opens s in a random port and returns its address when it is ready
+func openTestServer(t *testing.T, s *Server) string {
+	s.Addr = "127.0.0.1:0"
+	go s.Open()
+	for i := 0; i < 100 && !s.Ready(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !s.Ready() {
+		t.Fatal("the server is not ready")
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ln.Addr().String()
+}
+
+func TestShutdownRollsBackOperations(t *testing.T) {
+	defer func(store *operationStore) { operations = store }(operations)
+	operations = newOperationStore()
+	a := newTestAPI(t)
+	defer a.close()
+
+	// the full deploy blocks creating the artifactory, after the AEM deployments
+	blocked, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	a.deps.KubeClient.(*fake.Clientset).PrependReactor("create", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		once.Do(func() {
+			close(blocked)
+			<-release
+		})
+		return false, nil, nil
+	})
+	op := bedrock.Operation{}
+	a.run([]handlerTest{
+		{name: "full deploy", method: "POST", path: "/clients", body: fullDeployClient("acme", false), status: http.StatusAccepted, check: func(t *testing.T, body []byte) {
+			decodeBody(t, body, &op)
+		}},
+	})
+	<-blocked
+
+	s := NewServer(nil, a.deps)
+	s.Auth = auth.Anonymous()
+	s.ShutdownDelay = 0
+	addr := openTestServer(t, s)
+	// an unfinished request keeps the connection active, the drain fails with the expired context
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /healthz HTTP/1.1\r\n"))
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errc := make(chan error, 1)
+	go func() { errc <- s.Shutdown(ctx) }()
+	for i := 0; i < 500 && !operations.isInterrupted(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+	err = <-errc
+	if err == nil {
+		t.Error("expected the errors of the expired shutdown")
+	}
+
+	finished, _ := operations.get(op.OperationID)
+	if finished.Status != bedrock.OperationFailed || !finished.RolledBack {
+		t.Errorf("expected the operation failed and rolled back, got %+v", finished)
+	}
+	deployments, err := a.deps.AEMClient.AemV1beta1().AEMDeployments("acme").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deployments.Items) != 0 {
+		t.Errorf("expected the AEM deployments rolled back, got %v", len(deployments.Items))
+	}
+	sets, err := a.deps.KubeClient.AppsV1beta2().StatefulSets("acme").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets.Items) != 0 {
+		t.Errorf("expected the artifactory rolled back, got %v", len(sets.Items))
+	}
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	// the list of clients blocks until release is closed
+	blocked, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	a.deps.KubeClient.(*fake.Clientset).PrependReactor("list", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		once.Do(func() {
+			close(blocked)
+			<-release
+		})
+		return false, nil, nil
+	})
+
+	s := NewServer(nil, a.deps)
+	s.Auth = auth.Anonymous()
+	s.ShutdownDelay = 100 * time.Millisecond
+	addr := openTestServer(t, s)
+	status := make(chan int, 1)
+	go func() {
+		res, err := http.Get("http://" + addr + "/api/v1/clients")
+		if err != nil {
+			status <- 0
+			return
+		}
+		res.Body.Close()
+		status <- res.StatusCode
+	}()
+	<-blocked
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() { errc <- s.Shutdown(ctx) }()
+	time.Sleep(20 * time.Millisecond)
+	if s.Ready() {
+		t.Error("expected the readiness switched off before the listener is closed")
+	}
+	// the listener accepts requests during the ShutdownDelay, the probes see the server not ready
+	res, err := http.Get("http://" + addr + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected the readiness probe failing, got %v", res.StatusCode)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	close(release)
+	if code := <-status; code != http.StatusOK {
+		t.Errorf("expected the in-flight request completed, got %v", code)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("expected a graceful shutdown, got %v", err)
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("expected the listener closed")
+	}
+}
+
+func TestServerTimeouts(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	s := NewServer(nil, a.deps)
+	s.Auth = auth.Anonymous()
+	s.ReadTimeout, s.WriteTimeout, s.IdleTimeout = time.Second, 2*time.Second, 3*time.Second
+	openTestServer(t, s)
+	defer s.Close()
+
+	s.mu.RLock()
+	srv := s.srv
+	s.mu.RUnlock()
+	if srv.ReadTimeout != time.Second || srv.WriteTimeout != 2*time.Second || srv.IdleTimeout != 3*time.Second {
+		t.Errorf("expected the configured timeouts, got read %v, write %v, idle %v", srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+}
+
+func TestOpenAfterClose(t *testing.T) {
+	a := newTestAPI(t)
+	defer a.close()
+	s := NewServer(nil, a.deps)
+	s.Auth = auth.Anonymous()
+	s.Addr = "127.0.0.1:0"
+	s.Close()
+	if err := s.Open(); err != nil || s.Ready() {
+		t.Errorf("expected a closed server to not serve, got %v", err)
+	}
+}
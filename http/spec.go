@@ -0,0 +1,398 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/openapi"
+)
+
+// endpoint documents a route of the api in the OpenAPI spec
+type endpoint struct {
+	summary string
+	tag     string
+	// body is a value of the type of the request body, nil when the route has no body
+	body interface{}
+	// responses maps the status codes to the successful responses of the route
+	responses map[int]response
+}
+
+// response documents a successful response, body is a value of the type of the response body
+type response struct {
+	description string
+	body        interface{}
+}
+
+const (
+	tagClients     = "Clients"
+	tagEnvironment = "Environments"
+	tagAEM         = "AEM Deployment"
+	tagDispatcher  = "Dispatcher"
+	tagArtifactory = "Artifactory Manager"
+	tagSCM         = "Source Control Manager"
+	tagCI          = "Continuous Integration Manager"
+	tagToolbelt    = "Toolbelts"
+	tagOperations  = "Operations"
+	tagAudit       = "Audit"
+)
+
+// parameters describes the path parameters of the routes
+var parameters = map[string]string{
+	"clientId":      "id of the client, it is also the namespace of its resources",
+	"environmentId": "id of the environment, it is also the name of the AEM deployment",
+	"artifactoryId": "vendor of the artifactory, one of /vendors/artifactory/list",
+	"scmId":         "vendor of the source control manager, one of /vendors/scm/list",
+	"ciId":          "vendor of the continuous integration manager, one of /vendors/ci/list",
+	"operationId":   "id of the operation returned when it was started",
+	"instanceId":    "name of the pod of the AEM instance, one of the instances of the environment",
+}
+
+// applied documents the responses of the routes that create or update resources to the desired state
+func applied(v interface{}) map[int]response {
+	return map[int]response{
+		http.StatusOK:      {"the resource already existed, updated to the desired state", v},
+		http.StatusCreated: {"the resource was created", v},
+	}
+}
+
+// ok documents the routes that only respond 200
+func ok(description string, v interface{}) map[int]response {
+	return map[int]response{http.StatusOK: {description, v}}
+}
+
+// endpoints documents the routes registered in apiRoutes, the key is the method and the route pattern
+var endpoints = map[string]endpoint{
+	"GET /clients": {
+		summary:   "List the clients, filtered with ?selector= (a label selector of the metadata), ?status=, ?createdAfter= and ?createdBefore= (RFC3339), sorted with ?sort= (clientId, createdAt or status, - prefix for descending) and paginated with ?limit= and ?offset=",
+		tag:       tagClients,
+		responses: ok("the clients accessible by the caller, the X-Total-Count header is the number of clients of all the pages", []bedrock.Client{}),
+	},
+	"POST /clients": {
+		summary: "Create a client",
+		tag:     tagClients,
+		body:    bedrock.Client{},
+		responses: map[int]response{
+			http.StatusOK:       {"client already existed, updated to the desired state, or the full deploy of a dryRun", openapi.OneOf{bedrock.Client{}, bedrock.FullDeploy{}}},
+			http.StatusCreated:  {"client created without customConfig", bedrock.Client{}},
+			http.StatusAccepted: {"client created, the full deploy runs in background and is tracked by the operation", bedrock.Operation{}},
+		},
+	},
+	"GET /clients/{clientId}": {
+		summary:   "Info for an specific client",
+		tag:       tagClients,
+		responses: ok("the client", bedrock.Client{}),
+	},
+	"PATCH /clients/{clientId}": {
+		summary:   "Update the metadata of a client, a null value removes the key",
+		tag:       tagClients,
+		body:      bedrock.ClientPatch{},
+		responses: ok("the updated client", bedrock.Client{}),
+	},
+	"GET /clients/{clientId}/summary": {
+		summary:   "Summary of a client with the AEM deployments, the stack servers, the certificate and the toolbelt",
+		tag:       tagClients,
+		responses: ok("the summary, the resources that could not be read are in the errors", bedrock.ClientSummary{}),
+	},
+	"DELETE /clients/{clientId}": {
+		summary: "Schedule the deletion of a client after ?gracePeriod= (24h by default), with ?export=true the scm repositories and the artifactory blobs are exported to S3 before (a suspended client must be resumed first), the protected clients are not deleted",
+		tag:     tagClients,
+		responses: map[int]response{
+			http.StatusAccepted: {"the deletion, the secrets and the namespace are deleted when it is ready", bedrock.ClientDeletion{}},
+		},
+	},
+	"GET /clients/{clientId}/deletion": {
+		summary:   "Status of the deletion of a client",
+		tag:       tagClients,
+		responses: ok("the deletion and the status of the exports", bedrock.ClientDeletion{}),
+	},
+	"DELETE /clients/{clientId}/deletion": {
+		summary:   "Cancel the deletion of a client, the exports already uploaded are kept",
+		tag:       tagClients,
+		responses: ok("the cancelled deletion", bedrock.ClientDeletion{}),
+	},
+	"POST /clients/{clientId}/protect": {
+		summary:   "Protect a client against the deletion, the new clients are protected",
+		tag:       tagClients,
+		responses: ok("the protected client", bedrock.Client{}),
+	},
+	"POST /clients/{clientId}/unprotect": {
+		summary:   "Remove the protection of a client, it can be deleted after",
+		tag:       tagClients,
+		responses: ok("the client", bedrock.Client{}),
+	},
+	"POST /clients/{clientId}/suspend": {
+		summary:   "Suspend a client, the AEM deployments and the stack servers are scaled to zero and their replicas are recorded in the namespace, the requests that create or scale its resources respond 409 until it is resumed",
+		tag:       tagClients,
+		responses: ok("the suspension with the recorded replicas, suspending again keeps them", bedrock.ClientSuspension{}),
+	},
+	"POST /clients/{clientId}/resume": {
+		summary:   "Resume a suspended client, the recorded replicas are restored",
+		tag:       tagClients,
+		responses: ok("the suspension that was resumed", bedrock.ClientSuspension{}),
+	},
+	"GET /clients/{clientId}/audit": {
+		summary:   "Audit log of the requests that changed the resources of the client, filtered with ?environmentId=, ?since= (RFC3339) and ?limit= (100 by default)",
+		tag:       tagAudit,
+		responses: ok("the audit records in chronological order, the secrets of the bodies are redacted", []bedrock.AuditRecord{}),
+	},
+	"GET /clients/{clientId}/environments": {
+		summary:   "List of environments for the client",
+		tag:       tagEnvironment,
+		responses: ok("the environments of the client", []bedrock.Environment{}),
+	},
+	"GET /clients/{clientId}/environments/{environmentId}/aem": {
+		summary:   "Info of the AEM deployment",
+		tag:       tagAEM,
+		responses: ok("the AEM deployment", bedrock.AEMDeployment{}),
+	},
+	"POST /clients/{clientId}/environments/{environmentId}/aem": {
+		summary:   "Create the AEM deployment",
+		tag:       tagAEM,
+		body:      bedrock.AEMDeployment{},
+		responses: applied(bedrock.AEMDeployment{}),
+	},
+	"PATCH /clients/{clientId}/environments/{environmentId}/aem": {
+		summary:   "Update the AEM deployment",
+		tag:       tagAEM,
+		body:      bedrock.AEMDeployment{},
+		responses: ok("the updated AEM deployment", bedrock.AEMDeployment{}),
+	},
+	"DELETE /clients/{clientId}/environments/{environmentId}/aem": {
+		summary:   "Delete an specific AEM deployment",
+		tag:       tagAEM,
+		responses: ok("the deleted AEM deployment", bedrock.AEMDeployment{}),
+	},
+	"GET /clients/{clientId}/environments/{environmentId}/aem/instances": {
+		summary:   "List of instances in an AEM deployment",
+		tag:       tagAEM,
+		responses: ok("the instances of the AEM deployment", []bedrock.Instance{}),
+	},
+	"GET /clients/{clientId}/environments/{environmentId}/aem/instances/{instanceId}/password": {
+		summary:   "Reveal the admin password of an AEM instance, requires the secrets-admin role, with ?wrapTTL=5m returns a vault wrapping token",
+		tag:       tagAEM,
+		responses: ok("the credentials of the instance, the access is logged", bedrock.InstanceCredentials{}),
+	},
+	"POST /clients/{clientId}/environments/{environmentId}/aem/instances/{instanceId}/password/rotate": {
+		summary:   "Rotate the admin password of an AEM instance",
+		tag:       tagAEM,
+		responses: ok("the rotation, the new password is applied by the aem-operator", bedrock.PasswordRotation{}),
+	},
+	"POST /clients/{clientId}/environments/{environmentId}/aem/passwords/rotate": {
+		summary:   "Rotate the admin password of all the AEM instances of the environment",
+		tag:       tagAEM,
+		responses: ok("the rotation, the new passwords are applied by the aem-operator", bedrock.PasswordRotation{}),
+	},
+	"GET /clients/{clientId}/environments/{environmentId}/aem/dispatcherconfig": {
+		summary:   "Get the dispatcher configuration of the environment",
+		tag:       tagDispatcher,
+		responses: ok("the configMap of the dispatcher", bedrock.ConfigMap{}),
+	},
+	"PATCH /clients/{clientId}/environments/{environmentId}/aem/dispatcherconfig": {
+		summary:   "Replace the data of a dispatcher configMap of the environment",
+		tag:       tagDispatcher,
+		body:      bedrock.ConfigMap{},
+		responses: ok("the updated configMap", bedrock.ConfigMap{}),
+	},
+	"GET /clients/{clientId}/tools/toolbelt": {
+		summary:   "Get info toolbelt box",
+		tag:       tagToolbelt,
+		responses: ok("the toolbelt", bedrock.Toolbelt{}),
+	},
+	"POST /clients/{clientId}/tools/toolbelt": {
+		summary:   "Create toolbelt box",
+		tag:       tagToolbelt,
+		responses: applied(bedrock.Toolbelt{}),
+	},
+	"DELETE /clients/{clientId}/tools/toolbelt": {
+		summary:   "Delete toolbelt box",
+		tag:       tagToolbelt,
+		responses: ok("the deleted toolbelt", bedrock.Toolbelt{}),
+	},
+	"POST /clients/{clientId}/artifactory": {
+		summary:   "Create an artifact manager",
+		tag:       tagArtifactory,
+		body:      bedrock.Artifactory{},
+		responses: applied(bedrock.Artifactory{}),
+	},
+	"GET /clients/{clientId}/artifactory/{artifactoryId}": {
+		summary:   "Get the artifact manager",
+		tag:       tagArtifactory,
+		responses: ok("the artifact manager", bedrock.Artifactory{}),
+	},
+	"PATCH /clients/{clientId}/artifactory/{artifactoryId}": {
+		summary:   "Update the artifact manager",
+		tag:       tagArtifactory,
+		body:      bedrock.ArtifactoryPatch{},
+		responses: ok("the updated artifact manager", bedrock.Artifactory{}),
+	},
+	"DELETE /clients/{clientId}/artifactory/{artifactoryId}": {
+		summary:   "Delete the artifact manager",
+		tag:       tagArtifactory,
+		responses: ok("the deleted artifact manager", bedrock.Artifactory{}),
+	},
+	"POST /clients/{clientId}/scm": {
+		summary:   "Create a source control manager",
+		tag:       tagSCM,
+		body:      bedrock.SCM{},
+		responses: applied(bedrock.SCM{}),
+	},
+	"GET /clients/{clientId}/scm/{scmId}": {
+		summary:   "Get the source control manager",
+		tag:       tagSCM,
+		responses: ok("the source control manager", bedrock.SCM{}),
+	},
+	"PATCH /clients/{clientId}/scm/{scmId}": {
+		summary:   "Update the source control manager",
+		tag:       tagSCM,
+		body:      bedrock.SCMPatch{},
+		responses: ok("the updated source control manager", bedrock.SCM{}),
+	},
+	"DELETE /clients/{clientId}/scm/{scmId}": {
+		summary:   "Delete the source control manager",
+		tag:       tagSCM,
+		responses: ok("the deleted source control manager", bedrock.SCM{}),
+	},
+	"POST /clients/{clientId}/ci": {
+		summary:   "Create a continuous integration manager",
+		tag:       tagCI,
+		body:      bedrock.CI{},
+		responses: applied(bedrock.CI{}),
+	},
+	"GET /clients/{clientId}/ci/{ciId}": {
+		summary:   "Get the continuous integration manager",
+		tag:       tagCI,
+		responses: ok("the continuous integration manager", bedrock.CI{}),
+	},
+	"PATCH /clients/{clientId}/ci/{ciId}": {
+		summary:   "Update the continuous integration manager",
+		tag:       tagCI,
+		body:      bedrock.CIPatch{},
+		responses: ok("the updated continuous integration manager", bedrock.CI{}),
+	},
+	"DELETE /clients/{clientId}/ci/{ciId}": {
+		summary:   "Delete the continuous integration manager",
+		tag:       tagCI,
+		responses: ok("the deleted continuous integration manager", bedrock.CI{}),
+	},
+	"GET /operations/{operationId}": {
+		summary:   "Status of an operation",
+		tag:       tagOperations,
+		responses: ok("the operation", bedrock.Operation{}),
+	},
+	"GET /vendors/artifactory/list": {
+		summary:   "Get all artifactory vendors available in the system",
+		tag:       tagArtifactory,
+		responses: ok("the artifactory vendors", []bedrock.Vendor{}),
+	},
+	"GET /vendors/scm/list": {
+		summary:   "Get all source control managers vendors available in the system",
+		tag:       tagSCM,
+		responses: ok("the source control manager vendors", []bedrock.Vendor{}),
+	},
+	"GET /vendors/ci/list": {
+		summary:   "Get all CI vendors available in the system",
+		tag:       tagCI,
+		responses: ok("the continuous integration manager vendors", []bedrock.Vendor{}),
+	},
+	"GET /images/aem/list": {
+		summary:   "Get all aem images available in the system",
+		tag:       tagAEM,
+		responses: ok("the aem images", []bedrock.Image{}),
+	},
+	"GET /images/dispatcher/list": {
+		summary:   "Get all dispatcher images available in the system",
+		tag:       tagAEM,
+		responses: ok("the dispatcher images", []bedrock.Image{}),
+	},
+	"GET /instances/type/list": {
+		summary:   "Get all instances types available in the system",
+		tag:       tagAEM,
+		responses: ok("the instance types", []bedrock.InstanceType{}),
+	},
+}
+
+// Spec returns the OpenAPI spec of the api generated from the routes and the bedrock types
+func Spec() (*openapi.Document, error) {
+	r := chi.NewRouter()
+	apiRoutes(r)
+	return routesSpec(r)
+}
+
+// routesSpec documents the routes of r with endpoints, it fails when a route is not
+// documented or when an endpoint is not a route
+func routesSpec(r chi.Routes) (*openapi.Document, error) {
+	routes := []string{}
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		// the patterns of mounted routers keep their wildcards, /clients/*/{clientId}/*
+		route = strings.TrimSuffix(strings.Replace(route, "/*/", "/", -1), "/*")
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		routes = append(routes, method+" "+route)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newSpec(routes)
+}
+
+// newSpec returns the spec of the routes, every route is the method and the pattern, for example GET /clients
+func newSpec(routes []string) (*openapi.Document, error) {
+	spec := openapi.New(openapi.Info{
+		Title:       "Bedrock API",
+		Version:     "v1",
+		Description: "Bedrock api k8s, generated from the routes, run go run ./cmd/openapi > openapi.yaml after changing them",
+	})
+	spec.Security = []map[string][]string{{"bearerAuth": {}}}
+	spec.Components.SecuritySchemes = map[string]openapi.SecurityScheme{
+		"bearerAuth": {
+			Type:        "http",
+			Scheme:      "bearer",
+			Description: "static token or OIDC JWT, unauthenticated requests return 401 and requests outside the grants of the token return 403",
+		},
+	}
+
+	undocumented := []string{}
+	documented := map[string]bool{}
+	for _, route := range routes {
+		e, ok := endpoints[route]
+		if !ok {
+			undocumented = append(undocumented, route)
+			continue
+		}
+		documented[route] = true
+		parts := strings.SplitN(route, " ", 2)
+		method, path := parts[0], parts[1]
+		op := &openapi.Operation{
+			Summary:    e.summary,
+			Tags:       []string{e.tag},
+			Parameters: openapi.PathParameters(path, parameters),
+			Responses: map[string]*openapi.Response{
+				"default": spec.Response("unexpected error", JSONError{}),
+			},
+		}
+		if e.body != nil {
+			op.RequestBody = spec.Body(e.body)
+		}
+		for code, resp := range e.responses {
+			op.Responses[strconv.Itoa(code)] = spec.Response(resp.description, resp.body)
+		}
+		spec.AddOperation(method, path, op)
+	}
+	for route := range endpoints {
+		if !documented[route] {
+			undocumented = append(undocumented, route)
+		}
+	}
+	if len(undocumented) > 0 {
+		sort.Strings(undocumented)
+		return nil, fmt.Errorf("the routes and the endpoints of the spec differ: %v", strings.Join(undocumented, ", "))
+	}
+	return spec, nil
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/go-chi/chi"
+)
+
+func TestSpecRoutes(t *testing.T) {
+	s := NewServer(nil, nil)
+	routes, ok := s.getAPIRouter().(chi.Routes)
+	if !ok {
+		t.Fatal("the api router does not expose its routes")
+	}
+	_, err := routesSpec(routes)
+	if err != nil {
+		t.Fatalf("update endpoints in spec.go: %v", err)
+	}
+}
+
+func TestSpecFile(t *testing.T) {
+	spec, err := Spec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile("../openapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected, actual interface{}
+	if err := json.Unmarshal(generated, &expected); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(current, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatal("openapi.yaml is out of date, run go run ./cmd/openapi > openapi.yaml")
+	}
+}
+
+func TestValidateRequests(t *testing.T) {
+	spec, err := Spec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := chi.NewRouter()
+	api.Use(ValidateRequests(spec))
+	api.Post("/clients", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	})
+	api.Get("/clients", func(w http.ResponseWriter, r *http.Request) {})
+	r := chi.NewRouter()
+	r.Mount("/api/v1", api)
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{"valid", "POST", `{"clientId": "acme"}`, http.StatusOK},
+		{"unknown field", "POST", `{"clientId": "acme", "clientName": "Acme"}`, http.StatusBadRequest},
+		{"invalid value", "POST", `{"clientId": "Acme Inc"}`, http.StatusBadRequest},
+		{"malformed", "POST", `{"clientId"`, http.StatusBadRequest},
+		{"without body", "GET", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, "/api/v1/clients", strings.NewReader(tt.body))
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%v: expected %v, got %v %v", tt.name, tt.status, w.Code, w.Body.String())
+		}
+		if tt.status == http.StatusOK && w.Body.String() != tt.body {
+			t.Errorf("%v: the handler received %q", tt.name, w.Body.String())
+		}
+	}
+}
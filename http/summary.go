@@ -0,0 +1,185 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	certclient "github.com/jetstack/cert-manager/pkg/client/clientset/versioned"
+	aemclientset "github.com/xumak-grid/aem-operator/pkg/generated/clientset/versioned"
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/k8s"
+	"github.com/xumak-grid/bedrock/stack/drone"
+	"github.com/xumak-grid/bedrock/stack/gogs"
+	"github.com/xumak-grid/bedrock/stack/nexus"
+	"k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// summaryStack are the names of the resources of a stack server
+type summaryStack struct {
+	vendor    string
+	server    string
+	container string
+	ingress   string
+	// initJob is empty for the stacks without custom configuration
+	initJob string
+}
+
+var (
+	artifactorySummary = summaryStack{vendor: nexus.Vendor().Name, server: nexus.ServerName, container: nexus.ServerName, ingress: nexus.IngressName, initJob: nexus.InitJobName}
+	scmSummary         = summaryStack{vendor: gogs.Vendor().Name, server: gogs.ServerName, container: gogs.ContainerName, ingress: gogs.IngressName, initJob: gogs.InitJobName}
+	ciSummary          = summaryStack{vendor: drone.Vendor().Name, server: drone.ServerName, container: drone.ServerName, ingress: drone.IngressName}
+)
+
+// getClientSummaryHandler returns the client and the state of all its stacks in one response,
+// the resources that can not be read are reported in the errors of the summary
+func getClientSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "clientId")
+	kubecli := getK8Client(r)
+	ns, err := k8s.GetNamespace(kubecli, clientID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	encode(w, clientSummary(kubecli, getAEMClient(r), getCertManagerClient(r), ns, time.Now()))
+}
+
+// clientSummary reads the AEM deployments, the stack servers, the certificate and the toolbelt of the client
+func clientSummary(kubecli kubernetes.Interface, aemcli aemclientset.Interface, certcli certclient.Interface, ns *v1.Namespace, now time.Time) *bedrock.ClientSummary {
+	summary := &bedrock.ClientSummary{
+		Client:       clientFromNamespace(ns),
+		Environments: []bedrock.EnvironmentSummary{},
+	}
+	addError := func(resource string, err error) {
+		summary.Errors = append(summary.Errors, fmt.Sprintf("%v: %v", resource, err))
+	}
+
+	envs, err := environmentSummaries(kubecli, aemcli, ns.Name)
+	if err != nil {
+		addError("environments", err)
+	}
+	summary.Environments = append(summary.Environments, envs...)
+
+	for _, s := range []struct {
+		stack  summaryStack
+		target **bedrock.StackSummary
+	}{
+		{artifactorySummary, &summary.Artifactory},
+		{scmSummary, &summary.SCM},
+		{ciSummary, &summary.CI},
+	} {
+		*s.target, err = stackSummary(kubecli, ns.Name, s.stack)
+		if err != nil {
+			addError(s.stack.vendor, err)
+		}
+	}
+
+	cert, err := k8s.GetCertificate(certcli, ns.Name)
+	switch {
+	case err == nil:
+		ready, message := k8s.CertificateReady(cert)
+		summary.Certificate = &bedrock.CertificateSummary{Name: cert.Name, Ready: ready, Message: message}
+	case !k8serrors.IsNotFound(err):
+		addError("certificate", err)
+	}
+
+	secret, err := k8s.GetSecret(kubecli, ns.Name, toolbletSecretName)
+	switch {
+	case err == nil:
+		tb := toolbeltFromSecret(ns.Name, secret)
+		summary.Toolbelt = &bedrock.ToolbeltSummary{
+			URL:       tb.URL,
+			ExpiresAt: tb.ExpiresAt,
+			Expired:   tb.ExpiresAt != nil && !now.Before(*tb.ExpiresAt),
+		}
+	case !k8serrors.IsNotFound(err):
+		addError("toolbelt", err)
+	}
+	return summary
+}
+
+// environmentSummaries returns the AEM deployments of the namespace, the ready replicas are
+// the ready pods counted by their runmode label: author, publish or dispatcher
+func environmentSummaries(kubecli kubernetes.Interface, aemcli aemclientset.Interface, ns string) ([]bedrock.EnvironmentSummary, error) {
+	k8sDeps, err := k8s.ListAEMDeployments(aemcli, ns)
+	if err != nil {
+		return nil, err
+	}
+	envs := []bedrock.EnvironmentSummary{}
+	for _, k8sDep := range k8sDeps {
+		env := bedrock.EnvironmentSummary{
+			EnvironmentID:     k8sDep.Name,
+			Status:            string(k8sDep.Status.Phase),
+			Version:           k8sDep.Spec.Version,
+			DispatcherVersion: k8sDep.Spec.DispatcherVersion,
+			Replicas: bedrock.InstanceReplicas{
+				Authors:     k8sDep.Spec.Authors.Replicas,
+				Publishers:  k8sDep.Spec.Publishers.Replicas,
+				Dispatchers: k8sDep.Spec.Dispatchers.Replicas,
+			},
+		}
+		pods, err := k8s.ListAEMDeploymentPods(kubecli, &bedrock.AEMDeployment{ClientID: ns, EnvironmentID: k8sDep.Name})
+		if err != nil {
+			return envs, err
+		}
+		for i := range pods {
+			if !k8s.IsPodReady(&pods[i]) {
+				continue
+			}
+			switch pods[i].Labels["runmode"] {
+			case "author":
+				env.ReadyReplicas.Authors++
+			case "publish":
+				env.ReadyReplicas.Publishers++
+			case "dispatcher":
+				env.ReadyReplicas.Dispatchers++
+			}
+		}
+		envs = append(envs, env)
+	}
+	return envs, nil
+}
+
+// stackSummary returns the state of the server of the stack, nil when it is not provisioned
+func stackSummary(kubecli kubernetes.Interface, ns string, stack summaryStack) (*bedrock.StackSummary, error) {
+	sfs, err := k8s.GetStatefulSet(kubecli, ns, stack.server)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	summary := &bedrock.StackSummary{
+		Vendor:        stack.vendor,
+		Image:         containerImage(sfs, stack.container),
+		ReadyReplicas: sfs.Status.ReadyReplicas,
+	}
+	if sfs.Spec.Replicas != nil {
+		summary.Replicas = *sfs.Spec.Replicas
+	}
+	summary.Ready = summary.Replicas > 0 && summary.ReadyReplicas >= summary.Replicas
+
+	ingress, err := k8s.GetIngress(kubecli, ns, stack.ingress)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return summary, err
+	}
+	if err == nil && len(ingress.Spec.Rules) > 0 {
+		summary.Host = "https://" + ingress.Spec.Rules[0].Host
+	}
+
+	if stack.initJob == "" {
+		return summary, nil
+	}
+	job, err := k8s.GetJob(kubecli, ns, stack.initJob)
+	if k8serrors.IsNotFound(err) {
+		return summary, nil
+	}
+	if err != nil {
+		return summary, err
+	}
+	summary.InitJob = jobStatus(job)
+	return summary, nil
+}
@@ -0,0 +1,208 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	aemclientset "github.com/xumak-grid/aem-operator/pkg/generated/clientset/versioned"
+	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/k8s"
+	"github.com/xumak-grid/bedrock/stack/drone"
+	"github.com/xumak-grid/bedrock/stack/gogs"
+	"github.com/xumak-grid/bedrock/stack/nexus"
+	"k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// stackStatefulSets are the servers of the stacks that are scaled to zero by the suspension
+var stackStatefulSets = []string{nexus.ServerName, gogs.ServerName, drone.ServerName}
+
+// suspendClientHandler scales to zero the AEM deployments and the stack servers of the client
+func suspendClientHandler(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "clientId")
+	err := checkClient(r, clientID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	suspension, err := suspendClient(getK8Client(r), getAEMClient(r), clientID, getPrincipal(r).Subject, time.Now().UTC())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	encode(w, suspension)
+}
+
+// resumeClientHandler restores the replicas that the client had before the suspension
+func resumeClientHandler(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "clientId")
+	err := checkClient(r, clientID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	suspension, err := resumeClient(getK8Client(r), getAEMClient(r), clientID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	encode(w, suspension)
+}
+
+// checkActiveClient returns a not found error when the client does not exist and a conflict error
+// when it is suspended, the requests that create or scale resources must wait until it is resumed
+func checkActiveClient(r *http.Request, clientID string) error {
+	ns, err := k8s.GetNamespace(getK8Client(r), clientID)
+	if k8serrors.IsNotFound(err) {
+		return notFoundError("client %v not found", clientID)
+	}
+	if err != nil {
+		return err
+	}
+	return suspendedError(ns)
+}
+
+// suspendedError returns a conflict error when the namespace is of a suspended client
+func suspendedError(ns *v1.Namespace) error {
+	if ns.Annotations[k8s.SuspensionAnnotation] == "" {
+		return nil
+	}
+	return conflictError("client %v is suspended, resume it first", ns.Name)
+}
+
+// getSuspension returns the suspension stored in the namespace of the client, nil when the client is not suspended
+func getSuspension(kubecli kubernetes.Interface, clientID string) (*bedrock.ClientSuspension, error) {
+	ns, err := k8s.GetNamespace(kubecli, clientID)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := ns.Annotations[k8s.SuspensionAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	suspension := &bedrock.ClientSuspension{}
+	err = json.Unmarshal([]byte(v), suspension)
+	if err != nil {
+		return nil, fmt.Errorf("invalid suspension of the client %v: %v", clientID, err)
+	}
+	if suspension.AEMDeployments == nil {
+		suspension.AEMDeployments = map[string]bedrock.InstanceReplicas{}
+	}
+	if suspension.StatefulSets == nil {
+		suspension.StatefulSets = map[string]int32{}
+	}
+	return suspension, nil
+}
+
+// suspendClient records the replicas of the AEM deployments and the stack servers in the namespace
+// of the client and scales them to zero, suspending a suspended client keeps the recorded replicas
+// and scales again the resources, this way a failed suspension can be retried
+func suspendClient(kubecli kubernetes.Interface, aemcli aemclientset.Interface, clientID, subject string, at time.Time) (bedrock.ClientSuspension, error) {
+	suspension, err := getSuspension(kubecli, clientID)
+	if err != nil {
+		return bedrock.ClientSuspension{}, err
+	}
+	if suspension == nil {
+		suspension = &bedrock.ClientSuspension{
+			ClientID:       clientID,
+			Suspended:      true,
+			SuspendedAt:    &at,
+			SuspendedBy:    subject,
+			AEMDeployments: map[string]bedrock.InstanceReplicas{},
+			StatefulSets:   map[string]int32{},
+		}
+	}
+
+	deployments, err := k8s.ListAEMDeployments(aemcli, clientID)
+	if err != nil {
+		return *suspension, err
+	}
+	for _, d := range deployments {
+		if _, ok := suspension.AEMDeployments[d.Name]; ok {
+			continue
+		}
+		suspension.AEMDeployments[d.Name] = bedrock.InstanceReplicas{
+			Authors:     d.Spec.Authors.Replicas,
+			Publishers:  d.Spec.Publishers.Replicas,
+			Dispatchers: d.Spec.Dispatchers.Replicas,
+		}
+	}
+	for _, name := range stackStatefulSets {
+		if _, ok := suspension.StatefulSets[name]; ok {
+			continue
+		}
+		sfs, err := k8s.GetStatefulSet(kubecli, clientID, name)
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return *suspension, err
+		}
+		replicas := int32(1)
+		if sfs.Spec.Replicas != nil {
+			replicas = *sfs.Spec.Replicas
+		}
+		suspension.StatefulSets[name] = replicas
+	}
+
+	// the replicas are recorded before scaling to resume also a partial suspension
+	value, err := json.Marshal(suspension)
+	if err != nil {
+		return *suspension, err
+	}
+	_, err = k8s.SetNamespaceAnnotation(kubecli, clientID, k8s.SuspensionAnnotation, string(value))
+	if err != nil {
+		return *suspension, err
+	}
+	for env := range suspension.AEMDeployments {
+		err = k8s.ScaleAEMDeployment(aemcli, &bedrock.AEMDeployment{ClientID: clientID, EnvironmentID: env})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return *suspension, err
+		}
+	}
+	for name := range suspension.StatefulSets {
+		_, err = k8s.ScaleStatefulSet(kubecli, clientID, name, 0)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return *suspension, err
+		}
+	}
+	return *suspension, nil
+}
+
+// resumeClient restores the replicas recorded by suspendClient, the resources deleted during the
+// suspension are ignored, the suspension is removed from the namespace when all are restored
+func resumeClient(kubecli kubernetes.Interface, aemcli aemclientset.Interface, clientID string) (bedrock.ClientSuspension, error) {
+	suspension, err := getSuspension(kubecli, clientID)
+	if err != nil {
+		return bedrock.ClientSuspension{}, err
+	}
+	if suspension == nil {
+		return bedrock.ClientSuspension{}, conflictError("client %v is not suspended", clientID)
+	}
+	for env, replicas := range suspension.AEMDeployments {
+		aemDeploy := bedrock.AEMDeployment{ClientID: clientID, EnvironmentID: env}
+		aemDeploy.Spec.Authors.Replicas = replicas.Authors
+		aemDeploy.Spec.Publishers.Replicas = replicas.Publishers
+		aemDeploy.Spec.Dispatchers.Replicas = replicas.Dispatchers
+		err = k8s.ScaleAEMDeployment(aemcli, &aemDeploy)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return *suspension, err
+		}
+	}
+	for name, replicas := range suspension.StatefulSets {
+		_, err = k8s.ScaleStatefulSet(kubecli, clientID, name, replicas)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return *suspension, err
+		}
+	}
+	_, err = k8s.SetNamespaceAnnotation(kubecli, clientID, k8s.SuspensionAnnotation, "")
+	if err != nil {
+		return *suspension, err
+	}
+	suspension.Suspended = false
+	return *suspension, nil
+}
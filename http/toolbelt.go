@@ -3,6 +3,8 @@ package http
 import (
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi"
@@ -24,7 +26,7 @@ func createToolbeltHandler(w http.ResponseWriter, r *http.Request) {
 	ns := chi.URLParam(r, "clientId")
 	err := checkClient(r, ns)
 	if err != nil {
-		jsonError(w, "invalid clientId", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
@@ -32,41 +34,45 @@ func createToolbeltHandler(w http.ResponseWriter, r *http.Request) {
 		ClientID: ns,
 	}
 	kubecli := getK8Client(r)
-	err = createToolbelt(kubecli, &tb)
+	result, err := createToolbelt(kubecli, getPresigner(r), &tb, nil)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(applyStatus(result))
 	encode(w, tb)
 
 }
 
-// createToolbelt creates a presigned URL from the demo box and creates a k8s secret to persiste the data
-func createToolbelt(kubeCli kubernetes.Interface, tb *bedrock.Toolbelt) error {
+// createToolbelt creates a presigned URL from the demo box and creates or updates a k8s secret to persiste the data
+// the secret is recorded in tracker when is created
+func createToolbelt(kubeCli kubernetes.Interface, presigner awscli.Presigner, tb *bedrock.Toolbelt, tracker *deployTracker) (k8s.ApplyResult, error) {
 
 	bucket := "xumak-grid-boxes"
 	client := "demo"
 	box := "boot2docker_virtualbox2.box"
 	hours := 24
 
-	sess, err := awscli.Session()
-	if err != nil {
-		return fmt.Errorf("error session %s", err.Error())
-	}
-
 	key := client + "/" + box
 	s3obj := awscli.NewS3Object(bucket, key)
 
-	tb.URL, err = s3obj.PreSignedURL(sess, hours)
-	tb.Message = fmt.Sprintf("url expires in %dhrs, time created: %v", hours, time.Now())
+	var err error
+	tb.URL, err = presigner.PreSignedURL(s3obj, hours)
+	if err != nil {
+		return k8s.Unchanged, upstreamError("aws", err)
+	}
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(hours) * time.Hour).UTC()
+	tb.ExpiresAt = &expiresAt
+	tb.Message = fmt.Sprintf("url expires in %dhrs, time created: %v", hours, now)
 
-	_, err = k8s.CreateSecret(kubeCli, tb.ClientID, toolbeltSecret(*tb))
+	k8Secret, result, err := k8s.ApplySecret(kubeCli, tb.ClientID, toolbeltSecret(*tb))
 	if err != nil {
-		return fmt.Errorf("error creating secret. %s", err.Error())
+		return result, err
 	}
-	return nil
+	trackSecret(tracker, result, kubeCli, tb.ClientID, k8Secret.Name)
+	return result, nil
 }
 
 // getToolbeltHandler returns a toolbelt data from k8s secret
@@ -75,23 +81,53 @@ func getToolbeltHandler(w http.ResponseWriter, r *http.Request) {
 	ns := chi.URLParam(r, "clientId")
 	err := checkClient(r, ns)
 	if err != nil {
-		jsonError(w, "invalid clientId", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
 	kubecli := getK8Client(r)
 	k8secret, err := k8s.GetSecret(kubecli, ns, toolbletSecretName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
+	encode(w, toolbeltFromSecret(ns, k8secret))
+}
+
+// toolbeltFromSecret returns the toolbelt persisted in the k8s secret, the secrets created
+// before the expiration was persisted take it from the signature of the url
+func toolbeltFromSecret(ns string, secret *v1.Secret) bedrock.Toolbelt {
 	tb := bedrock.Toolbelt{
 		ClientID: ns,
-		URL:      string(k8secret.Data["url"]),
-		Message:  string(k8secret.Data["message"]),
+		URL:      string(secret.Data["url"]),
+		Message:  string(secret.Data["message"]),
 	}
-	encode(w, tb)
+	if expiresAt, err := time.Parse(time.RFC3339, string(secret.Data["expiresAt"])); err == nil {
+		tb.ExpiresAt = &expiresAt
+	} else {
+		tb.ExpiresAt = presignedURLExpiration(tb.URL)
+	}
+	return tb
+}
+
+// presignedURLExpiration returns the expiration of a S3 url presigned with signature v4, nil when
+// the url has no signature
+func presignedURLExpiration(rawURL string) *time.Time {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	signedAt, err := time.Parse("20060102T150405Z", u.Query().Get("X-Amz-Date"))
+	if err != nil {
+		return nil
+	}
+	seconds, err := strconv.Atoi(u.Query().Get("X-Amz-Expires"))
+	if err != nil {
+		return nil
+	}
+	expiresAt := signedAt.Add(time.Duration(seconds) * time.Second)
+	return &expiresAt
 }
 
 // deleteToolbeltHandler deletes k8s service with toolbelt data
@@ -99,14 +135,14 @@ func deleteToolbeltHandler(w http.ResponseWriter, r *http.Request) {
 	ns := chi.URLParam(r, "clientId")
 	err := checkClient(r, ns)
 	if err != nil {
-		jsonError(w, "invalid clientId", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
 	kubecli := getK8Client(r)
 	err = k8s.DeleteSecret(kubecli, ns, toolbletSecretName)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
@@ -125,6 +161,13 @@ func toolbeltSecret(tb bedrock.Toolbelt) *v1.Secret {
 	}
 	burl := []byte(tb.URL)
 	bmsg := []byte(tb.Message)
+	data := map[string][]byte{
+		"url":     burl,
+		"message": bmsg,
+	}
+	if tb.ExpiresAt != nil {
+		data["expiresAt"] = []byte(tb.ExpiresAt.Format(time.RFC3339))
+	}
 	return &v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      toolbletSecretName,
@@ -132,9 +175,6 @@ func toolbeltSecret(tb bedrock.Toolbelt) *v1.Secret {
 			Labels:    labels,
 		},
 		Type: v1.SecretTypeOpaque,
-		Data: map[string][]byte{
-			"url":     burl,
-			"message": bmsg,
-		},
+		Data: data,
 	}
 }
@@ -5,10 +5,20 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	certclient "github.com/jetstack/cert-manager/pkg/client/clientset/versioned"
 	aemclientset "github.com/xumak-grid/aem-operator/pkg/generated/clientset/versioned"
 	"github.com/xumak-grid/bedrock"
+	"github.com/xumak-grid/bedrock/audit"
+	"github.com/xumak-grid/bedrock/auth"
+	"github.com/xumak-grid/bedrock/awscli"
+	"github.com/xumak-grid/bedrock/k8s"
+	"github.com/xumak-grid/bedrock/secrets"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -19,6 +29,13 @@ func getK8Client(r *http.Request) kubernetes.Interface {
 	}
 	return nil
 }
+
+// getPrincipal returns the authenticated principal of the request, nil when is not present
+func getPrincipal(r *http.Request) *auth.Principal {
+	principal, _ := r.Context().Value(PrincipalKey).(*auth.Principal)
+	return principal
+}
+
 func getAEMClient(r *http.Request) aemclientset.Interface {
 	kubecli, ok := r.Context().Value(K8sAEMClient).(aemclientset.Interface)
 	if ok {
@@ -36,8 +53,40 @@ func getCertManagerClient(r *http.Request) certclient.Interface {
 	return nil
 }
 
+// getSecretService returns the secret service from the context in the request
+func getSecretService(r *http.Request) secrets.SecretService {
+	secretService, ok := r.Context().Value(SecretServiceKey).(secrets.SecretService)
+	if ok {
+		return secretService
+	}
+	return nil
+}
+
+// getAuditLog returns the audit log from the context in the request
+func getAuditLog(r *http.Request) *audit.Log {
+	entry, ok := r.Context().Value(AuditKey).(*auditEntry)
+	if ok {
+		return entry.log
+	}
+	return nil
+}
+
+// getPresigner returns the S3 presigner from the context in the request
+func getPresigner(r *http.Request) awscli.Presigner {
+	presigner, ok := r.Context().Value(PresignerKey).(awscli.Presigner)
+	if ok {
+		return presigner
+	}
+	return nil
+}
+
+// decode reads the json body of the request, an invalid body is a validation error
 func decode(r *http.Request, v interface{}) error {
-	return json.NewDecoder(r.Body).Decode(v)
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		return validationError("invalid body: " + err.Error())
+	}
+	return nil
 }
 
 func encode(w io.Writer, v interface{}) error {
@@ -51,18 +100,24 @@ func encode(w io.Writer, v interface{}) error {
 
 // JSONError represents an error in JSON format.
 type JSONError struct {
-	Code int    `json:"code"`
-	Msg  string `json:"msg"`
+	// Code is the http status code
+	Code int `json:"code"`
+	// Reason is a machine-readable bedrock.Reason* value
+	Reason  string               `json:"reason"`
+	Msg     string               `json:"msg"`
+	Details []bedrock.FieldError `json:"details,omitempty"`
 }
 
+// jsonError writes an error with the reason that corresponds to the status code
 func jsonError(w http.ResponseWriter, message string, code int) {
+	writeJSONError(w, &JSONError{Code: code, Reason: bedrock.StatusReason(code), Msg: message})
+}
+
+func writeJSONError(w http.ResponseWriter, err *JSONError) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
-	w.WriteHeader(code)
-	err := &JSONError{Code: code}
-	err.Msg = message
+	w.WriteHeader(err.Code)
 	_ = encode(w, err)
-	return
 }
 func validVendor(name string, vendors []bedrock.Vendor) bool {
 	for _, vendor := range vendors {
@@ -73,12 +128,113 @@ func validVendor(name string, vendors []bedrock.Vendor) bool {
 	return false
 }
 
+// validImage returns true if the image is one of the images of the vendor
+// secondary is only compared when the vendor image requires it
+func validImage(image, secondary string, vendor bedrock.Vendor) bool {
+	for _, i := range vendor.Images {
+		if i.Name == image && (i.Secondary == "" || i.Secondary == secondary) {
+			return true
+		}
+	}
+	return false
+}
+
+// containerImage returns the image of the container without the grid docker repository
+func containerImage(sfs *appsv1beta2.StatefulSet, containerName string) string {
+	for _, c := range sfs.Spec.Template.Spec.Containers {
+		if c.Name == containerName {
+			return strings.TrimPrefix(c.Image, bedrock.GridDockerRepository()+"/")
+		}
+	}
+	return ""
+}
+
+// updateContainerImages sets in current the images of the containers in desired
+// containers are matched by name, returns true when at least one image changed
+func updateContainerImages(current, desired *appsv1beta2.StatefulSet) bool {
+	changed := false
+	for _, d := range desired.Spec.Template.Spec.Containers {
+		for i, c := range current.Spec.Template.Spec.Containers {
+			if c.Name == d.Name && c.Image != d.Image {
+				current.Spec.Template.Spec.Containers[i].Image = d.Image
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
 // getPodSecretKey returns the string key to be used when save secret for the given pod
 func getPodSecretKey(nsName, deploymentName, podName string) string {
 	return fmt.Sprintf("%s/%s", getSecretBasePath(nsName, deploymentName), podName)
 }
 
+// getClientSecretPath returns the path of all the secrets of the client in the namespace
+func getClientSecretPath(ns string) string {
+	return fmt.Sprintf("secret/%v", ns)
+}
+
 // getSecretBasePath returns the base path to be used when save secrets for the given namespace and deployment
 func getSecretBasePath(ns, deployment string) string {
-	return fmt.Sprintf("secret/%v/%v", ns, deployment)
+	return fmt.Sprintf("%v/%v", getClientSecretPath(ns), deployment)
+}
+
+// jobStatus returns the Operation status of a job from its conditions
+func jobStatus(job *batchv1.Job) string {
+	for _, c := range job.Status.Conditions {
+		if c.Status != v1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return bedrock.OperationSucceeded
+		case batchv1.JobFailed:
+			return bedrock.OperationFailed
+		}
+	}
+	return bedrock.OperationRunning
+}
+
+// applyStatus returns the http status for the result of an apply
+// 201 when something was created, otherwise 200
+func applyStatus(result k8s.ApplyResult) int {
+	if result == k8s.Created {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
+
+// applyInitJob applies the secret with the init configuration and the job that consumes it
+// when the configuration changes the previous job is deleted to run the job with the new configuration
+func applyInitJob(kubeCli kubernetes.Interface, ns string, secret *v1.Secret, job *batchv1.Job, tracker *deployTracker) (k8s.ApplyResult, error) {
+	k8Secret, applied, err := k8s.ApplySecret(kubeCli, ns, secret)
+	if err != nil {
+		return applied, err
+	}
+	trackSecret(tracker, applied, kubeCli, ns, k8Secret.Name)
+	if applied == k8s.Updated {
+		err = k8s.DeleteJob(kubeCli, ns, job.Name)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return applied, err
+		}
+	}
+	k8Job, result, err := k8s.ApplyJob(kubeCli, ns, job)
+	if err != nil {
+		return applied, err
+	}
+	trackJob(tracker, result, kubeCli, ns, k8Job.Name)
+	return applied.Merge(result), nil
+}
+
+// deleteInitJob deletes the init job and its configuration secret if they exist
+func deleteInitJob(kubeCli kubernetes.Interface, ns, jobName, secretName string) error {
+	err := k8s.DeleteJob(kubeCli, ns, jobName)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	err = k8s.DeleteSecret(kubeCli, ns, secretName)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
 }
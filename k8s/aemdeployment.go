@@ -1,15 +1,28 @@
 package k8s
 
 import (
+	"strings"
+	"time"
+
 	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
 	aemclientset "github.com/xumak-grid/aem-operator/pkg/generated/clientset/versioned"
 	"github.com/xumak-grid/bedrock"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 )
 
+const (
+	// PasswordRotationAnnotation is the time of the last password rotation of the aem deployment,
+	// the aem-operator applies the passwords of the secret service when it changes
+	PasswordRotationAnnotation = "aem.grid.xumak.io/password-rotation"
+	// PasswordRotationInstancesAnnotation lists the pods of the last password rotation
+	PasswordRotationInstancesAnnotation = "aem.grid.xumak.io/password-rotation-instances"
+)
+
 // CreateAEMDeployment creates an aem deployment.
 func CreateAEMDeployment(cli aemclientset.Interface, aemDep *bedrock.AEMDeployment) (*aemv1beta1.AEMDeployment, error) {
 	k8sdep := &aemv1beta1.AEMDeployment{
@@ -18,43 +31,32 @@ func CreateAEMDeployment(cli aemclientset.Interface, aemDep *bedrock.AEMDeployme
 			Namespace:   aemDep.ClientID,
 			Annotations: gridLabels,
 		},
-		Spec: aemv1beta1.AEMDeploymentSpec{
-			Version:           aemDep.Spec.Version,
-			DispatcherVersion: aemDep.Spec.DispatcherVersion,
-			Authors: aemv1beta1.InstanceSpec{
-				Replicas: aemDep.Spec.Authors.Replicas,
-				Type:     aemDep.Spec.Authors.Type,
-			},
-			Publishers: aemv1beta1.InstanceSpec{
-				Replicas: aemDep.Spec.Publishers.Replicas,
-				Type:     aemDep.Spec.Publishers.Type,
-			},
-			Dispatchers: aemv1beta1.InstanceSpec{
-				Replicas: aemDep.Spec.Dispatchers.Replicas,
-				Type:     aemDep.Spec.Dispatchers.Type,
-			},
-		},
+		Spec: aemDeploymentSpec(aemDep),
 	}
 	return cli.AemV1beta1().AEMDeployments(aemDep.ClientID).Create(k8sdep)
 }
 
-// DeleteAEMDeployment deletes the aem deployment base on the clientID and the environment
-func DeleteAEMDeployment(cli aemclientset.Interface, aemDep *bedrock.AEMDeployment) error {
-	return cli.AemV1beta1().AEMDeployments(aemDep.ClientID).Delete(aemDep.EnvironmentID, &metav1.DeleteOptions{})
-}
-
-// GetAEMDeployment returns and AEM deployment base on the bedrock AEM deployment
-func GetAEMDeployment(cli aemclientset.Interface, aemDep *bedrock.AEMDeployment) (*aemv1beta1.AEMDeployment, error) {
-	return cli.AemV1beta1().AEMDeployments(aemDep.ClientID).Get(aemDep.EnvironmentID, metav1.GetOptions{})
-}
-
-// UpdateAEMDeployment updates the aem deployment
-func UpdateAEMDeployment(cli aemclientset.Interface, aemDep *bedrock.AEMDeployment) error {
-	k8sDep, err := GetAEMDeployment(cli, aemDep)
+// ApplyAEMDeployment creates the aem deployment or updates it when the spec differs
+func ApplyAEMDeployment(cli aemclientset.Interface, aemDep *bedrock.AEMDeployment) (ApplyResult, error) {
+	current, err := GetAEMDeployment(cli, aemDep)
+	if k8serrors.IsNotFound(err) {
+		_, err = CreateAEMDeployment(cli, aemDep)
+		return Created, err
+	}
 	if err != nil {
-		return err
+		return Unchanged, err
+	}
+	if equality.Semantic.DeepDerivative(aemDeploymentSpec(aemDep), current.Spec) {
+		return Unchanged, nil
 	}
-	k8sDep.Spec = aemv1beta1.AEMDeploymentSpec{
+	current.Spec = aemDeploymentSpec(aemDep)
+	_, err = cli.AemV1beta1().AEMDeployments(aemDep.ClientID).Update(current)
+	return Updated, err
+}
+
+// aemDeploymentSpec returns the operator spec from the bedrock AEM deployment
+func aemDeploymentSpec(aemDep *bedrock.AEMDeployment) aemv1beta1.AEMDeploymentSpec {
+	return aemv1beta1.AEMDeploymentSpec{
 		Version:           aemDep.Spec.Version,
 		DispatcherVersion: aemDep.Spec.DispatcherVersion,
 		Authors: aemv1beta1.InstanceSpec{
@@ -70,6 +72,25 @@ func UpdateAEMDeployment(cli aemclientset.Interface, aemDep *bedrock.AEMDeployme
 			Type:     aemDep.Spec.Dispatchers.Type,
 		},
 	}
+}
+
+// DeleteAEMDeployment deletes the aem deployment base on the clientID and the environment
+func DeleteAEMDeployment(cli aemclientset.Interface, aemDep *bedrock.AEMDeployment) error {
+	return cli.AemV1beta1().AEMDeployments(aemDep.ClientID).Delete(aemDep.EnvironmentID, &metav1.DeleteOptions{})
+}
+
+// GetAEMDeployment returns and AEM deployment base on the bedrock AEM deployment
+func GetAEMDeployment(cli aemclientset.Interface, aemDep *bedrock.AEMDeployment) (*aemv1beta1.AEMDeployment, error) {
+	return cli.AemV1beta1().AEMDeployments(aemDep.ClientID).Get(aemDep.EnvironmentID, metav1.GetOptions{})
+}
+
+// UpdateAEMDeployment updates the aem deployment
+func UpdateAEMDeployment(cli aemclientset.Interface, aemDep *bedrock.AEMDeployment) error {
+	k8sDep, err := GetAEMDeployment(cli, aemDep)
+	if err != nil {
+		return err
+	}
+	k8sDep.Spec = aemDeploymentSpec(aemDep)
 
 	_, err = cli.AemV1beta1().AEMDeployments(aemDep.ClientID).Update(k8sDep)
 	if err != nil {
@@ -78,6 +99,38 @@ func UpdateAEMDeployment(cli aemclientset.Interface, aemDep *bedrock.AEMDeployme
 	return nil
 }
 
+// ScaleAEMDeployment changes only the replicas of the instances of the aem deployment,
+// the types and the versions are kept
+func ScaleAEMDeployment(cli aemclientset.Interface, aemDep *bedrock.AEMDeployment) error {
+	k8sDep, err := GetAEMDeployment(cli, aemDep)
+	if err != nil {
+		return err
+	}
+	k8sDep.Spec.Authors.Replicas = aemDep.Spec.Authors.Replicas
+	k8sDep.Spec.Publishers.Replicas = aemDep.Spec.Publishers.Replicas
+	k8sDep.Spec.Dispatchers.Replicas = aemDep.Spec.Dispatchers.Replicas
+	_, err = cli.AemV1beta1().AEMDeployments(aemDep.ClientID).Update(k8sDep)
+	return err
+}
+
+// RequestPasswordRotation annotates the aem deployment to make the aem-operator change the admin
+// password of the instances to the one stored in the secret service
+func RequestPasswordRotation(cli aemclientset.Interface, aemDep *bedrock.AEMDeployment, instances []string, at time.Time) error {
+	k8sDep, err := GetAEMDeployment(cli, aemDep)
+	if err != nil {
+		return err
+	}
+	annotations := map[string]string{}
+	for k, v := range k8sDep.Annotations {
+		annotations[k] = v
+	}
+	annotations[PasswordRotationAnnotation] = at.UTC().Format(time.RFC3339)
+	annotations[PasswordRotationInstancesAnnotation] = strings.Join(instances, ",")
+	k8sDep.Annotations = annotations
+	_, err = cli.AemV1beta1().AEMDeployments(aemDep.ClientID).Update(k8sDep)
+	return err
+}
+
 func ListAEMDeployments(cli aemclientset.Interface, ns string) ([]aemv1beta1.AEMDeployment, error) {
 	deployments, err := cli.AemV1beta1().AEMDeployments(ns).List(metav1.ListOptions{})
 	if err != nil {
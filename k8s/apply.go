@@ -0,0 +1,41 @@
+package k8s
+
+// ApplyResult represents the change made in the cluster by an Apply function
+type ApplyResult string
+
+const (
+	// Unchanged the resource already exists with the desired state
+	Unchanged ApplyResult = "unchanged"
+	// Created the resource did not exist and was created
+	Created ApplyResult = "created"
+	// Updated the resource existed and was updated to the desired state
+	Updated ApplyResult = "updated"
+)
+
+// Merge returns the most relevant result between r and other,
+// Created takes precedence over Updated and Updated over Unchanged
+func (r ApplyResult) Merge(other ApplyResult) ApplyResult {
+	if r == Created || other == Created {
+		return Created
+	}
+	if r == Updated || other == Updated {
+		return Updated
+	}
+	return Unchanged
+}
+
+// mergeMap adds the key/values from src to dst, it returns the resulting map
+// and true if a key was added or changed
+func mergeMap(dst, src map[string]string) (map[string]string, bool) {
+	changed := false
+	for k, v := range src {
+		if dst == nil {
+			dst = map[string]string{}
+		}
+		if current, ok := dst[k]; !ok || current != v {
+			dst[k] = v
+			changed = true
+		}
+	}
+	return dst, changed
+}
@@ -0,0 +1,47 @@
+package k8s
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestApplyResultMerge(t *testing.T) {
+	tests := []struct {
+		a, b, want ApplyResult
+	}{
+		{Unchanged, Unchanged, Unchanged},
+		{Unchanged, Updated, Updated},
+		{Updated, Created, Created},
+		{Created, Unchanged, Created},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Merge(tt.b); got != tt.want {
+			t.Errorf("%v.Merge(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestApplySecret(t *testing.T) {
+	kubecli := fake.NewSimpleClientset()
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "config"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	}
+
+	_, result, err := ApplySecret(kubecli, "bedrock", secret)
+	if err != nil || result != Created {
+		t.Fatalf("first apply: result %v, err %v", result, err)
+	}
+	_, result, err = ApplySecret(kubecli, "bedrock", secret)
+	if err != nil || result != Unchanged {
+		t.Fatalf("second apply: result %v, err %v", result, err)
+	}
+	secret.Data["key"] = []byte("new value")
+	_, result, err = ApplySecret(kubecli, "bedrock", secret)
+	if err != nil || result != Updated {
+		t.Fatalf("apply with new data: result %v, err %v", result, err)
+	}
+}
@@ -5,9 +5,15 @@ import (
 
 	certmanager "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha1"
 	certclient "github.com/jetstack/cert-manager/pkg/client/clientset/versioned"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// CertificateName returns the name of the certManager Certificate of the namespace
+func CertificateName(ns string) string {
+	return ns + "-account-certificate"
+}
+
 // CreateCertficate creates a cerManager Certificate for all ingresses in the namespace
 func CreateCertficate(kubecli certclient.Interface, ns string) (*certmanager.Certificate, error) {
 
@@ -24,7 +30,7 @@ func CreateCertficate(kubecli certclient.Interface, ns string) (*certmanager.Cer
 
 	cert := &certmanager.Certificate{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      ns + "-account-certificate",
+			Name:      CertificateName(ns),
 			Namespace: ns,
 		},
 		Spec: certmanager.CertificateSpec{
@@ -56,3 +62,36 @@ func CreateCertficate(kubecli certclient.Interface, ns string) (*certmanager.Cer
 	}
 	return kubecli.Certmanager().Certificates(ns).Create(cert)
 }
+
+// DeleteCertificate deletes a cerManager Certificate
+func DeleteCertificate(kubecli certclient.Interface, ns, certificateName string) error {
+	return kubecli.Certmanager().Certificates(ns).Delete(certificateName, &metav1.DeleteOptions{})
+}
+
+// ApplyCertificate creates the certManager Certificate of the namespace if it does not exist
+func ApplyCertificate(kubecli certclient.Interface, ns string) (*certmanager.Certificate, ApplyResult, error) {
+	current, err := kubecli.Certmanager().Certificates(ns).Get(CertificateName(ns), metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		cert, err := CreateCertficate(kubecli, ns)
+		return cert, Created, err
+	}
+	if err != nil {
+		return nil, Unchanged, err
+	}
+	return current, Unchanged, nil
+}
+
+// GetCertificate returns the certManager Certificate of the namespace
+func GetCertificate(kubecli certclient.Interface, ns string) (*certmanager.Certificate, error) {
+	return kubecli.Certmanager().Certificates(ns).Get(CertificateName(ns), metav1.GetOptions{})
+}
+
+// CertificateReady returns true when the certificate is issued and the message of its Ready condition
+func CertificateReady(cert *certmanager.Certificate) (bool, string) {
+	for _, c := range cert.Status.Conditions {
+		if c.Type == certmanager.CertificateConditionReady {
+			return c.Status == certmanager.ConditionTrue, c.Message
+		}
+	}
+	return false, ""
+}
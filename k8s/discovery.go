@@ -0,0 +1,28 @@
+package k8s
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// CheckAPIServer returns an error if the kubernetes API is not reachable
+func CheckAPIServer(kubecli kubernetes.Interface) error {
+	_, err := kubecli.Discovery().ServerVersion()
+	return err
+}
+
+// CheckResource returns an error if the resource is not served in the groupVersion,
+// for example when the CRD of an operator is not installed
+func CheckResource(kubecli kubernetes.Interface, groupVersion, resource string) error {
+	list, err := kubecli.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		return err
+	}
+	for _, r := range list.APIResources {
+		if r.Name == resource {
+			return nil
+		}
+	}
+	return fmt.Errorf("resource %v not found in %v", resource, groupVersion)
+}
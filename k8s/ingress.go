@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	v1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -39,3 +40,25 @@ func GetIngress(kubecli kubernetes.Interface, namespace, ingressName string) (*v
 func UpdateIngress(kubecli kubernetes.Interface, namespace string, ingress *v1beta1.Ingress) (*v1beta1.Ingress, error) {
 	return kubecli.ExtensionsV1beta1().Ingresses(namespace).Update(ingress)
 }
+
+// ApplyIngress creates the ingress or updates it when differs from the desired ingress
+func ApplyIngress(kubecli kubernetes.Interface, namespace string, ingress *v1beta1.Ingress) (*v1beta1.Ingress, ApplyResult, error) {
+	current, err := GetIngress(kubecli, namespace, ingress.Name)
+	if k8serrors.IsNotFound(err) {
+		ing, err := CreateIngress(kubecli, namespace, ingress)
+		return ing, Created, err
+	}
+	if err != nil {
+		return nil, Unchanged, err
+	}
+	labels, labelsChanged := mergeMap(current.Labels, ingress.Labels)
+	annotations, annotationsChanged := mergeMap(current.Annotations, ingress.Annotations)
+	if !labelsChanged && !annotationsChanged && equality.Semantic.DeepDerivative(ingress.Spec, current.Spec) {
+		return current, Unchanged, nil
+	}
+	current.Labels = labels
+	current.Annotations = annotations
+	current.Spec = ingress.Spec
+	ing, err := UpdateIngress(kubecli, namespace, current)
+	return ing, Updated, err
+}
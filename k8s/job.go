@@ -29,3 +29,22 @@ func DeleteJob(kubecli kubernetes.Interface, namespace, jobName string) error {
 	}
 	return kubecli.BatchV1().Jobs(namespace).Delete(jobName, ops)
 }
+
+// GetJob get a k8s job
+func GetJob(kubecli kubernetes.Interface, namespace, jobName string) (*v1.Job, error) {
+	return kubecli.BatchV1().Jobs(namespace).Get(jobName, metav1.GetOptions{})
+}
+
+// ApplyJob creates the job if it does not exist
+// the template of a job is immutable, an existing job is never updated
+func ApplyJob(kubecli kubernetes.Interface, namespace string, job *v1.Job) (*v1.Job, ApplyResult, error) {
+	current, err := GetJob(kubecli, namespace, job.Name)
+	if k8serrors.IsNotFound(err) {
+		jb, err := CreateJob(kubecli, namespace, job)
+		return jb, Created, err
+	}
+	if err != nil {
+		return nil, Unchanged, err
+	}
+	return current, Unchanged, nil
+}
@@ -0,0 +1,63 @@
+package k8s
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xumak-grid/bedrock/metrics"
+	"k8s.io/client-go/rest"
+)
+
+// instrumentedTransport observes the latency of the calls to the kubernetes API
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(res.StatusCode)
+	}
+	metrics.KubernetesRequestDuration.
+		WithLabelValues(req.Method, apiResource(req.URL.Path), code).
+		Observe(time.Since(start).Seconds())
+	return res, err
+}
+
+// Instrument wraps the transport of cfg to observe every call made by the clients
+// created with it, including the aem-operator and certManager clients
+func Instrument(cfg *rest.Config) {
+	wrap := cfg.WrapTransport
+	cfg.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if wrap != nil {
+			rt = wrap(rt)
+		}
+		return instrumentedTransport{next: rt}
+	}
+}
+
+// apiResource returns the resource of a kubernetes API path, for example
+// /apis/apps/v1beta2/namespaces/demo/statefulsets/gogs-server returns statefulsets
+// the paths that are not resources, like /version, return the first segment
+func apiResource(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	var rest []string
+	switch {
+	case parts[0] == "api" && len(parts) > 2:
+		rest = parts[2:]
+	case parts[0] == "apis" && len(parts) > 3:
+		rest = parts[3:]
+	case parts[0] == "api" || parts[0] == "apis":
+		return "discovery"
+	default:
+		return parts[0]
+	}
+	if rest[0] == "namespaces" && len(rest) > 2 {
+		return rest[2]
+	}
+	return rest[0]
+}
@@ -0,0 +1,21 @@
+package k8s
+
+import "testing"
+
+func TestAPIResource(t *testing.T) {
+	tests := map[string]string{
+		"/api/v1/namespaces":                                          "namespaces",
+		"/api/v1/namespaces/demo":                                     "namespaces",
+		"/api/v1/namespaces/demo/services/gogs-srvc":                  "services",
+		"/apis/apps/v1beta2/namespaces/demo/statefulsets/gogs-server": "statefulsets",
+		"/apis/aem.xumak.io/v1beta1/namespaces/demo/aemdeployments":   "aemdeployments",
+		"/apis/certmanager.k8s.io/v1alpha1":                           "discovery",
+		"/api":                                                        "discovery",
+		"/version":                                                    "version",
+	}
+	for path, expected := range tests {
+		if r := apiResource(path); r != expected {
+			t.Errorf("apiResource(%v) = %v, expected %v", path, r, expected)
+		}
+	}
+}
@@ -5,10 +5,20 @@ import (
 	"strings"
 
 	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
+const (
+	// SuspensionAnnotation stores in the namespace of a suspended client the json of its bedrock.ClientSuspension
+	SuspensionAnnotation = "grid.xumak.io/suspension"
+	// ProtectedAnnotation prevents the deletion of the client while it is "true"
+	ProtectedAnnotation = "grid.xumak.io/protected"
+	// DeletionAnnotation stores in the namespace of a client scheduled for deletion the json of its bedrock.ClientDeletion
+	DeletionAnnotation = "grid.xumak.io/deletion"
+)
+
 var (
 	// gridLabels are labels that identified grid resources
 	gridLabels = map[string]string{
@@ -28,6 +38,70 @@ func CreateNamespace(kubecli kubernetes.Interface, name string, annotations map[
 	return kubecli.CoreV1().Namespaces().Create(ns)
 }
 
+// ApplyNamespace creates the namespace or adds the annotations to the existing namespace
+// an existing namespace without gridLabels is not a client and returns an error
+func ApplyNamespace(kubecli kubernetes.Interface, name string, annotations map[string]string) (*v1.Namespace, ApplyResult, error) {
+	current, err := kubecli.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		ns, err := CreateNamespace(kubecli, name, annotations)
+		return ns, Created, err
+	}
+	if err != nil {
+		return nil, Unchanged, err
+	}
+	for k, v := range gridLabels {
+		if current.Labels[k] != v {
+			return nil, Unchanged, fmt.Errorf("namespace \"%v\" already exists and is not a grid client", name)
+		}
+	}
+	merged, changed := mergeMap(current.Annotations, annotations)
+	if !changed {
+		return current, Unchanged, nil
+	}
+	current.Annotations = merged
+	ns, err := kubecli.CoreV1().Namespaces().Update(current)
+	return ns, Updated, err
+}
+
+// SetNamespaceAnnotation sets the annotation key of the namespace, an empty value removes it
+func SetNamespaceAnnotation(kubecli kubernetes.Interface, name, key, value string) (*v1.Namespace, error) {
+	if value == "" {
+		return UpdateNamespaceAnnotations(kubecli, name, map[string]*string{key: nil})
+	}
+	return UpdateNamespaceAnnotations(kubecli, name, map[string]*string{key: &value})
+}
+
+// UpdateNamespaceAnnotations sets the annotations of the namespace, a nil value removes the annotation
+// the namespace is not updated when the annotations do not change
+func UpdateNamespaceAnnotations(kubecli kubernetes.Interface, name string, changes map[string]*string) (*v1.Namespace, error) {
+	current, err := GetNamespace(kubecli, name)
+	if err != nil {
+		return nil, err
+	}
+	annotations := map[string]string{}
+	for k, v := range current.Annotations {
+		annotations[k] = v
+	}
+	changed := false
+	for k, v := range changes {
+		old, ok := annotations[k]
+		switch {
+		case v == nil && ok:
+			delete(annotations, k)
+		case v != nil && (!ok || old != *v):
+			annotations[k] = *v
+		default:
+			continue
+		}
+		changed = true
+	}
+	if !changed {
+		return current, nil
+	}
+	current.Annotations = annotations
+	return kubecli.CoreV1().Namespaces().Update(current)
+}
+
 // GetNamespaces lists all namespaces with gridLabels
 func GetNamespaces(kubecli kubernetes.Interface) ([]v1.Namespace, error) {
 	ops := metav1.ListOptions{
@@ -42,7 +116,8 @@ func GetNamespaces(kubecli kubernetes.Interface) ([]v1.Namespace, error) {
 	return namespaces, nil
 }
 
-// GetNamespace returns a namespace with gridLabels
+// GetNamespace returns a namespace with gridLabels, a kubernetes not found error when
+// the namespace does not exist or is not a grid client
 func GetNamespace(kubecli kubernetes.Interface, name string) (*v1.Namespace, error) {
 	namespaces, err := GetNamespaces(kubecli)
 	if err != nil {
@@ -53,7 +128,7 @@ func GetNamespace(kubecli kubernetes.Interface, name string) (*v1.Namespace, err
 			return &ns, nil
 		}
 	}
-	return nil, fmt.Errorf("namespace \"%v\" not found", name)
+	return nil, k8serrors.NewNotFound(v1.Resource("namespaces"), name)
 }
 
 // DeleteNamespace deletes a namespace with gridLabels
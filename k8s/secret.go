@@ -4,8 +4,10 @@ import (
 	"errors"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -26,7 +28,44 @@ func DeleteSecret(kubecli kubernetes.Interface, namespace, secretName string) er
 	return kubecli.CoreV1().Secrets(namespace).Delete(secretName, &metav1.DeleteOptions{})
 }
 
+// DeleteSecrets deletes the k8s secrets of the namespace that have the labels
+func DeleteSecrets(kubecli kubernetes.Interface, namespace string, secretLabels map[string]string) error {
+	list, err := kubecli.CoreV1().Secrets(namespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(secretLabels).String(),
+	})
+	if err != nil {
+		return err
+	}
+	for _, secret := range list.Items {
+		err = DeleteSecret(kubecli, namespace, secret.Name)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetSecret get a k8s secret
 func GetSecret(kubecli kubernetes.Interface, namespace, secretName string) (*v1.Secret, error) {
 	return kubecli.CoreV1().Secrets(namespace).Get(secretName, metav1.GetOptions{})
 }
+
+// ApplySecret creates the secret or updates its data when differs from the desired secret
+func ApplySecret(kubecli kubernetes.Interface, namespace string, secret *v1.Secret) (*v1.Secret, ApplyResult, error) {
+	current, err := GetSecret(kubecli, namespace, secret.Name)
+	if k8serrors.IsNotFound(err) {
+		scrt, err := CreateSecret(kubecli, namespace, secret)
+		return scrt, Created, err
+	}
+	if err != nil {
+		return nil, Unchanged, err
+	}
+	labels, labelsChanged := mergeMap(current.Labels, secret.Labels)
+	if !labelsChanged && equality.Semantic.DeepEqual(secret.Data, current.Data) {
+		return current, Unchanged, nil
+	}
+	current.Labels = labels
+	current.Data = secret.Data
+	scrt, err := kubecli.CoreV1().Secrets(namespace).Update(current)
+	return scrt, Updated, err
+}
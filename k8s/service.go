@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -30,3 +31,26 @@ func DeleteService(kubecli kubernetes.Interface, namespace, serviceName string)
 func GetService(kubecli kubernetes.Interface, namespace, serviceName string) (*v1.Service, error) {
 	return kubecli.CoreV1().Services(namespace).Get(serviceName, metav1.GetOptions{})
 }
+
+// ApplyService creates the service or updates it when differs from the desired service
+func ApplyService(kubecli kubernetes.Interface, namespace string, service *v1.Service) (*v1.Service, ApplyResult, error) {
+	current, err := GetService(kubecli, namespace, service.Name)
+	if k8serrors.IsNotFound(err) {
+		srvc, err := CreateService(kubecli, namespace, service)
+		return srvc, Created, err
+	}
+	if err != nil {
+		return nil, Unchanged, err
+	}
+	labels, labelsChanged := mergeMap(current.Labels, service.Labels)
+	if !labelsChanged && equality.Semantic.DeepDerivative(service.Spec, current.Spec) {
+		return current, Unchanged, nil
+	}
+	// clusterIP is immutable and is kept from the current service
+	current.Labels = labels
+	current.Spec.Ports = service.Spec.Ports
+	current.Spec.Selector = service.Spec.Selector
+	current.Spec.Type = service.Spec.Type
+	srvc, err := kubecli.CoreV1().Services(namespace).Update(current)
+	return srvc, Updated, err
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -39,3 +40,50 @@ func GetStatefulSet(kubecli kubernetes.Interface, namespace, statefulSetName str
 func UpdateStatefulSet(kubecli kubernetes.Interface, namespace string, statefulSet *appsv1beta2.StatefulSet) (*appsv1beta2.StatefulSet, error) {
 	return kubecli.AppsV1beta2().StatefulSets(namespace).Update(statefulSet)
 }
+
+// ScaleStatefulSet changes the replicas of a statefulSet, it returns the previous replicas
+func ScaleStatefulSet(kubecli kubernetes.Interface, namespace, statefulSetName string, replicas int32) (int32, error) {
+	current, err := GetStatefulSet(kubecli, namespace, statefulSetName)
+	if err != nil {
+		return 0, err
+	}
+	// the replicas are 1 when are not set
+	previous := int32(1)
+	if current.Spec.Replicas != nil {
+		previous = *current.Spec.Replicas
+	}
+	if previous == replicas {
+		return previous, nil
+	}
+	current.Spec.Replicas = &replicas
+	_, err = UpdateStatefulSet(kubecli, namespace, current)
+	return previous, err
+}
+
+// ApplyStatefulSet creates the statefulSet or updates it when differs from the desired statefulSet
+// only the mutable fields are updated: replicas, template and update strategy
+func ApplyStatefulSet(kubecli kubernetes.Interface, namespace string, statefulSet *appsv1beta2.StatefulSet) (*appsv1beta2.StatefulSet, ApplyResult, error) {
+	current, err := GetStatefulSet(kubecli, namespace, statefulSet.Name)
+	if k8serrors.IsNotFound(err) {
+		sfs, err := CreateStatefulSet(kubecli, namespace, statefulSet)
+		return sfs, Created, err
+	}
+	if err != nil {
+		return nil, Unchanged, err
+	}
+	labels, labelsChanged := mergeMap(current.Labels, statefulSet.Labels)
+	if !labelsChanged &&
+		equality.Semantic.DeepDerivative(statefulSet.Spec.Replicas, current.Spec.Replicas) &&
+		equality.Semantic.DeepDerivative(statefulSet.Spec.Template, current.Spec.Template) &&
+		equality.Semantic.DeepDerivative(statefulSet.Spec.UpdateStrategy, current.Spec.UpdateStrategy) {
+		return current, Unchanged, nil
+	}
+	current.Labels = labels
+	current.Spec.Replicas = statefulSet.Spec.Replicas
+	current.Spec.Template = statefulSet.Spec.Template
+	if statefulSet.Spec.UpdateStrategy.Type != "" {
+		current.Spec.UpdateStrategy = statefulSet.Spec.UpdateStrategy
+	}
+	sfs, err := UpdateStatefulSet(kubecli, namespace, current)
+	return sfs, Updated, err
+}
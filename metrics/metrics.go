@@ -0,0 +1,110 @@
+// Package metrics contains the prometheus metrics exposed by the bedrock-api
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "bedrock"
+
+	// Success is the result label of an operation without errors
+	Success = "success"
+	// Failure is the result label of an operation with errors
+	Failure = "failure"
+)
+
+var (
+	// RequestDuration observes the latency of the api requests by chi route pattern
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the api requests by route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+
+	// ProvisioningTotal counts the provisioning of the stacks by vendor and result
+	ProvisioningTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provisioning_total",
+		Help:      "Provisioning attempts by stack, vendor and result.",
+	}, []string{"stack", "vendor", "result"})
+
+	// FullDeployDuration observes the time taken by a full deploy
+	FullDeployDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "full_deploy_duration_seconds",
+		Help:      "Time taken by a full deploy by result.",
+		Buckets:   []float64{5, 15, 30, 60, 120, 300, 600, 1200},
+	}, []string{"result"})
+
+	// KubernetesRequestDuration observes the latency of the calls to the kubernetes API
+	KubernetesRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kubernetes_request_duration_seconds",
+		Help:      "Latency of the kubernetes API calls by method, resource and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "resource", "code"})
+
+	// VaultRequestsTotal counts the calls to vault
+	VaultRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vault_requests_total",
+		Help:      "Vault calls by operation and result.",
+	}, []string{"operation", "result"})
+
+	// S3RequestsTotal counts the calls to AWS S3
+	S3RequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "s3_requests_total",
+		Help:      "AWS S3 calls by operation and result.",
+	}, []string{"operation", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		RequestDuration,
+		ProvisioningTotal,
+		FullDeployDuration,
+		KubernetesRequestDuration,
+		VaultRequestsTotal,
+		S3RequestsTotal,
+	)
+}
+
+// Handler returns the handler of the /metrics endpoint
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Result returns the result label for err
+func Result(err error) string {
+	if err != nil {
+		return Failure
+	}
+	return Success
+}
+
+// ObserveProvisioning counts the provisioning of a stack
+func ObserveProvisioning(stack, vendor string, err error) {
+	ProvisioningTotal.WithLabelValues(stack, vendor, Result(err)).Inc()
+}
+
+// ObserveFullDeploy observes the duration of a full deploy that started at start
+func ObserveFullDeploy(start time.Time, err error) {
+	FullDeployDuration.WithLabelValues(Result(err)).Observe(time.Since(start).Seconds())
+}
+
+// CountVault counts a vault call
+func CountVault(operation string, err error) {
+	VaultRequestsTotal.WithLabelValues(operation, Result(err)).Inc()
+}
+
+// CountS3 counts an AWS S3 call
+func CountS3(operation string, err error) {
+	S3RequestsTotal.WithLabelValues(operation, Result(err)).Inc()
+}
@@ -0,0 +1,183 @@
+// Package openapi builds OpenAPI 3 documents from Go types and validates
+// request bodies against the generated schemas.
+package openapi
+
+import (
+	"sort"
+	"strings"
+)
+
+// Version is the OpenAPI version of the generated documents
+const Version = "3.0.0"
+
+// ContentType is the only media type used by the api
+const ContentType = "application/json"
+
+// Document is the root of an OpenAPI document
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Security   []map[string][]string `json:"security,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+}
+
+// Info is the metadata of the api
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps the lowercase http methods of a path to its operations
+type PathItem map[string]*Operation
+
+// Operation describes a single method of a path
+type Operation struct {
+	Summary     string               `json:"summary,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter describes a path or query parameter of an operation
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of a request
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response of an operation, Content is empty when the response has no body
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the reusable objects of the document
+type Components struct {
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+	Schemas         map[string]*Schema        `json:"schemas"`
+}
+
+// SecurityScheme describes how the requests are authenticated
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// New returns an empty document
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+		},
+	}
+}
+
+// AddOperation adds the operation of method in path, the path parameters are written
+// between braces, for example /clients/{clientId}
+func (d *Document) AddOperation(method, path string, op *Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = PathItem{}
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// Body returns a required request body with the schema of v
+func (d *Document) Body(v interface{}) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content:  map[string]MediaType{ContentType: {Schema: d.Schema(v)}},
+	}
+}
+
+// Response returns a response with the schema of v, v nil is a response without body
+func (d *Document) Response(description string, v interface{}) *Response {
+	resp := &Response{Description: description}
+	if v != nil {
+		resp.Content = map[string]MediaType{ContentType: {Schema: d.Schema(v)}}
+	}
+	return resp
+}
+
+// PathParameters returns the parameters of a path, all of them are required strings
+// descriptions maps the names of the parameters to their description
+func PathParameters(path string, descriptions map[string]string) []Parameter {
+	params := []Parameter{}
+	for _, segment := range strings.Split(path, "/") {
+		if !isParameter(segment) {
+			continue
+		}
+		name := segment[1 : len(segment)-1]
+		params = append(params, Parameter{
+			Name:        name,
+			In:          "path",
+			Description: descriptions[name],
+			Required:    true,
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+	return params
+}
+
+// Find returns the operation that matches the method and the path of a request
+func (d *Document) Find(method, path string) (*Operation, bool) {
+	segments := splitPath(path)
+	// sorted to match the static segments before the parameters, for example /clients/new before /clients/{clientId}
+	paths := make([]string, 0, len(d.Paths))
+	for p := range d.Paths {
+		paths = append(paths, p)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	for _, p := range paths {
+		op, ok := d.Paths[p][strings.ToLower(method)]
+		if ok && matchPath(splitPath(p), segments) {
+			return op, true
+		}
+	}
+	return nil, false
+}
+
+func matchPath(template, segments []string) bool {
+	if len(template) != len(segments) {
+		return false
+	}
+	for i, t := range template {
+		if !isParameter(t) && t != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func isParameter(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
@@ -0,0 +1,104 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/xumak-grid/bedrock"
+)
+
+func TestSchema(t *testing.T) {
+	d := New(Info{Title: "test", Version: "v1"})
+	s := d.Schema([]bedrock.AEMDeployment{})
+	if s.Type != "array" || s.Items.Ref != refPrefix+"AEMDeployment" {
+		t.Fatalf("expected an array of AEMDeployment, got %+v", s)
+	}
+	for _, name := range []string{"AEMDeployment", "AEMDeploymentSpec", "Config"} {
+		if _, ok := d.Components.Schemas[name]; !ok {
+			t.Errorf("expected %v in the components", name)
+		}
+	}
+	config := d.Components.Schemas["Config"]
+	if !reflect.DeepEqual(config.Properties["type"].Enum, []string{"small", "medium", "large", ""}) {
+		t.Errorf("unexpected enum of type: %v", config.Properties["type"].Enum)
+	}
+	replicas := config.Properties["replicas"]
+	if *replicas.Minimum != 0 || *replicas.Maximum != 10 {
+		t.Errorf("unexpected bounds of replicas: %v %v", *replicas.Minimum, *replicas.Maximum)
+	}
+	spec := d.Components.Schemas["AEMDeploymentSpec"]
+	if !reflect.DeepEqual(spec.Required, []string{"version", "dispatcher_version"}) {
+		t.Errorf("unexpected required fields: %v", spec.Required)
+	}
+}
+
+func TestValidateBody(t *testing.T) {
+	d := New(Info{Title: "test", Version: "v1"})
+	body := d.Body(bedrock.Client{})
+	schema := body.Content[ContentType].Schema
+
+	tests := []struct {
+		name   string
+		body   string
+		fields []string
+	}{
+		{"valid", `{"clientId": "acme", "meta": {"team": "web"}}`, nil},
+		{"null optional field", `{"clientId": "acme", "configuration": null}`, nil},
+		{"missing required", `{"customConfig": true}`, []string{"clientId"}},
+		{"unknown field", `{"clientId": "acme", "name": "Acme"}`, []string{"name"}},
+		{"wrong types", `{"clientId": 1, "customConfig": "yes"}`, []string{"clientId", "customConfig"}},
+		{"nested", `{"clientId": "Acme", "configuration": {"adminEmail": "nope", "environments": []}}`, []string{
+			"clientId",
+			"configuration.fullCompanyName",
+			"configuration.aemInstancesVersion",
+			"configuration.aemInstancesType",
+			"configuration.dispatcherInstancesVersion",
+			"configuration.dispatcherInstancesType",
+			"configuration.adminEmail",
+			"configuration.environments",
+		}},
+		{"not an object", `[]`, []string{"body"}},
+	}
+	for _, tt := range tests {
+		var v interface{}
+		if err := json.Unmarshal([]byte(tt.body), &v); err != nil {
+			t.Fatal(err)
+		}
+		fields := []string{}
+		for _, e := range d.ValidateBody(schema, v) {
+			fields = append(fields, e.Field)
+		}
+		if len(fields) == 0 {
+			fields = nil
+		}
+		if !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("%v: expected errors in %v, got %v", tt.name, tt.fields, fields)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	d := New(Info{Title: "test", Version: "v1"})
+	get := &Operation{Summary: "get"}
+	list := &Operation{Summary: "list"}
+	d.AddOperation("GET", "/clients", list)
+	d.AddOperation("GET", "/clients/{clientId}", get)
+
+	tests := []struct {
+		method, path string
+		op           *Operation
+	}{
+		{"GET", "/clients", list},
+		{"GET", "/clients/", list},
+		{"GET", "/clients/acme", get},
+		{"POST", "/clients/acme", nil},
+		{"GET", "/clients/acme/scm", nil},
+	}
+	for _, tt := range tests {
+		op, ok := d.Find(tt.method, tt.path)
+		if ok != (tt.op != nil) || op != tt.op {
+			t.Errorf("%v %v: expected %+v, got %+v", tt.method, tt.path, tt.op, op)
+		}
+	}
+}
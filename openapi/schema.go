@@ -0,0 +1,202 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xumak-grid/bedrock"
+)
+
+// Schema is the subset of the OpenAPI schema object used by the generated documents
+type Schema struct {
+	Ref        string             `json:"$ref,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Enum       []string           `json:"enum,omitempty"`
+	Pattern    string             `json:"pattern,omitempty"`
+	MinLength  *int               `json:"minLength,omitempty"`
+	MaxLength  *int               `json:"maxLength,omitempty"`
+	Minimum    *int               `json:"minimum,omitempty"`
+	Maximum    *int               `json:"maximum,omitempty"`
+	MinItems   *int               `json:"minItems,omitempty"`
+	MaxItems   *int               `json:"maxItems,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	// AdditionalProperties is false in the structs, the fields that are not documented are rejected,
+	// and the schema of the values in the maps
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema   `json:"oneOf,omitempty"`
+}
+
+// OneOf is documented as a value of any of the types of its elements
+type OneOf []interface{}
+
+const refPrefix = "#/components/schemas/"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schema returns the schema of the type of v, the named structs are added to the
+// components of the document and referenced. The fields use the json names and
+// the validate tags of bedrock.Validate are documented as constraints of the schema
+func (d *Document) Schema(v interface{}) *Schema {
+	if o, ok := v.(OneOf); ok {
+		s := &Schema{}
+		for _, i := range o {
+			s.OneOf = append(s.OneOf, d.Schema(i))
+		}
+		return s
+	}
+	return d.typeSchema(reflect.TypeOf(v))
+}
+
+// Resolve follows the reference of s, it returns nil when the reference is not in the document
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, refPrefix)]
+	}
+	return s
+}
+
+func (d *Document) typeSchema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		if _, ok := d.Components.Schemas[t.Name()]; !ok {
+			// registered before the fields to support recursive types
+			d.Components.Schemas[t.Name()] = &Schema{}
+			*d.Components.Schemas[t.Name()] = *d.structSchema(t)
+		}
+		return &Schema{Ref: refPrefix + t.Name()}
+	case t.Kind() == reflect.Struct:
+		return d.structSchema(t)
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: d.typeSchema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.typeSchema(t.Elem())}
+	}
+	// interface values accept any json value
+	return &Schema{}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:                 "object",
+		Properties:           map[string]*Schema{},
+		AdditionalProperties: false,
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			embedded := d.Resolve(d.typeSchema(f.Type))
+			for n, p := range embedded.Properties {
+				s.Properties[n] = p
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		field := d.typeSchema(f.Type)
+		if applyRules(field, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = field
+	}
+	return s
+}
+
+// applyRules documents the validate rules in s, it returns true when the field is required
+func applyRules(s *Schema, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+		switch name {
+		case "required":
+			required = true
+			one := 1
+			switch s.Type {
+			case "string":
+				s.MinLength = &one
+			case "array":
+				s.MinItems = &one
+			}
+		case "dns1123label":
+			s.Pattern, s.MaxLength = bedrock.DNS1123LabelPattern, intPtr(bedrock.DNS1123LabelMaxLength)
+		case "dns1123subdomain":
+			s.Pattern, s.MaxLength = bedrock.DNS1123SubdomainPattern, intPtr(bedrock.DNS1123SubdomainMaxLength)
+		case "oneof":
+			s.Enum = strings.Fields(arg)
+		case "min", "max":
+			n, _ := strconv.Atoi(arg)
+			limit := limitFor(s, name)
+			if limit != nil {
+				*limit = &n
+			}
+		case "url":
+			s.Format = "uri"
+		case "email":
+			s.Format = "email"
+		}
+	}
+	// bedrock.Validate does not check the rules of the empty optional values
+	if !required && s.Enum != nil {
+		s.Enum = append(s.Enum, "")
+	}
+	return required
+}
+
+// limitFor returns the field of s that documents a min or max rule
+func limitFor(s *Schema, rule string) **int {
+	switch {
+	case s.Type == "string" && rule == "min":
+		return &s.MinLength
+	case s.Type == "string":
+		return &s.MaxLength
+	case s.Type == "array" && rule == "min":
+		return &s.MinItems
+	case s.Type == "array":
+		return &s.MaxItems
+	case s.Type == "integer" && rule == "min":
+		return &s.Minimum
+	case s.Type == "integer":
+		return &s.Maximum
+	}
+	return nil
+}
+
+func intPtr(n int) *int {
+	return &n
+}
@@ -0,0 +1,174 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/xumak-grid/bedrock"
+)
+
+// ValidateBody checks a decoded json value against the schema s of the document
+// and returns all the invalid fields, the path of the fields uses the json names
+func (d *Document) ValidateBody(s *Schema, v interface{}) []bedrock.FieldError {
+	return d.validate(s, v, "")
+}
+
+func (d *Document) validate(s *Schema, v interface{}, path string) []bedrock.FieldError {
+	s = d.Resolve(s)
+	if s == nil {
+		return nil
+	}
+	if len(s.OneOf) > 0 {
+		for _, o := range s.OneOf {
+			if len(d.validate(o, v, path)) == 0 {
+				return nil
+			}
+		}
+		return []bedrock.FieldError{fieldError(path, "does not match any of the allowed schemas")}
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return []bedrock.FieldError{fieldError(path, "must be an object")}
+		}
+		return d.validateObject(s, obj, path)
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return []bedrock.FieldError{fieldError(path, "must be an array")}
+		}
+		errs := []bedrock.FieldError{}
+		if msg := checkLength(len(arr), s.MinItems, s.MaxItems, "items"); msg != "" {
+			errs = append(errs, fieldError(path, msg))
+		}
+		for i, item := range arr {
+			errs = append(errs, d.validate(s.Items, item, fmt.Sprintf("%v[%d]", path, i))...)
+		}
+		return errs
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return []bedrock.FieldError{fieldError(path, "must be a string")}
+		}
+		if msg := checkString(s, str); msg != "" {
+			return []bedrock.FieldError{fieldError(path, msg)}
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			return []bedrock.FieldError{fieldError(path, "must be an integer")}
+		}
+		if s.Minimum != nil && n < float64(*s.Minimum) {
+			return []bedrock.FieldError{fieldError(path, fmt.Sprintf("must be at least %d", *s.Minimum))}
+		}
+		if s.Maximum != nil && n > float64(*s.Maximum) {
+			return []bedrock.FieldError{fieldError(path, fmt.Sprintf("must be at most %d", *s.Maximum))}
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return []bedrock.FieldError{fieldError(path, "must be a number")}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return []bedrock.FieldError{fieldError(path, "must be a boolean")}
+		}
+	}
+	return nil
+}
+
+func (d *Document) validateObject(s *Schema, obj map[string]interface{}, path string) []bedrock.FieldError {
+	errs := []bedrock.FieldError{}
+	for _, name := range s.Required {
+		if v, ok := obj[name]; !ok || v == nil {
+			errs = append(errs, fieldError(join(path, name), "is required"))
+		}
+	}
+	// sorted to return the errors always in the same order
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v := obj[name]
+		prop, ok := s.Properties[name]
+		if !ok {
+			if additional, ok := s.AdditionalProperties.(*Schema); ok {
+				errs = append(errs, d.validate(additional, v, join(path, name))...)
+			} else if s.AdditionalProperties == false {
+				errs = append(errs, fieldError(join(path, name), "is not a known field"))
+			}
+			continue
+		}
+		// null is decoded as the zero value, the required fields were already checked
+		if v == nil {
+			continue
+		}
+		errs = append(errs, d.validate(prop, v, join(path, name))...)
+	}
+	return errs
+}
+
+// checkString returns the error message when str does not match the string constraints of s,
+// like bedrock.Validate the empty values are only checked when they are required
+func checkString(s *Schema, str string) string {
+	if msg := checkLength(len(str), s.MinLength, s.MaxLength, "characters"); msg != "" {
+		return msg
+	}
+	if str == "" {
+		return ""
+	}
+	if len(s.Enum) > 0 {
+		for _, e := range s.Enum {
+			if str == e {
+				return ""
+			}
+		}
+		return "must be one of: " + strings.Join(s.Enum, ", ")
+	}
+	if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(str) {
+		return "must match the pattern " + s.Pattern
+	}
+	switch s.Format {
+	case "uri":
+		u, err := url.Parse(str)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return "must be an absolute url"
+		}
+	case "email":
+		if _, err := mail.ParseAddress(str); err != nil {
+			return "must be a valid email address"
+		}
+	}
+	return ""
+}
+
+func checkLength(n int, min, max *int, unit string) string {
+	if min != nil && n < *min {
+		return fmt.Sprintf("must have at least %d %v", *min, unit)
+	}
+	if max != nil && n > *max {
+		return fmt.Sprintf("must have at most %d %v", *max, unit)
+	}
+	return ""
+}
+
+func fieldError(path, msg string) bedrock.FieldError {
+	if path == "" {
+		path = "body"
+	}
+	return bedrock.FieldError{Field: path, Message: msg}
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
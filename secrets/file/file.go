@@ -0,0 +1,199 @@
+// Package file is a SecretService that keeps the secrets in a file encrypted with AES-256-GCM,
+// it is intended for single node development clusters without Vault.
+//
+// The whole file is rewritten on every change, the values are stored as JSON so the
+// numbers are returned as float64 like in the Vault implementation.
+package file
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/xumak-grid/bedrock/secrets"
+)
+
+// KeySize is the size of the encryption key in bytes
+const KeySize = 32
+
+// ErrInvalidKey is returned when the file can not be decrypted with the key
+var ErrInvalidKey = errors.New("the secrets file can not be decrypted, the key is invalid or the file is corrupted")
+
+type fileSecretService struct {
+	path string
+	aead cipher.AEAD
+
+	mu   sync.Mutex
+	data map[string]map[string]interface{}
+}
+
+// NewSecretService returns a secret service that stores the secrets in path encrypted with key,
+// the file is created on the first write when it does not exist.
+func NewSecretService(path string, key []byte) (secrets.SecretService, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("the key must have %v bytes, got %v", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	fss := &fileSecretService{path: path, aead: aead, data: map[string]map[string]interface{}{}}
+	err = fss.load()
+	if err != nil {
+		return nil, err
+	}
+	return fss, nil
+}
+
+// ParseKey decodes a base64 key, for example the output of `openssl rand -base64 32`
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("the key is not valid base64: %v", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("the key must have %v bytes, got %v", KeySize, len(key))
+	}
+	return key, nil
+}
+
+func (fss *fileSecretService) Get(key string) (map[string]interface{}, error) {
+	fss.mu.Lock()
+	defer fss.mu.Unlock()
+	value := make(map[string]interface{}, len(fss.data[key]))
+	for k, v := range fss.data[key] {
+		value[k] = v
+	}
+	return value, nil
+}
+
+func (fss *fileSecretService) Put(key string, value map[string]interface{}) error {
+	fss.mu.Lock()
+	defer fss.mu.Unlock()
+	// the value is stored as it will be read after a restart
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	stored := map[string]interface{}{}
+	err = json.Unmarshal(data, &stored)
+	if err != nil {
+		return err
+	}
+	previous, existed := fss.data[key]
+	fss.data[key] = stored
+	err = fss.save()
+	if err != nil {
+		// the memory is kept equal to the file
+		if existed {
+			fss.data[key] = previous
+		} else {
+			delete(fss.data, key)
+		}
+	}
+	return err
+}
+
+func (fss *fileSecretService) Delete(key string) error {
+	fss.mu.Lock()
+	defer fss.mu.Unlock()
+	previous, existed := fss.data[key]
+	if !existed {
+		return nil
+	}
+	delete(fss.data, key)
+	err := fss.save()
+	if err != nil {
+		fss.data[key] = previous
+	}
+	return err
+}
+
+// CleanUp deletes secrets under the especified path, example path secret/demo/dev
+func (fss *fileSecretService) CleanUp(path string) error {
+	fss.mu.Lock()
+	defer fss.mu.Unlock()
+	deleted := map[string]map[string]interface{}{}
+	for key, value := range fss.data {
+		if secrets.IsUnder(key, path) {
+			deleted[key] = value
+			delete(fss.data, key)
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+	err := fss.save()
+	if err != nil {
+		for key, value := range deleted {
+			fss.data[key] = value
+		}
+	}
+	return err
+}
+
+// load reads and decrypts the file, a missing file is an empty store
+func (fss *fileSecretService) load() error {
+	ciphertext, err := ioutil.ReadFile(fss.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	nonceSize := fss.aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return ErrInvalidKey
+	}
+	plaintext, err := fss.aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], nil)
+	if err != nil {
+		return ErrInvalidKey
+	}
+	return json.Unmarshal(plaintext, &fss.data)
+}
+
+// save encrypts the secrets with a new nonce and replaces the file atomically
+func (fss *fileSecretService) save() error {
+	plaintext, err := json.Marshal(fss.data)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, fss.aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return err
+	}
+	ciphertext := fss.aead.Seal(nonce, nonce, plaintext, nil)
+
+	tmp, err := ioutil.TempFile(filepath.Dir(fss.path), "."+filepath.Base(fss.path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(ciphertext)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	// TempFile creates the file with 0600
+	return os.Rename(tmp.Name(), fss.path)
+}
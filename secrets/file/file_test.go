@@ -0,0 +1,84 @@
+package file
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileSecretService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "secrets.enc")
+	key := bytes.Repeat([]byte{7}, KeySize)
+
+	ss, err := NewSecretService(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{"password": "s3cret", "port": 4502}
+	err = ss.Put("secret/acme/dev/author-0", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ss.Put("secret/acme/prod/author-0", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, []byte("s3cret")) {
+		t.Errorf("the secrets file is not encrypted")
+	}
+
+	// a new service reads the secrets stored by the previous one
+	ss, err = NewSecretService(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ss.Get("secret/acme/dev/author-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"password": "s3cret", "port": float64(4502)}
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected %v, got %v", expected, s)
+	}
+
+	err = ss.CleanUp("secret/acme/dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ = ss.Get("secret/acme/dev/author-0")
+	if len(s) != 0 {
+		t.Errorf("expected the secret deleted by CleanUp, got %v", s)
+	}
+	s, _ = ss.Get("secret/acme/prod/author-0")
+	if len(s) != 2 {
+		t.Errorf("expected the secret of prod, got %v", s)
+	}
+
+	_, err = NewSecretService(path, bytes.Repeat([]byte{8}, KeySize))
+	if err != ErrInvalidKey {
+		t.Errorf("expected an invalid key error, got %v", err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	_, err := ParseKey("c2hvcnQ=")
+	if err == nil {
+		t.Errorf("expected an error with a short key")
+	}
+	key, err := ParseKey("BwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwc=")
+	if err != nil || len(key) != KeySize {
+		t.Errorf("expected a key of %v bytes, got %v %v", KeySize, key, err)
+	}
+}
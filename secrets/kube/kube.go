@@ -0,0 +1,203 @@
+// Package kube is a SecretService that stores the secrets in Kubernetes Secrets of the client namespace,
+// it is intended for the clusters without Vault.
+//
+// The keys have the format mount/namespace/path, every key is a Secret in the namespace named
+// with the mount and the path joined by dots, for example secret/acme/dev/author-0 is the Secret
+// secret.dev.author-0 of the namespace acme. The segments of the path are also labels of the Secret,
+// CleanUp deletes the Secrets by label.
+//
+// Get reads any Secret with the name of the key, labelled or not. The aem-operator stores the
+// passwords of the instances in Vault, with this backend it has to write them to the Secret
+// secret.<environment>.<pod> of the client namespace, in the password field, otherwise the
+// passwords are not found until a rotation stores new ones.
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xumak-grid/bedrock/k8s"
+	"github.com/xumak-grid/bedrock/secrets"
+	"k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// AppLabel identifies the Secrets managed by the secret service
+	AppLabel = "bedrock-secrets"
+	// mountLabel is the first segment of the key
+	mountLabel = "secrets.bedrock/mount"
+	// pathLabelPrefix is followed by the position of the segment in the path, starting with 1
+	pathLabelPrefix = "secrets.bedrock/path-"
+	// keyAnnotation is the original key of the Secret
+	keyAnnotation = "secrets.bedrock/key"
+	// jsonAnnotation lists the fields that are not strings, they are stored as JSON
+	jsonAnnotation = "secrets.bedrock/json-fields"
+	// maxNameLength is the maximum length of a Secret name
+	maxNameLength = 253
+)
+
+// segmentPattern is a dns label, it is valid in the name and in the labels of the Secret
+var segmentPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+type kubeSecretService struct {
+	kubeClient func() kubernetes.Interface
+}
+
+// NewSecretService returns a secret service that uses the client returned by kubeClient,
+// the client is obtained on every call to use the current one after a kubeconfig reload.
+func NewSecretService(kubeClient func() kubernetes.Interface) secrets.SecretService {
+	return &kubeSecretService{kubeClient: kubeClient}
+}
+
+// location is the Secret of a key or the Secrets under a path
+type location struct {
+	namespace string
+	mount     string
+	path      []string
+}
+
+// parseKey splits a key in the format mount/namespace/path, the path is required when isKey
+func parseKey(key string, isKey bool) (location, error) {
+	segments := strings.Split(strings.Trim(key, "/"), "/")
+	if len(segments) < 2 || (isKey && len(segments) < 3) {
+		return location{}, fmt.Errorf("invalid key %q, the format is mount/namespace/path", key)
+	}
+	for _, s := range segments {
+		if !segmentPattern.MatchString(s) {
+			return location{}, fmt.Errorf("invalid key %q, the segment %q is not a dns label", key, s)
+		}
+	}
+	l := location{mount: segments[0], namespace: segments[1], path: segments[2:]}
+	if len(l.name()) > maxNameLength {
+		return location{}, fmt.Errorf("invalid key %q, the name of the secret is longer than %v", key, maxNameLength)
+	}
+	return l, nil
+}
+
+// name is the name of the Secret of the key
+func (l location) name() string {
+	return strings.Join(append([]string{l.mount}, l.path...), ".")
+}
+
+// labels selects the Secret of the key or the Secrets under the path
+func (l location) labels() map[string]string {
+	labels := map[string]string{
+		"app":      AppLabel,
+		mountLabel: l.mount,
+	}
+	for i, s := range l.path {
+		labels[pathLabelPrefix+strconv.Itoa(i+1)] = s
+	}
+	return labels
+}
+
+func (kss *kubeSecretService) Get(key string) (map[string]interface{}, error) {
+	l, err := parseKey(key, true)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := k8s.GetSecret(kss.kubeClient(), l.namespace, l.name())
+	if k8serrors.IsNotFound(err) {
+		return make(map[string]interface{}), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	jsonFields := map[string]bool{}
+	for _, field := range strings.Split(secret.Annotations[jsonAnnotation], ",") {
+		jsonFields[field] = true
+	}
+	value := make(map[string]interface{}, len(secret.Data))
+	for field, data := range secret.Data {
+		if !jsonFields[field] {
+			value[field] = string(data)
+			continue
+		}
+		var v interface{}
+		err = json.Unmarshal(data, &v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid field %v of the secret %v/%v: %v", field, l.namespace, l.name(), err)
+		}
+		value[field] = v
+	}
+	return value, nil
+}
+
+func (kss *kubeSecretService) Put(key string, value map[string]interface{}) error {
+	l, err := parseKey(key, true)
+	if err != nil {
+		return err
+	}
+	data := map[string][]byte{}
+	jsonFields := []string{}
+	for field, v := range value {
+		if s, ok := v.(string); ok {
+			data[field] = []byte(s)
+			continue
+		}
+		data[field], err = json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("invalid field %v: %v", field, err)
+		}
+		jsonFields = append(jsonFields, field)
+	}
+	sort.Strings(jsonFields)
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      l.name(),
+			Namespace: l.namespace,
+			Labels:    l.labels(),
+			Annotations: map[string]string{
+				keyAnnotation:  key,
+				jsonAnnotation: strings.Join(jsonFields, ","),
+			},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: data,
+	}
+
+	kubecli := kss.kubeClient()
+	current, err := k8s.GetSecret(kubecli, l.namespace, secret.Name)
+	if k8serrors.IsNotFound(err) {
+		_, err = k8s.CreateSecret(kubecli, l.namespace, secret)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	current.Labels = secret.Labels
+	current.Annotations = secret.Annotations
+	current.Data = secret.Data
+	_, err = kubecli.CoreV1().Secrets(l.namespace).Update(current)
+	return err
+}
+
+func (kss *kubeSecretService) Delete(key string) error {
+	l, err := parseKey(key, true)
+	if err != nil {
+		return err
+	}
+	err = k8s.DeleteSecret(kss.kubeClient(), l.namespace, l.name())
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// CleanUp deletes secrets under the especified path
+// this is usually when the deployment is deleted
+// example path secret/demo/dev
+func (kss *kubeSecretService) CleanUp(path string) error {
+	l, err := parseKey(path, false)
+	if err != nil {
+		return err
+	}
+	return k8s.DeleteSecrets(kss.kubeClient(), l.namespace, l.labels())
+}
@@ -0,0 +1,89 @@
+package kube
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKubeSecretService(t *testing.T) {
+	kubecli := fake.NewSimpleClientset()
+	ss := NewSecretService(func() kubernetes.Interface { return kubecli })
+
+	data := map[string]interface{}{"password": "s3cret", "port": float64(4502)}
+	for _, key := range []string{"secret/acme/dev/author-0", "secret/acme/dev/publish-0", "secret/acme/prod/author-0"} {
+		err := ss.Put(key, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	secret, err := kubecli.CoreV1().Secrets("acme").Get("secret.dev.author-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["password"]) != "s3cret" {
+		t.Errorf("expected the password in the secret, got %v", secret.Data)
+	}
+
+	s, err := ss.Get("secret/acme/dev/author-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, data) {
+		t.Errorf("expected %v, got %v", data, s)
+	}
+	s, err = ss.Get("secret/acme/dev/unknown")
+	if err != nil || len(s) != 0 {
+		t.Errorf("expected an empty secret, got %v %v", s, err)
+	}
+
+	err = ss.CleanUp("secret/acme/dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := kubecli.CoreV1().Secrets("acme").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "secret.prod.author-0" {
+		t.Errorf("expected only the secret of prod, got %v", list.Items)
+	}
+
+	err = ss.Delete("secret/acme/prod/author-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ss.Delete("secret/acme/prod/author-0")
+	if err != nil {
+		t.Errorf("expected the delete of a missing secret to succeed, got %v", err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		isKey bool
+		name  string
+		valid bool
+	}{
+		{"secret/acme/dev/author-0", true, "secret.dev.author-0", true},
+		{"/secret/acme/dev/", false, "secret.dev", true},
+		{"secret/acme", false, "secret", true},
+		{"secret/acme", true, "", false},
+		{"secret/acme/Dev", true, "", false},
+		{"secret/acme/dev.author", true, "", false},
+	}
+	for _, tt := range tests {
+		l, err := parseKey(tt.key, tt.isKey)
+		if (err == nil) != tt.valid {
+			t.Errorf("%v: expected valid %v, got %v", tt.key, tt.valid, err)
+			continue
+		}
+		if tt.valid && (l.namespace != "acme" || l.name() != tt.name) {
+			t.Errorf("%v: expected the secret acme/%v, got %+v", tt.key, tt.name, l)
+		}
+	}
+}
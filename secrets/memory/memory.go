@@ -0,0 +1,60 @@
+// Package memory is a SecretService that keeps the secrets in the memory of the process,
+// the secrets are lost on restart so it is only for tests and local development
+package memory
+
+import (
+	"sync"
+
+	"github.com/xumak-grid/bedrock/secrets"
+)
+
+type memorySecretService struct {
+	mu   sync.RWMutex
+	data map[string]map[string]interface{}
+}
+
+// NewSecretService returns a new empty secret service.
+func NewSecretService() secrets.SecretService {
+	return &memorySecretService{data: map[string]map[string]interface{}{}}
+}
+
+// Get returns a copy of the secret in key, an empty secret when it does not exist
+func (m *memorySecretService) Get(key string) (map[string]interface{}, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return copySecret(m.data[key]), nil
+}
+
+func (m *memorySecretService) Put(key string, value map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = copySecret(value)
+	return nil
+}
+
+func (m *memorySecretService) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+// CleanUp deletes secrets under the especified path, example path secret/demo/dev
+func (m *memorySecretService) CleanUp(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.data {
+		if secrets.IsUnder(key, path) {
+			delete(m.data, key)
+		}
+	}
+	return nil
+}
+
+func copySecret(value map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(value))
+	for k, v := range value {
+		c[k] = v
+	}
+	return c
+}
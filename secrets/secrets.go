@@ -1,5 +1,10 @@
 package secrets
 
+import (
+	"strings"
+	"time"
+)
+
 // SecretService should implement secret storage management.
 type SecretService interface {
 	Get(key string) (map[string]interface{}, error)
@@ -7,3 +12,27 @@ type SecretService interface {
 	Delete(Key string) error
 	CleanUp(path string) error
 }
+
+// Checker is implemented by the secret services that depend on an external system,
+// Check returns an error when the secrets can not be read or written
+type Checker interface {
+	Check() error
+}
+
+// Wrapper is implemented by the secret services that can return a single use token instead of the secret,
+// the secret is obtained with the token before it expires, for example with vault unwrap
+type Wrapper interface {
+	Wrap(key string, ttl time.Duration) (WrappedSecret, error)
+}
+
+// WrappedSecret is the token returned by Wrap
+type WrappedSecret struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// IsUnder returns true when key is in path or in one of its subpaths,
+// for example secret/demo/dev/author-0 is under secret/demo
+func IsUnder(key, path string) bool {
+	return strings.HasPrefix(key, strings.TrimSuffix(path, "/")+"/")
+}
@@ -0,0 +1,149 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/xumak-grid/bedrock/metrics"
+)
+
+// retryInterval is the wait after a failed renewal or login
+var retryInterval = 10 * time.Second
+
+// lease is the ttl of the current token
+type lease struct {
+	ttl       time.Duration
+	renewable bool
+}
+
+// authLease returns the lease of a token returned by a login or a renewal
+func authLease(auth *api.SecretAuth) lease {
+	return lease{
+		ttl:       time.Duration(auth.LeaseDuration) * time.Second,
+		renewable: auth.Renewable,
+	}
+}
+
+// login authenticates with the kubernetes or the approle auth method and replaces the client
+// with one that uses the new token
+func (vss *vaultSecretService) login() (lease, error) {
+	data := map[string]interface{}{}
+	switch vss.config.AuthMethod {
+	case KubernetesAuth:
+		jwt, err := ioutil.ReadFile(vss.config.ServiceAccountTokenPath)
+		if err != nil {
+			return lease{}, fmt.Errorf("error reading the service account token: %v", err)
+		}
+		data["role"] = vss.config.Role
+		data["jwt"] = strings.TrimSpace(string(jwt))
+	case AppRoleAuth:
+		data["role_id"] = vss.config.RoleID
+		data["secret_id"] = vss.config.SecretID
+	default:
+		return lease{}, fmt.Errorf("the %v auth method does not support login", vss.config.AuthMethod)
+	}
+
+	client, err := api.NewClient(vss.apiConfig)
+	if err != nil {
+		return lease{}, err
+	}
+	client.ClearToken()
+	s, err := client.Logical().Write("auth/"+vss.config.AuthMount+"/login", data)
+	metrics.CountVault("login", err)
+	if err != nil {
+		return lease{}, err
+	}
+	if s == nil || s.Auth == nil || s.Auth.ClientToken == "" {
+		return lease{}, fmt.Errorf("the login of auth/%v did not return a token", vss.config.AuthMount)
+	}
+	client.SetToken(s.Auth.ClientToken)
+
+	vss.mu.Lock()
+	vss.client = client
+	vss.mu.Unlock()
+	return authLease(s.Auth), nil
+}
+
+// lookupSelf returns the lease of the current token
+func (vss *vaultSecretService) lookupSelf() (lease, error) {
+	s, err := vss.vault().Auth().Token().LookupSelf()
+	metrics.CountVault("lookup-self", err)
+	if err != nil {
+		return lease{}, err
+	}
+	if s == nil {
+		return lease{}, fmt.Errorf("the token lookup returned nothing")
+	}
+	l := lease{}
+	l.renewable, _ = s.Data["renewable"].(bool)
+	if ttl, ok := s.Data["ttl"].(json.Number); ok {
+		seconds, err := ttl.Int64()
+		if err != nil {
+			return lease{}, fmt.Errorf("invalid ttl of the token %v", ttl)
+		}
+		l.ttl = time.Duration(seconds) * time.Second
+	}
+	return l, nil
+}
+
+// renewSelf extends the lease of the current token
+func (vss *vaultSecretService) renewSelf() (lease, error) {
+	s, err := vss.vault().Auth().Token().RenewSelf(0)
+	metrics.CountVault("renew-self", err)
+	if err != nil {
+		return lease{}, err
+	}
+	if s == nil || s.Auth == nil {
+		return lease{}, fmt.Errorf("the token renewal returned nothing")
+	}
+	return authLease(s.Auth), nil
+}
+
+// refresh renews the token, a new login is done when the token is not renewable or the lease
+// is shorter than the current one because it reached the max ttl
+func (vss *vaultSecretService) refresh(current lease) (lease, error) {
+	if current.renewable {
+		l, err := vss.renewSelf()
+		if vss.config.AuthMethod == TokenAuth || (err == nil && l.ttl >= current.ttl) {
+			return l, err
+		}
+		if err != nil {
+			vss.config.Log.Warnf("error renewing the vault token, logging in again: %v", err)
+		}
+	}
+	return vss.login()
+}
+
+// watch refreshes the token when two thirds of the lease have passed until Close is called
+func (vss *vaultSecretService) watch(l lease) {
+	if l.ttl == 0 {
+		return
+	}
+	if !l.renewable && vss.config.AuthMethod == TokenAuth {
+		vss.config.Log.Warnf("the vault token expires in %v and is not renewable", l.ttl)
+		return
+	}
+	wait := l.ttl * 2 / 3
+	for {
+		select {
+		case <-vss.stop:
+			return
+		case <-time.After(wait):
+		}
+		next, err := vss.refresh(l)
+		if err != nil {
+			vss.config.Log.Errorf("error refreshing the vault token, retrying in %v: %v", retryInterval, err)
+			wait = retryInterval
+			continue
+		}
+		if next.ttl == 0 {
+			return
+		}
+		l = next
+		wait = l.ttl * 2 / 3
+	}
+}
@@ -0,0 +1,121 @@
+package vault
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// TokenAuth uses the token of VAULT_TOKEN
+	TokenAuth = "token"
+	// KubernetesAuth logs in with the service account token of the pod
+	KubernetesAuth = "kubernetes"
+	// AppRoleAuth logs in with a role id and a secret id
+	AppRoleAuth = "approle"
+
+	// DefaultServiceAccountTokenPath is where kubernetes mounts the token of the pod
+	DefaultServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	// defaultKVMount is the first segment of the keys used by the handlers
+	defaultKVMount = "secret"
+)
+
+// Config is the address, the authentication and the KV secrets engine of vault
+type Config struct {
+	// Address of vault, VAULT_ADDR is used when is empty
+	Address string
+	// AuthMethod is token, kubernetes or approle
+	AuthMethod string
+	// AuthMount is the path where the auth method is enabled, by default the name of the method
+	AuthMount string
+	// Role of the kubernetes auth method
+	Role string
+	// ServiceAccountTokenPath is the jwt sent by the kubernetes auth method
+	ServiceAccountTokenPath string
+	// RoleID and SecretID are the credentials of the approle auth method
+	RoleID   string
+	SecretID string
+	// KVMount replaces the first segment of the keys, secret/acme/dev is stored in <KVMount>/acme/dev
+	KVMount string
+	// KVVersion is the version of the KV secrets engine mounted in KVMount, 1 or 2
+	KVVersion int
+	// Log reports the renewals and the logins, the standard logger when is nil
+	Log logrus.FieldLogger
+}
+
+// ConfigFromEnv reads the config from VAULT_ADDR, VAULT_AUTH_METHOD, VAULT_AUTH_MOUNT, VAULT_ROLE,
+// VAULT_SA_TOKEN_PATH, VAULT_ROLE_ID, VAULT_SECRET_ID (or VAULT_SECRET_ID_FILE), VAULT_KV_MOUNT
+// and VAULT_KV_VERSION
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		Address:                 os.Getenv("VAULT_ADDR"),
+		AuthMethod:              os.Getenv("VAULT_AUTH_METHOD"),
+		AuthMount:               os.Getenv("VAULT_AUTH_MOUNT"),
+		Role:                    os.Getenv("VAULT_ROLE"),
+		ServiceAccountTokenPath: os.Getenv("VAULT_SA_TOKEN_PATH"),
+		RoleID:                  os.Getenv("VAULT_ROLE_ID"),
+		SecretID:                os.Getenv("VAULT_SECRET_ID"),
+		KVMount:                 os.Getenv("VAULT_KV_MOUNT"),
+	}
+	if path := os.Getenv("VAULT_SECRET_ID_FILE"); path != "" && config.SecretID == "" {
+		secretID, err := ioutil.ReadFile(path)
+		if err != nil {
+			return config, fmt.Errorf("error reading VAULT_SECRET_ID_FILE: %v", err)
+		}
+		config.SecretID = strings.TrimSpace(string(secretID))
+	}
+	if version := os.Getenv("VAULT_KV_VERSION"); version != "" {
+		v, err := strconv.Atoi(version)
+		if err != nil {
+			return config, fmt.Errorf("invalid VAULT_KV_VERSION %q", version)
+		}
+		config.KVVersion = v
+	}
+	return config, nil
+}
+
+// setDefaults fills the empty fields and validates the config
+func (c *Config) setDefaults() error {
+	if c.AuthMethod == "" {
+		c.AuthMethod = TokenAuth
+	}
+	if c.AuthMount == "" {
+		c.AuthMount = c.AuthMethod
+	}
+	c.AuthMount = strings.Trim(c.AuthMount, "/")
+	if c.ServiceAccountTokenPath == "" {
+		c.ServiceAccountTokenPath = DefaultServiceAccountTokenPath
+	}
+	if c.KVMount == "" {
+		c.KVMount = defaultKVMount
+	}
+	c.KVMount = strings.Trim(c.KVMount, "/")
+	if c.KVVersion == 0 {
+		c.KVVersion = 1
+	}
+	if c.Log == nil {
+		c.Log = logrus.StandardLogger()
+	}
+
+	switch c.AuthMethod {
+	case TokenAuth:
+	case KubernetesAuth:
+		if c.Role == "" {
+			return fmt.Errorf("the role is required by the kubernetes auth method")
+		}
+	case AppRoleAuth:
+		if c.RoleID == "" || c.SecretID == "" {
+			return fmt.Errorf("the role id and the secret id are required by the approle auth method")
+		}
+	default:
+		return fmt.Errorf("unknown auth method %v, use token, kubernetes or approle", c.AuthMethod)
+	}
+	if c.KVVersion != 1 && c.KVVersion != 2 {
+		return fmt.Errorf("unknown KV version %v, use 1 or 2", c.KVVersion)
+	}
+	return nil
+}
@@ -2,53 +2,137 @@ package vault
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/vault/api"
-	"github.com/xumak-grid/aem-operator/pkg/secrets"
+	"github.com/xumak-grid/bedrock/metrics"
+	"github.com/xumak-grid/bedrock/secrets"
 )
 
 type vaultSecretService struct {
+	config    Config
+	apiConfig *api.Config
+	stop      chan struct{}
+	stopOnce  sync.Once
+
+	// mu guards client, it is replaced on every login
+	mu     sync.RWMutex
 	client *api.Client
 }
 
-// NewSecretService returns a new secret service implementation.
-func NewSecretService() (secrets.SecretService, error) {
-	// TODO: Get token and address from K8S
-	// For testing VAULT_TOKEN, VAULT_ADDRESS should be in the environment.
-	vaultClient, err := api.NewClient(api.DefaultConfig())
+// NewSecretService returns a secret service that authenticates with the auth method of the config,
+// the token is renewed in background until Close is called.
+// The TLS settings are read from the environment (VAULT_CACERT, VAULT_SKIP_VERIFY).
+func NewSecretService(config Config) (secrets.SecretService, error) {
+	err := config.setDefaults()
+	if err != nil {
+		return nil, err
+	}
+	apiConfig := api.DefaultConfig()
+	if config.Address != "" {
+		apiConfig.Address = config.Address
+	}
+	client, err := api.NewClient(apiConfig)
 	if err != nil {
 		return nil, err
 	}
-	vaultService := vaultSecretService{client: vaultClient}
-	return &vaultService, nil
+	vss := &vaultSecretService{
+		config:    config,
+		apiConfig: apiConfig,
+		stop:      make(chan struct{}),
+		client:    client,
+	}
+
+	var l lease
+	if config.AuthMethod == TokenAuth {
+		if client.Token() == "" {
+			return nil, fmt.Errorf("VAULT_TOKEN is required by the token auth method")
+		}
+		l, err = vss.lookupSelf()
+	} else {
+		l, err = vss.login()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error authenticating with the %v auth method: %v", config.AuthMethod, err)
+	}
+	go vss.watch(l)
+	return vss, nil
+}
+
+// Close stops the renewal of the token
+func (vss *vaultSecretService) Close() error {
+	vss.stopOnce.Do(func() {
+		close(vss.stop)
+	})
+	return nil
+}
+
+// vault returns the client with the current token
+func (vss *vaultSecretService) vault() *api.Client {
+	vss.mu.RLock()
+	defer vss.mu.RUnlock()
+	return vss.client
+}
+
+// path returns the path of the key in the KV secrets engine, the first segment of the key is
+// replaced by the mount and for the version 2 the prefix (data or metadata) follows the mount
+func (vss *vaultSecretService) path(key, prefix string) string {
+	segments := []string{vss.config.KVMount}
+	if vss.config.KVVersion == 2 {
+		segments = append(segments, prefix)
+	}
+	key = strings.Trim(key, "/")
+	if i := strings.Index(key, "/"); i >= 0 {
+		segments = append(segments, key[i+1:])
+	}
+	return strings.Join(segments, "/")
 }
 
 func (vss *vaultSecretService) Get(key string) (map[string]interface{}, error) {
-	s, err := vss.client.Logical().Read(key)
+	s, err := vss.vault().Logical().Read(vss.path(key, "data"))
+	metrics.CountVault("read", err)
 	if err != nil {
 		return nil, err
 	}
 	if s == nil {
 		return make(map[string]interface{}), nil
 	}
-	return s.Data, nil
+	if vss.config.KVVersion == 1 {
+		return s.Data, nil
+	}
+	// a deleted version has no data
+	data, ok := s.Data["data"].(map[string]interface{})
+	if !ok {
+		return make(map[string]interface{}), nil
+	}
+	return data, nil
 }
 
 func (vss *vaultSecretService) Put(key string, value map[string]interface{}) error {
-	_, err := vss.client.Logical().Write(key, value)
+	data := value
+	if vss.config.KVVersion == 2 {
+		data = map[string]interface{}{"data": value}
+	}
+	_, err := vss.vault().Logical().Write(vss.path(key, "data"), data)
+	metrics.CountVault("write", err)
 	return err
 }
 
+// Delete removes the secret, with the version 2 all the versions are removed
 func (vss *vaultSecretService) Delete(key string) error {
-	_, err := vss.client.Logical().Delete(key)
+	_, err := vss.vault().Logical().Delete(vss.path(key, "metadata"))
+	metrics.CountVault("delete", err)
 	return err
 }
 
-// CleanUp deletes secrets under the especified path
-// this is usually when the deployment is deleted
+// CleanUp deletes secrets under the especified path and its subpaths
+// this is usually when the deployment or the client is deleted
 // example path secret/demo/dev
 func (vss *vaultSecretService) CleanUp(path string) error {
-	s, err := vss.client.Logical().List(path)
+	s, err := vss.vault().Logical().List(vss.path(path, "metadata"))
+	metrics.CountVault("list", err)
 	if err != nil {
 		return err
 	}
@@ -61,6 +145,14 @@ func (vss *vaultSecretService) CleanUp(path string) error {
 	}
 	for _, i := range data {
 		k, _ := i.(string)
+		// the subpaths are listed with a trailing slash
+		if strings.HasSuffix(k, "/") {
+			err := vss.CleanUp(fmt.Sprintf("%v/%v", path, strings.TrimSuffix(k, "/")))
+			if err != nil {
+				return err
+			}
+			continue
+		}
 		// joins the path with the k to obtain the key
 		keyDelete := fmt.Sprintf("%v/%v", path, k)
 		err := vss.Delete(keyDelete)
@@ -70,3 +162,33 @@ func (vss *vaultSecretService) CleanUp(path string) error {
 	}
 	return nil
 }
+
+// Wrap reads the secret with response wrapping, the secret is returned by vault unwrap with the token
+func (vss *vaultSecretService) Wrap(key string, ttl time.Duration) (secrets.WrappedSecret, error) {
+	client, err := api.NewClient(vss.apiConfig)
+	if err != nil {
+		return secrets.WrappedSecret{}, err
+	}
+	client.SetToken(vss.vault().Token())
+	client.SetWrappingLookupFunc(func(operation, path string) string {
+		return ttl.String()
+	})
+	s, err := client.Logical().Read(vss.path(key, "data"))
+	metrics.CountVault("wrap", err)
+	if err != nil {
+		return secrets.WrappedSecret{}, err
+	}
+	if s == nil || s.WrapInfo == nil {
+		return secrets.WrappedSecret{}, fmt.Errorf("the secret %v does not exist", key)
+	}
+	return secrets.WrappedSecret{
+		Token:     s.WrapInfo.Token,
+		ExpiresAt: time.Now().UTC().Add(time.Duration(s.WrapInfo.TTL) * time.Second),
+	}, nil
+}
+
+// Check returns an error if vault is not reachable or the token is not valid
+func (vss *vaultSecretService) Check() error {
+	_, err := vss.lookupSelf()
+	return err
+}
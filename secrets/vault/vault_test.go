@@ -1,12 +1,22 @@
 package vault
 
 import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/xumak-grid/bedrock/secrets"
 )
 
 func TestVaultGet(t *testing.T) {
-	ss, err := NewSecretService()
+	ss, err := newEnvSecretService()
 	if err != nil {
 		t.Fatal("error", err)
 	}
@@ -30,7 +40,7 @@ func TestVaultGet(t *testing.T) {
 }
 
 func TestVaultGetCleanUp(t *testing.T) {
-	ss, err := NewSecretService()
+	ss, err := newEnvSecretService()
 	if err != nil {
 		t.Fatal("error", err)
 	}
@@ -39,6 +49,7 @@ func TestVaultGetCleanUp(t *testing.T) {
 	}
 	ss.Put("secret/test/env/secret1", data)
 	ss.Put("secret/test/env/secret2", data)
+	ss.Put("secret/test/other/secret3", data)
 
 	err = ss.CleanUp("secret/test/env")
 	if err != nil {
@@ -55,4 +66,143 @@ func TestVaultGetCleanUp(t *testing.T) {
 	if len(secret) > 0 || len(secret2) > 0 {
 		t.Error("secrets should not contain values", secret)
 	}
+
+	// the subpaths are deleted with the path of the client
+	err = ss.CleanUp("secret/test")
+	if err != nil {
+		t.Fatal("error", err)
+	}
+	secret3, err := ss.Get("secret/test/other/secret3")
+	if err != nil {
+		t.Fatal("error", err)
+	}
+	if len(secret3) > 0 {
+		t.Error("secrets of the subpaths should not contain values", secret3)
+	}
+}
+
+// newEnvSecretService connects to the vault of the environment, VAULT_ADDR and VAULT_TOKEN are required
+func newEnvSecretService() (secrets.SecretService, error) {
+	config, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewSecretService(config)
+}
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		config Config
+		key    string
+		prefix string
+		path   string
+	}{
+		{Config{KVMount: "secret", KVVersion: 1}, "secret/acme/dev/author-0", "data", "secret/acme/dev/author-0"},
+		{Config{KVMount: "bedrock", KVVersion: 1}, "secret/acme/dev", "metadata", "bedrock/acme/dev"},
+		{Config{KVMount: "secret", KVVersion: 2}, "secret/acme/dev/author-0", "data", "secret/data/acme/dev/author-0"},
+		{Config{KVMount: "kv", KVVersion: 2}, "/secret/acme/dev/", "metadata", "kv/metadata/acme/dev"},
+	}
+	for _, tt := range tests {
+		vss := &vaultSecretService{config: tt.config}
+		path := vss.path(tt.key, tt.prefix)
+		if path != tt.path {
+			t.Errorf("%v with %+v: expected %v, got %v", tt.key, tt.config, tt.path, path)
+		}
+	}
+}
+
+// fakeVault is a vault with the kubernetes auth method and a KV version 2 mounted in kv
+type fakeVault struct {
+	jwt      string
+	renewals int32
+	secrets  map[string]interface{}
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body := map[string]interface{}{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	auth := map[string]interface{}{"client_token": "login-token", "lease_duration": 1, "renewable": true}
+	switch {
+	case r.URL.Path == "/v1/auth/kubernetes/login":
+		if body["role"] != "bedrock-api" || body["jwt"] != v.jwt {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"auth": auth})
+		return
+	case r.Header.Get("X-Vault-Token") != "login-token":
+		w.WriteHeader(http.StatusForbidden)
+	case r.URL.Path == "/v1/auth/token/renew-self":
+		atomic.AddInt32(&v.renewals, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{"auth": auth})
+	case r.URL.Path == "/v1/kv/data/acme/dev/author-0" && r.Header.Get("X-Vault-Wrap-TTL") != "":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"wrap_info": map[string]interface{}{"token": "wrapping-token", "ttl": 300},
+		})
+	case r.URL.Path == "/v1/kv/data/acme/dev/author-0" && r.Method == http.MethodPut:
+		v.secrets[r.URL.Path] = body["data"]
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/v1/kv/data/acme/dev/author-0" && v.secrets[r.URL.Path] != nil:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"data": v.secrets[r.URL.Path]},
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestKubernetesAuth(t *testing.T) {
+	jwt, err := ioutil.TempFile("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(jwt.Name())
+	jwt.WriteString("service-account-jwt\n")
+	jwt.Close()
+
+	v := &fakeVault{jwt: "service-account-jwt", secrets: map[string]interface{}{}}
+	server := httptest.NewServer(v)
+	defer server.Close()
+
+	ss, err := NewSecretService(Config{
+		Address:                 server.URL,
+		AuthMethod:              KubernetesAuth,
+		Role:                    "bedrock-api",
+		ServiceAccountTokenPath: jwt.Name(),
+		KVMount:                 "kv",
+		KVVersion:               2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ss.(io.Closer).Close()
+
+	data := map[string]interface{}{"password": "s3cret"}
+	err = ss.Put("secret/acme/dev/author-0", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ss.Get("secret/acme/dev/author-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, s) {
+		t.Errorf("expected %v, got %v", data, s)
+	}
+	s, err = ss.Get("secret/acme/dev/publish-0")
+	if err != nil || len(s) != 0 {
+		t.Errorf("expected an empty secret, got %v %v", s, err)
+	}
+	wrapped, err := ss.(secrets.Wrapper).Wrap("secret/acme/dev/author-0", 5*time.Minute)
+	if err != nil || wrapped.Token != "wrapping-token" || wrapped.ExpiresAt.IsZero() {
+		t.Errorf("expected a wrapping token, got %+v %v", wrapped, err)
+	}
+
+	// the lease of one second is renewed after two thirds
+	time.Sleep(1500 * time.Millisecond)
+	if atomic.LoadInt32(&v.renewals) == 0 {
+		t.Error("expected the token to be renewed")
+	}
 }
@@ -29,6 +29,8 @@ const (
 	PodInfoVolName = "podinfo"
 	//GogsPort running port
 	GogsPort = 3000
+	// ContainerName the name of the gogs container in the server pod
+	ContainerName = "gogs"
 	// ServerName the server name for gogs deployment
 	ServerName = "gogs-server"
 	// ServiceName the service name for gogs deployment
@@ -41,6 +43,10 @@ const (
 	initJobImage = "grid/init-gogs:1.0.0"
 	// InitSecretName the name of the secret
 	InitSecretName = "gogs-init-config"
+	// ExportJobName the name of the k8s job that exports the repositories before deleting the client
+	ExportJobName = "gogs-export-job"
+	// exportJobImage the export job image
+	exportJobImage = "grid/export-gogs:1.0.0"
 )
 
 // Vendor represents the vendor for gogs and contains the images available to deploy
@@ -78,7 +84,7 @@ func volumes() []v1.Volume {
 
 func server(gogsImage, namespace string) v1.Container {
 	return v1.Container{
-		Name:  "gogs",
+		Name:  ContainerName,
 		Image: fmt.Sprintf("%s/%s", bedrock.GridDockerRepository(), gogsImage),
 		Env: []v1.EnvVar{
 			v1.EnvVar{
@@ -283,3 +289,71 @@ func InitJob(gogsHost, namespace string) *batchv1.Job {
 	}
 	return j
 }
+
+// ExportJob is a k8s job that uploads a mirror of all the repositories of the gogs server to the
+// s3 destination with a PUT to the pre-signed uploadURL, the job reads the admin credentials
+// from the init secret and has no aws credentials
+func ExportJob(namespace, destination, uploadURL string) *batchv1.Job {
+	backofflimit := int32(3)
+	optional := true
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ExportJobName,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backofflimit,
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					AutomountServiceAccountToken: &automountServiceAccount,
+					RestartPolicy:                v1.RestartPolicyNever,
+					Containers: []v1.Container{
+						v1.Container{
+							Name:            ExportJobName,
+							Image:           fmt.Sprintf("%s/%s", bedrock.GridDockerRepository(), exportJobImage),
+							ImagePullPolicy: v1.PullAlways,
+							Env: []v1.EnvVar{
+								v1.EnvVar{
+									Name:  "GOGS_HOST",
+									Value: "http://" + ServiceName,
+								},
+								v1.EnvVar{
+									Name:  "GOGS_CONFIG_FILE",
+									Value: "/app/config/configFile.json",
+								},
+								v1.EnvVar{
+									Name:  "EXPORT_DESTINATION",
+									Value: destination,
+								},
+								v1.EnvVar{
+									Name:  "EXPORT_UPLOAD_URL",
+									Value: uploadURL,
+								},
+							},
+							VolumeMounts: []v1.VolumeMount{
+								v1.VolumeMount{
+									Name:      "init-config",
+									MountPath: "/app/config",
+									ReadOnly:  true,
+								},
+							},
+						},
+					},
+					Volumes: []v1.Volume{
+						v1.Volume{
+							Name: "init-config",
+							VolumeSource: v1.VolumeSource{
+								// the gogs created without custom configuration has the default admin
+								Secret: &v1.SecretVolumeSource{
+									SecretName: InitSecretName,
+									Optional:   &optional,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -30,6 +30,10 @@ const (
 	initJobImage = "grid/init-nexus:1.0.0"
 	// InitSecretName the name of the secret
 	InitSecretName = "nexus-init-config"
+	// ExportJobName the name of the k8s job that exports the blobs before deleting the client
+	ExportJobName = "nexus-export-job"
+	// exportJobImage the export job image
+	exportJobImage = "grid/export-nexus:1.0.0"
 )
 
 var labels = map[string]string{
@@ -256,3 +260,54 @@ func InitJob(nexusHost, namespace string) *batchv1.Job {
 	}
 	return j
 }
+
+// ExportJob is a k8s job that uploads the blobs of the nexus server to the s3 destination
+// with a PUT to the pre-signed uploadURL, the job has no aws credentials
+func ExportJob(namespace, destination, uploadURL string) *batchv1.Job {
+	backofflimit := int32(3)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ExportJobName,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backofflimit,
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					AutomountServiceAccountToken: &automountServiceAccount,
+					RestartPolicy:                v1.RestartPolicyNever,
+					Containers: []v1.Container{
+						v1.Container{
+							Name:            ExportJobName,
+							Image:           fmt.Sprintf("%s/%s", bedrock.GridDockerRepository(), exportJobImage),
+							ImagePullPolicy: v1.PullAlways,
+							Env: []v1.EnvVar{
+								v1.EnvVar{
+									Name:  "NEXUS_USER",
+									Value: "admin",
+								},
+								v1.EnvVar{
+									Name:  "NEXUS_PASS",
+									Value: "admin123",
+								},
+								v1.EnvVar{
+									Name:  "NEXUS_HOST",
+									Value: "http://" + ServiceName,
+								},
+								v1.EnvVar{
+									Name:  "EXPORT_DESTINATION",
+									Value: destination,
+								},
+								v1.EnvVar{
+									Name:  "EXPORT_UPLOAD_URL",
+									Value: uploadURL,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
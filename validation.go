@@ -0,0 +1,361 @@
+package bedrock
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The fields of the bedrock types are validated with the rules in the validate tag,
+// the rules are separated by commas and the field path in the errors uses the json names:
+//
+//	required          the value can not be empty, nil or zero
+//	dns1123label      kubernetes name of a namespace or a service, max 63 characters
+//	dns1123subdomain  kubernetes name of most of the resources, max 253 characters
+//	oneof=A B C       the value must be one of the options separated by spaces
+//	min=N, max=N      bounds of a number or length of a string or a slice
+//	url               absolute http or https url
+//	email             email address
+//
+// the rules, except required, are not checked when the value is empty. The validations
+// that depend on more than one field are implemented by the types with a validate method.
+
+const (
+	// DNS1123LabelPattern and DNS1123LabelMaxLength define the dns1123label rule
+	DNS1123LabelPattern   = "^[a-z0-9]([-a-z0-9]*[a-z0-9])?$"
+	DNS1123LabelMaxLength = 63
+	// DNS1123SubdomainPattern and DNS1123SubdomainMaxLength define the dns1123subdomain rule
+	DNS1123SubdomainPattern   = "^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$"
+	DNS1123SubdomainMaxLength = 253
+)
+
+var (
+	dns1123LabelRegexp     = regexp.MustCompile(DNS1123LabelPattern)
+	dns1123SubdomainRegexp = regexp.MustCompile(DNS1123SubdomainPattern)
+	// qualifiedNameRegexp is the name of a kubernetes annotation key, after the optional prefix
+	qualifiedNameRegexp = regexp.MustCompile("^([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]$")
+)
+
+// ValidationErrors is the list of the invalid fields of a value
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := []string{}
+	for _, f := range e {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+// crossValidator is implemented by the types with rules that depend on more than one field,
+// path is the path of the value and must be used as prefix of the returned fields
+type crossValidator interface {
+	validate(path string) []FieldError
+}
+
+// Validate checks the validate tags of v and its nested values and returns
+// all the invalid fields at once as ValidationErrors, nil when v is valid
+func Validate(v interface{}) error {
+	errs := validateValue(reflect.ValueOf(v), "")
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func validateValue(v reflect.Value, path string) ValidationErrors {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	errs := ValidationErrors{}
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, ok := fieldName(f)
+			if !ok {
+				continue
+			}
+			p := joinPath(path, name)
+			if f.Anonymous {
+				p = path
+			}
+			errs = append(errs, checkRules(v.Field(i), p, f.Tag.Get("validate"))...)
+			errs = append(errs, validateValue(v.Field(i), p)...)
+		}
+		if cv, ok := v.Interface().(crossValidator); ok {
+			errs = append(errs, cv.validate(path)...)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, validateValue(v.Index(i), fmt.Sprintf("%v[%d]", path, i))...)
+		}
+	}
+	return errs
+}
+
+// fieldName returns the json name of an exported field, false when the field is not serialized
+func fieldName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name, true
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// checkRules returns the errors of the rules of a single field
+func checkRules(v reflect.Value, path, tag string) []FieldError {
+	if tag == "" {
+		return nil
+	}
+	rules := strings.Split(tag, ",")
+	if isEmpty(v) {
+		for _, rule := range rules {
+			if rule == "required" {
+				return []FieldError{{Field: path, Message: "is required"}}
+			}
+		}
+		return nil
+	}
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	errs := []FieldError{}
+	for _, rule := range rules {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+		msg := checkRule(v, name, arg)
+		if msg != "" {
+			errs = append(errs, FieldError{Field: path, Message: msg})
+		}
+	}
+	return errs
+}
+
+// checkRule returns the error message when v does not satisfy the rule
+func checkRule(v reflect.Value, rule, arg string) string {
+	switch rule {
+	case "required":
+		return ""
+	case "dns1123label":
+		if msg := dns1123Label(v.String()); msg != "" {
+			return msg
+		}
+	case "dns1123subdomain":
+		s := v.String()
+		if len(s) > DNS1123SubdomainMaxLength || !dns1123SubdomainRegexp.MatchString(s) {
+			return fmt.Sprintf("must consist of lowercase alphanumeric characters, '-' or '.', start and end with an alphanumeric character and have at most %d characters", DNS1123SubdomainMaxLength)
+		}
+	case "oneof":
+		options := strings.Fields(arg)
+		for _, o := range options {
+			if v.String() == o {
+				return ""
+			}
+		}
+		return "must be one of: " + strings.Join(options, ", ")
+	case "min", "max":
+		limit, err := strconv.Atoi(arg)
+		if err != nil {
+			panic(fmt.Sprintf("invalid %v rule argument %q", rule, arg))
+		}
+		n, unit := length(v)
+		if rule == "min" && n < limit {
+			return fmt.Sprintf("must be at least %d%v", limit, unit)
+		}
+		if rule == "max" && n > limit {
+			return fmt.Sprintf("must be at most %d%v", limit, unit)
+		}
+	case "url":
+		u, err := url.Parse(v.String())
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an absolute http or https url"
+		}
+	case "email":
+		if _, err := mail.ParseAddress(v.String()); err != nil {
+			return "must be a valid email address"
+		}
+	default:
+		panic(fmt.Sprintf("unknown validation rule %q", rule))
+	}
+	return ""
+}
+
+// dns1123Label returns the error message when s is not a valid DNS-1123 label
+func dns1123Label(s string) string {
+	if len(s) > DNS1123LabelMaxLength || !dns1123LabelRegexp.MatchString(s) {
+		return fmt.Sprintf("must consist of lowercase alphanumeric characters or '-', start and end with an alphanumeric character and have at most %d characters", DNS1123LabelMaxLength)
+	}
+	return ""
+}
+
+// length returns the number used by the min and max rules and its unit
+func length(v reflect.Value) (int, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return len(v.String()), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len(), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), ""
+	}
+	panic(fmt.Sprintf("min and max rules are not supported in %v", v.Kind()))
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.Interface() == reflect.Zero(v.Type()).Interface()
+}
+
+func (c Client) validate(path string) []FieldError {
+	errs := []FieldError{}
+	if c.CustomConfig && c.Configuration == nil {
+		errs = append(errs, FieldError{Field: joinPath(path, "configuration"), Message: "is required when customConfig is true"})
+	}
+	if c.DryRun && !c.CustomConfig {
+		errs = append(errs, FieldError{Field: joinPath(path, "dryRun"), Message: "only available with customConfig"})
+	}
+	for k := range c.MetaData {
+		if msg := metaDataKey(k); msg != "" {
+			errs = append(errs, FieldError{Field: joinPath(path, "meta") + "." + k, Message: msg})
+		}
+	}
+	return errs
+}
+
+func (p ClientPatch) validate(path string) []FieldError {
+	errs := []FieldError{}
+	for k := range p.MetaData {
+		if msg := metaDataKey(k); msg != "" {
+			errs = append(errs, FieldError{Field: joinPath(path, "meta") + "." + k, Message: msg})
+		}
+	}
+	return errs
+}
+
+// metaDataKey returns the error message when k is not a valid annotation key or is reserved by bedrock
+func metaDataKey(k string) string {
+	if strings.HasPrefix(k, ReservedMetaDataPrefix) {
+		return fmt.Sprintf("the prefix %v is reserved", ReservedMetaDataPrefix)
+	}
+	name := k
+	if i := strings.LastIndex(k, "/"); i >= 0 {
+		prefix := k[:i]
+		name = k[i+1:]
+		if len(prefix) > DNS1123SubdomainMaxLength || !dns1123SubdomainRegexp.MatchString(prefix) {
+			return "the prefix of the key must be a DNS-1123 subdomain"
+		}
+	}
+	if len(name) > 63 || !qualifiedNameRegexp.MatchString(name) {
+		return "the key must consist of alphanumeric characters, '-', '_' or '.', start and end with an alphanumeric character and have at most 63 characters after the prefix"
+	}
+	return ""
+}
+
+func (c ClientCustomConfig) validate(path string) []FieldError {
+	errs := []FieldError{}
+	for i, env := range c.Environments {
+		// the environment is the name of the AEM deployment
+		if msg := dns1123Label(env); msg != "" {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("%v[%d]", joinPath(path, "environments"), i), Message: msg})
+		}
+	}
+	if c.InitialRepositoryType == ContentSetupEPCommerce && c.EPObjectType == nil {
+		errs = append(errs, FieldError{Field: joinPath(path, "epCommerce"), Message: "is required when initialRepositoryType is " + ContentSetupEPCommerce})
+	}
+	if c.InitialRepositoryType == ContentSetupBloomreach && c.BRObjectType == nil {
+		errs = append(errs, FieldError{Field: joinPath(path, "bloomreachArchetype"), Message: "is required when initialRepositoryType is " + ContentSetupBloomreach})
+	}
+	return errs
+}
+
+func (a Artifactory) validate(path string) []FieldError {
+	if a.CustomConfig && a.Configuration == nil {
+		return []FieldError{{Field: joinPath(path, "configuration"), Message: "is required when customConfig is true"}}
+	}
+	return nil
+}
+
+func (p ArtifactoryPatch) validate(path string) []FieldError {
+	if p.CustomConfig != nil && *p.CustomConfig && p.Configuration == nil {
+		return []FieldError{{Field: joinPath(path, "configuration"), Message: "is required when customConfig is true"}}
+	}
+	return nil
+}
+
+func (c ArtifactoryConfig) validate(path string) []FieldError {
+	if len(c.Users)+len(c.Groups)+len(c.Hosteds)+len(c.Proxies) == 0 {
+		return []FieldError{{Field: path, Message: "requires at least 1 member of users, groups, hosteds or proxies"}}
+	}
+	return nil
+}
+
+func (p ArtifactoryProxy) validate(path string) []FieldError {
+	if p.RequiredAuth && p.Authentication == nil {
+		return []FieldError{{Field: joinPath(path, "authentication"), Message: "is required when requiredAuth is true"}}
+	}
+	return nil
+}
+
+func (s SCM) validate(path string) []FieldError {
+	if s.CustomConfig && s.Configuration == nil {
+		return []FieldError{{Field: joinPath(path, "configuration"), Message: "is required when customConfig is true"}}
+	}
+	return nil
+}
+
+func (p SCMPatch) validate(path string) []FieldError {
+	if p.CustomConfig != nil && *p.CustomConfig && p.Configuration == nil {
+		return []FieldError{{Field: joinPath(path, "configuration"), Message: "is required when customConfig is true"}}
+	}
+	return nil
+}
+
+func (d SCMInitData) validate(path string) []FieldError {
+	errs := []FieldError{}
+	if d.AdminName == "admin" {
+		errs = append(errs, FieldError{Field: joinPath(path, "admin_name"), Message: "admin name is reserved"})
+	}
+	if d.AdminConfirmPass != d.AdminPass {
+		errs = append(errs, FieldError{Field: joinPath(path, "admin_confirm_passwd"), Message: "must be equal to admin_passwd"})
+	}
+	return errs
+}
+
+func (r SCMRepository) validate(path string) []FieldError {
+	if r.ContentSetupType == ContentSetupEPCommerce && r.EPObjectType == nil {
+		return []FieldError{{Field: joinPath(path, "ep_commerce"), Message: "is required when content_setup_type is " + ContentSetupEPCommerce}}
+	}
+	if r.ContentSetupType == ContentSetupBloomreach && r.BRObjectType == nil {
+		return []FieldError{{Field: joinPath(path, "bloomreach_archetype"), Message: "is required when content_setup_type is " + ContentSetupBloomreach}}
+	}
+	return nil
+}
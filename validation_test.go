@@ -0,0 +1,178 @@
+package bedrock
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func validClient() Client {
+	return Client{
+		ClientID:     "acme",
+		CustomConfig: true,
+		Configuration: &ClientCustomConfig{
+			FullCompanyName:            "Acme Inc",
+			AdminEmail:                 "admin@acme.com",
+			Environments:               []string{"dev", "qa"},
+			AEMInstancesVersion:        "6.3",
+			AEMInstancesType:           "small",
+			DispatcherInstancesVersion: "4.2.2",
+			DispatcherInstancesType:    "small",
+			InitialRepositoryType:      ContentSetupDantaDemo,
+		},
+	}
+}
+
+// invalidFields returns the sorted fields of the validation errors of v
+func invalidFields(t *testing.T, v interface{}) []string {
+	err := Validate(v)
+	if err == nil {
+		return nil
+	}
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors, got %T", err)
+	}
+	fields := []string{}
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestValidateClient(t *testing.T) {
+	if fields := invalidFields(t, validClient()); fields != nil {
+		t.Fatalf("expected a valid client, got errors in %v", fields)
+	}
+
+	c := validClient()
+	c.ClientID = "Acme_Corp"
+	c.Configuration.AdminEmail = "not an email"
+	c.Configuration.Environments = []string{"dev", "QA"}
+	c.Configuration.AEMInstancesType = "huge"
+	c.Configuration.DispatcherInstancesVersion = ""
+	expected := []string{
+		"clientId",
+		"configuration.adminEmail",
+		"configuration.aemInstancesType",
+		"configuration.dispatcherInstancesVersion",
+		"configuration.environments[1]",
+	}
+	if fields := invalidFields(t, c); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected errors in %v, got %v", expected, fields)
+	}
+
+	c = validClient()
+	c.Configuration.InitialRepositoryType = ContentSetupEPCommerce
+	if fields := invalidFields(t, c); !reflect.DeepEqual(fields, []string{"configuration.epCommerce"}) {
+		t.Errorf("expected error in epCommerce, got %v", fields)
+	}
+	c.Configuration.EPObjectType = &EPObjectType{Version: "7.1"}
+	if fields := invalidFields(t, c); fields != nil {
+		t.Errorf("expected a valid ep-commerce client, got errors in %v", fields)
+	}
+	c = validClient()
+	c.Configuration.InitialRepositoryType = ContentSetupBloomreach
+	if fields := invalidFields(t, c); !reflect.DeepEqual(fields, []string{"configuration.bloomreachArchetype"}) {
+		t.Errorf("expected error in bloomreachArchetype, got %v", fields)
+	}
+	c.Configuration.BRObjectType = &BRObjectType{ArchetypeVersion: "12.2.0", GroupID: "org.acme", ArtifactID: "acme", Version: "0.1.0-SNAPSHOT", Package: "com.acme"}
+	if fields := invalidFields(t, c); !reflect.DeepEqual(fields, []string{"configuration.bloomreachArchetype.project_name"}) {
+		t.Errorf("expected error in the bloomreach project_name, got %v", fields)
+	}
+	c.Configuration.InitialRepositoryType = "wordpress"
+	c.Configuration.BRObjectType = nil
+	if fields := invalidFields(t, c); !reflect.DeepEqual(fields, []string{"configuration.initialRepositoryType"}) {
+		t.Errorf("expected error in initialRepositoryType, got %v", fields)
+	}
+
+	c = Client{ClientID: "acme", CustomConfig: true, DryRun: true}
+	if fields := invalidFields(t, c); !reflect.DeepEqual(fields, []string{"configuration"}) {
+		t.Errorf("expected error in configuration, got %v", fields)
+	}
+	c = Client{ClientID: "acme", DryRun: true}
+	if fields := invalidFields(t, c); !reflect.DeepEqual(fields, []string{"dryRun"}) {
+		t.Errorf("expected error in dryRun, got %v", fields)
+	}
+	c = Client{ClientID: "acme", MetaData: map[string]string{"team": "web", "example.com/tier": "gold", "grid.xumak.io/protected": "false", "bad key": "x"}}
+	if fields := invalidFields(t, c); !reflect.DeepEqual(fields, []string{"meta.bad key", "meta.grid.xumak.io/protected"}) {
+		t.Errorf("expected errors in the invalid and reserved keys, got %v", fields)
+	}
+}
+
+func TestValidateClientPatch(t *testing.T) {
+	web := "web"
+	p := ClientPatch{MetaData: map[string]*string{"team": &web, "owner": nil, "grid.xumak.io/deletion": nil, "-tier": &web}}
+	if fields := invalidFields(t, p); !reflect.DeepEqual(fields, []string{"meta.-tier", "meta.grid.xumak.io/deletion"}) {
+		t.Errorf("expected errors in the invalid and reserved keys, got %v", fields)
+	}
+}
+
+func TestValidateAEMDeployment(t *testing.T) {
+	d := AEMDeployment{
+		ClientID:      "acme",
+		EnvironmentID: "dev",
+		Spec: AEMDeploymentSpec{
+			Authors:           Config{Type: "small", Replicas: 1},
+			Publishers:        Config{Type: "medium", Replicas: 11},
+			Dispatchers:       Config{Replicas: -1},
+			DispatcherVersion: "4.2.2",
+		},
+	}
+	expected := []string{"spec.dispatchers.replicas", "spec.publishers.replicas", "spec.version"}
+	if fields := invalidFields(t, d); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected errors in %v, got %v", expected, fields)
+	}
+}
+
+func TestValidateArtifactory(t *testing.T) {
+	a := Artifactory{
+		Image:         "grid/nexus:3.8.0",
+		CustomConfig:  true,
+		Configuration: &ArtifactoryConfig{},
+	}
+	if fields := invalidFields(t, a); !reflect.DeepEqual(fields, []string{"configuration"}) {
+		t.Errorf("expected error in configuration, got %v", fields)
+	}
+
+	a.Configuration.Proxies = []ArtifactoryProxy{
+		{Name: "danta", VersionPolicy: "LATEST", LayoutPolicy: "STRICT", RemoteURL: "repo.example.com", RequiredAuth: true},
+	}
+	expected := []string{
+		"configuration.proxies[0].authentication",
+		"configuration.proxies[0].remoteUrl",
+		"configuration.proxies[0].versionPolicy",
+	}
+	if fields := invalidFields(t, a); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected errors in %v, got %v", expected, fields)
+	}
+}
+
+func TestValidateSCM(t *testing.T) {
+	s := SCM{
+		Image:        "grid/gogs:0.11.34",
+		CustomConfig: true,
+		Configuration: &SCMConfig{
+			InitData: &SCMInitData{
+				AdminName:        "admin",
+				AdminPass:        "secret",
+				AdminConfirmPass: "other",
+				AdminEmail:       "admin@acme.com",
+			},
+			Repositories: []SCMRepository{
+				{Name: "acme-app", ContentSetupType: ContentSetupEPCommerce},
+				{Name: "acme-site", ContentSetupType: "wordpress"},
+			},
+		},
+	}
+	expected := []string{
+		"configuration.init_data.admin_confirm_passwd",
+		"configuration.init_data.admin_name",
+		"configuration.repositories[0].ep_commerce",
+		"configuration.repositories[1].content_setup_type",
+	}
+	if fields := invalidFields(t, s); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected errors in %v, got %v", expected, fields)
+	}
+}